@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -22,82 +22,160 @@ type Client struct {
 	Config      aws.Config
 	tablename   string
 	s3          *awsS3.Client
-	dynamo      *dynamodb.Client
+	dynamo      DynamoMethods
 	sqs         *sqs.Client
 	eventBridge *eventbridge.Client
 	http        http.Client
+	endpoints   endpoints
+	s3PathStyle bool
 }
 
-// TableName returns the name of the DynamoDB table.
+// TableName returns the name of the DynamoDB table: the table set with
+// WithTable or WithTableName on the client carried by ctx, or, when ctx
+// carries none, on the default client, and otherwise the TABLENAME
+// environment variable. The default client is not created to find out.
 func TableName(ctx context.Context) string {
-	tn := os.Getenv("TABLENAME")
-	if tn == "" {
-		panic("TABLENAME environment variable not set")
+	if c, ok := FromContext(ctx); ok {
+		return c.TableName(ctx)
 	}
-	return tn
+	if c := defaultClient.Load(); c != nil {
+		return c.TableName(ctx)
+	}
+	return envTableName()
 }
 
-// TableName returns the name of the DynamoDB table.
+// TableName returns the name of the DynamoDB table set on the client, or
+// the TABLENAME environment variable.
 func (c *Client) TableName(ctx context.Context) string {
 	if c.tablename == "" {
-		return TableName(ctx)
+		return envTableName()
 	}
 	return c.tablename
 }
 
-var defaultClient *Client
+func envTableName() string {
+	tn := os.Getenv("TABLENAME")
+	if tn == "" {
+		panic("TABLENAME environment variable not set")
+	}
+	return tn
+}
+
+var (
+	defaultClient atomic.Pointer[Client]
+	defaultMu     sync.Mutex
+	// serviceMu guards the lazy creation of service clients, which are
+	// shared by every goroutine using the default client.
+	serviceMu sync.Mutex
+)
+
+// DefaultClient returns the singleton client. If the singleton client does
+// not exist, it is created from the default config and the AWS_REGION
+// environment variable. Creation is retried on the next call if it fails.
+func DefaultClient(ctx context.Context) (*Client, error) {
+	if c := defaultClient.Load(); c != nil {
+		return c, nil
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if c := defaultClient.Load(); c != nil {
+		return c, nil
+	}
+	c, err := New(ctx, WithRegion(os.Getenv("AWS_REGION")))
+	if err != nil {
+		return nil, err
+	}
+	defaultClient.Store(&c)
+	return &c, nil
+}
 
 // GetDefaultClient returns the singleton client. If the singleton
-// client does not exist, it will be created using the default config
+// client does not exist, it will be created using the default config.
+//
+// Deprecated: GetDefaultClient panics if the default config cannot be
+// loaded. Use DefaultClient or Resolve, which return the error instead.
 func GetDefaultClient(ctx context.Context) *Client {
-	if defaultClient == nil {
-		c := newClient(ctx)
-		defaultClient = &c
+	c, err := DefaultClient(ctx)
+	if err != nil {
+		panic(fmt.Errorf("clients: unable to create default client: %w", err))
 	}
-	return defaultClient
+	return c
 }
 
 // SetDefaultClient sets the provided client as the singleton client
 func SetDefaultClient(ctx context.Context, client Client) {
-	defaultClient = &client
+	defaultClient.Store(&client)
 }
 
-// new creates a new client appropriate for use when running within an aws service (i.e. lambda)
-func newClient(ctx context.Context) Client {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
-	fmt.Println(cfg.Region)
-	if err != nil {
-		logrus.Fatal("error", err)
+type clientContextKey struct{}
+
+// WithClient returns a copy of ctx that carries the provided client. Calls
+// made with the returned context use it in place of the default client.
+func WithClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// FromContext returns the client carried by ctx, if any.
+func FromContext(ctx context.Context) (*Client, bool) {
+	c, ok := ctx.Value(clientContextKey{}).(*Client)
+	return c, ok && c != nil
+}
+
+// Resolve returns the client carried by ctx, falling back to the
+// default client.
+func Resolve(ctx context.Context) (*Client, error) {
+	if c, ok := FromContext(ctx); ok {
+		return c, nil
 	}
-	return NewFromConfig(ctx, cfg)
+	return DefaultClient(ctx)
 }
 
 // NewLongTermCredentialClient creates a new client appropriate for use when you have long term credentials
+//
+// Deprecated: NewLongTermCredentialClient exits the program if the config
+// cannot be loaded. Use New with WithStaticCredentials, which returns the
+// error instead.
 func NewLongTermCredentialClient(ctx context.Context, accessKeyID, secretAccessKey string) Client {
-	cfg, err := newConfigWithLongTermCredentials(ctx, accessKeyID, secretAccessKey)
+	c, err := New(ctx, WithStaticCredentials(accessKeyID, secretAccessKey, ""))
 	if err != nil {
 		logrus.Fatal("error", err)
 	}
-	return NewFromConfig(ctx, cfg)
+	return c
 }
 
 // NewClientFromSTSCredentials creates a new client appropriate for use when you have short term STS
 // credentials like accessKeyID, secretAccessKey, and sessionToken.
+//
+// Deprecated: NewClientFromSTSCredentials exits the program if the config
+// cannot be loaded. Use New with WithStaticCredentials, which returns the
+// error instead.
 func NewClientFromSTSCredentials(ctx context.Context, accessKeyID, secretAccessKey, sessionToken string) Client {
-	cfg, err := newConfigWithCredentials(ctx, accessKeyID, secretAccessKey, sessionToken)
+	c, err := New(ctx, WithStaticCredentials(accessKeyID, secretAccessKey, sessionToken))
 	if err != nil {
 		logrus.Fatal("error", err)
 	}
-	return NewFromConfig(ctx, cfg)
+	return c
 }
 
 // NewFromConfig creates a new client when you have a custom config that you want to use.
-func NewFromConfig(ctx context.Context, cfg aws.Config) Client {
+// The options override the region and credentials of the config and set the
+// endpoints used by the service clients.
+func NewFromConfig(ctx context.Context, cfg aws.Config, opts ...Option) Client {
+	o := newOptions(opts)
+	if o.region != "" {
+		cfg.Region = o.region
+	}
+	if o.credentials != nil {
+		cfg.Credentials = aws.NewCredentialsCache(o.credentials)
+	}
 	return Client{
-		Config: cfg,
+		Config:    cfg,
+		tablename: o.tablename,
 		http: http.Client{
 			Timeout: 30,
 		},
+		endpoints:   o.endpoints,
+		s3PathStyle: o.s3PathStyle,
 	}
 }
 
@@ -111,31 +189,19 @@ func (c Client) WithConfig(cfg aws.Config) Client {
 	return c
 }
 
-// newConfigWithCredentials creates an AWS Config using the provided IAM credentials.
-func newConfigWithCredentials(ctx context.Context, accessKeyID, secretAccessKey, sessionToken string) (aws.Config, error) {
-	// Create a static credentials provider
-	creds := credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken)
-	// Load the default configuration
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithCredentialsProvider(creds),
-	)
-	if err != nil {
-		return aws.Config{}, err
-	}
-
-	return cfg, nil
-}
-
-// NewConfigWithLongTermCredentials creates an AWS Config using the provided IAM credentials.
-// The session token is optional because it is only required for temporary credentials.
-func newConfigWithLongTermCredentials(ctx context.Context, accessKeyID, secretAccessKey string) (aws.Config, error) {
-	return newConfigWithCredentials(ctx, accessKeyID, secretAccessKey, "")
+// WithDynamo returns a copy of the client whose Dynamo() calls are served by
+// the provided implementation, such as an in-memory table used in tests.
+func (c Client) WithDynamo(dynamo DynamoMethods) Client {
+	c.dynamo = dynamo
+	return c
 }
 
 // S3 returns the s3 client, or creates one if one doesnt exist
 func (c *Client) S3() *awsS3.Client {
+	serviceMu.Lock()
+	defer serviceMu.Unlock()
 	if c.s3 == nil {
-		c.s3 = s3.NewFromConfig(c.Config)
+		c.s3 = s3.NewFromConfig(c.Config, c.s3Options)
 	}
 	return c.s3
 }
@@ -146,28 +212,38 @@ type DynamoMethods interface {
 	DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
 	Query(ctx context.Context, in *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
 	UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	BatchGetItem(ctx context.Context, in *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, in *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	TransactGetItems(ctx context.Context, in *dynamodb.TransactGetItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error)
+	TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
 }
 
 // Dynamo returns the Dynamo client, or creates one if one doesnt exist
 func (c *Client) Dynamo() DynamoMethods {
+	serviceMu.Lock()
+	defer serviceMu.Unlock()
 	if c.dynamo == nil {
-		c.dynamo = dynamodb.NewFromConfig(c.Config)
+		c.dynamo = dynamodb.NewFromConfig(c.Config, c.dynamoOptions)
 	}
 	return c.dynamo
 }
 
 // SQS returns the SQS client, or creates one if one doesnt exist
 func (c *Client) SQS() *sqs.Client {
+	serviceMu.Lock()
+	defer serviceMu.Unlock()
 	if c.sqs == nil {
-		c.sqs = sqs.NewFromConfig(c.Config)
+		c.sqs = sqs.NewFromConfig(c.Config, c.sqsOptions)
 	}
 	return c.sqs
 }
 
 // EventBridge returns the EventBridge client, or creates one if one doesnt exist
 func (c *Client) EventBridge() *eventbridge.Client {
+	serviceMu.Lock()
+	defer serviceMu.Unlock()
 	if c.eventBridge == nil {
-		c.eventBridge = eventbridge.NewFromConfig(c.Config)
+		c.eventBridge = eventbridge.NewFromConfig(c.Config, c.eventBridgeOptions)
 	}
 	return c.eventBridge
 }
@@ -0,0 +1,55 @@
+package clients
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	previous := defaultClient.Load()
+	t.Cleanup(func() { defaultClient.Store(previous) })
+
+	SetDefaultClient(ctx, Client{}.WithTableName("default"))
+	c, err := Resolve(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "default", c.TableName(ctx))
+
+	scoped := Client{}.WithTableName("scoped")
+	ctx = WithClient(ctx, &scoped)
+	c, err = Resolve(ctx)
+	require.NoError(t, err)
+	assert.Same(t, &scoped, c)
+
+	_, ok := FromContext(WithClient(context.Background(), nil))
+	assert.False(t, ok)
+}
+
+func TestDefaultClientConcurrent(t *testing.T) {
+	ctx := context.Background()
+	previous := defaultClient.Load()
+	t.Cleanup(func() { defaultClient.Store(previous) })
+	defaultClient.Store(nil)
+	t.Setenv("AWS_REGION", LocalRegion)
+
+	var wg sync.WaitGroup
+	results := make([]*Client, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := DefaultClient(ctx)
+			assert.NoError(t, err)
+			c.Dynamo()
+			results[i] = c
+		}(i)
+	}
+	wg.Wait()
+	for _, c := range results {
+		assert.Same(t, results[0], c)
+	}
+}
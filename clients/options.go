@@ -0,0 +1,175 @@
+package clients
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	awsS3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// LocalRegion is the region used by WithLocalEndpoint when no other region
+// has been configured. Local stand-ins accept any region.
+const LocalRegion = "us-east-1"
+
+// endpoints holds the per-service endpoint overrides of a Client.
+// An empty string leaves the SDK's default endpoint resolution in place.
+type endpoints struct {
+	dynamo      string
+	s3          string
+	sqs         string
+	eventBridge string
+}
+
+// options collects the settings applied by New and NewFromConfig.
+type options struct {
+	region      string
+	credentials aws.CredentialsProvider
+	endpoints   endpoints
+	s3PathStyle bool
+	tablename   string
+}
+
+// Option configures a Client created with New or NewFromConfig.
+type Option func(*options)
+
+// WithRegion sets the AWS region used by every service client.
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// WithStaticCredentials uses the provided credentials instead of the default
+// credential chain. Local stand-ins accept any non-empty values.
+func WithStaticCredentials(accessKeyID, secretAccessKey, sessionToken string) Option {
+	return func(o *options) {
+		o.credentials = credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken)
+	}
+}
+
+// WithEndpoint points every service at the provided endpoint URL, which is
+// what LocalStack expects. Per-service options applied afterwards take
+// precedence.
+func WithEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoints = endpoints{dynamo: url, s3: url, sqs: url, eventBridge: url}
+	}
+}
+
+// WithDynamoEndpoint points the DynamoDB client at the provided endpoint URL,
+// such as DynamoDB Local on http://localhost:8000.
+func WithDynamoEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoints.dynamo = url
+	}
+}
+
+// WithS3Endpoint points the S3 client at the provided endpoint URL, such as
+// MinIO on http://localhost:9000.
+func WithS3Endpoint(url string) Option {
+	return func(o *options) {
+		o.endpoints.s3 = url
+	}
+}
+
+// WithSQSEndpoint points the SQS client at the provided endpoint URL.
+func WithSQSEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoints.sqs = url
+	}
+}
+
+// WithEventBridgeEndpoint points the EventBridge client at the provided
+// endpoint URL.
+func WithEventBridgeEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoints.eventBridge = url
+	}
+}
+
+// WithS3PathStyle makes the S3 client address buckets as http://host/bucket
+// rather than http://bucket.host, which MinIO and LocalStack require.
+func WithS3PathStyle() Option {
+	return func(o *options) {
+		o.s3PathStyle = true
+	}
+}
+
+// WithTable sets the DynamoDB table name used instead of the TABLENAME
+// environment variable by the rows and transactions that use the client,
+// unless they name a table of their own.
+func WithTable(tablename string) Option {
+	return func(o *options) {
+		o.tablename = tablename
+	}
+}
+
+// WithLocalEndpoint is a profile for local development and integration tests.
+// It points every service at the endpoint, uses dummy static credentials,
+// enables path-style S3 addressing and defaults the region to LocalRegion.
+func WithLocalEndpoint(url string) Option {
+	return func(o *options) {
+		if o.region == "" {
+			o.region = LocalRegion
+		}
+		WithStaticCredentials("local", "local", "")(o)
+		WithEndpoint(url)(o)
+		WithS3PathStyle()(o)
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// New creates a new client from the default config, adjusted by the provided
+// options.
+func New(ctx context.Context, opts ...Option) (Client, error) {
+	o := newOptions(opts)
+	var loadOpts []func(*config.LoadOptions) error
+	if o.region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(o.region))
+	}
+	if o.credentials != nil {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(o.credentials))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return Client{}, err
+	}
+	return NewFromConfig(ctx, cfg, opts...), nil
+}
+
+func (c *Client) dynamoOptions(o *dynamodb.Options) {
+	if c.endpoints.dynamo != "" {
+		o.BaseEndpoint = aws.String(c.endpoints.dynamo)
+	}
+}
+
+func (c *Client) s3Options(o *awsS3.Options) {
+	if c.endpoints.s3 != "" {
+		o.BaseEndpoint = aws.String(c.endpoints.s3)
+	}
+	o.UsePathStyle = o.UsePathStyle || c.s3PathStyle
+}
+
+func (c *Client) sqsOptions(o *sqs.Options) {
+	if c.endpoints.sqs != "" {
+		o.BaseEndpoint = aws.String(c.endpoints.sqs)
+	}
+}
+
+func (c *Client) eventBridgeOptions(o *eventbridge.Options) {
+	if c.endpoints.eventBridge != "" {
+		o.BaseEndpoint = aws.String(c.endpoints.eventBridge)
+	}
+}
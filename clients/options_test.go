@@ -0,0 +1,75 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithLocalEndpoint(t *testing.T) {
+	ctx := context.Background()
+	c, err := New(ctx,
+		WithLocalEndpoint("http://localhost:4566"),
+		WithDynamoEndpoint("http://localhost:8000"),
+		WithTable("local-table"),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, LocalRegion, c.Config.Region)
+	assert.Equal(t, "local-table", c.TableName(ctx))
+	creds, err := c.Config.Credentials.Retrieve(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "local", creds.AccessKeyID)
+
+	dynamo, ok := c.Dynamo().(*dynamodb.Client)
+	require.True(t, ok)
+	assert.Equal(t, "http://localhost:8000", aws.ToString(dynamo.Options().BaseEndpoint))
+
+	s3Opts := c.S3().Options()
+	assert.Equal(t, "http://localhost:4566", aws.ToString(s3Opts.BaseEndpoint))
+	assert.True(t, s3Opts.UsePathStyle)
+	assert.Equal(t, "http://localhost:4566", aws.ToString(c.SQS().Options().BaseEndpoint))
+	assert.Equal(t, "http://localhost:4566", aws.ToString(c.EventBridge().Options().BaseEndpoint))
+}
+
+func TestNewFromConfigOptions(t *testing.T) {
+	c := NewFromConfig(context.Background(), aws.Config{Region: "us-west-2"}, WithRegion("eu-west-1"))
+	assert.Equal(t, "eu-west-1", c.Config.Region)
+
+	dynamo, ok := c.Dynamo().(*dynamodb.Client)
+	require.True(t, ok)
+	assert.Nil(t, dynamo.Options().BaseEndpoint)
+	assert.False(t, c.S3().Options().UsePathStyle)
+}
+
+func TestNewClientFromSTSCredentials(t *testing.T) {
+	ctx := context.Background()
+	var c Client = NewClientFromSTSCredentials(ctx, "id", "secret", "token")
+	creds, err := c.Config.Credentials.Retrieve(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "id", creds.AccessKeyID)
+	assert.Equal(t, "token", creds.SessionToken)
+}
+
+func TestTableName(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TABLENAME", "env-table")
+	previous := defaultClient.Load()
+	t.Cleanup(func() { defaultClient.Store(previous) })
+
+	SetDefaultClient(ctx, Client{})
+	assert.Equal(t, "env-table", TableName(ctx))
+
+	local, err := New(ctx, WithLocalEndpoint("http://localhost:4566"), WithTable("local-table"))
+	require.NoError(t, err)
+	assert.Equal(t, "local-table", TableName(WithClient(ctx, &local)))
+
+	SetDefaultClient(ctx, Client{}.WithTableName("default-table"))
+	assert.Equal(t, "default-table", TableName(ctx))
+	assert.Equal(t, "local-table", TableName(WithClient(ctx, &local)))
+	assert.Equal(t, "env-table", TableName(WithClient(ctx, &Client{})), "the client carried by ctx replaces the default one")
+}
@@ -0,0 +1,413 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+)
+
+// Cardinality is the number of links of a type that the Entity0 and Entity1
+// of a DiLink or TriLink may each take part in.
+type Cardinality int
+
+const (
+	// ManyToMany puts no limit on the links of an entity. It is the
+	// cardinality of links that do not declare one.
+	ManyToMany Cardinality = iota
+	// OneToMany links an Entity0 to many Entity1s, each of which is linked
+	// to one Entity0 only.
+	OneToMany
+	// ManyToOne links many Entity0s to an Entity1, each Entity0 being
+	// linked to one Entity1 only.
+	ManyToOne
+	// OneToOne links each Entity0 to one Entity1 only, and each Entity1 to
+	// one Entity0 only.
+	OneToOne
+)
+
+func (c Cardinality) String() string {
+	switch c {
+	case ManyToMany:
+		return "many-to-many"
+	case OneToMany:
+		return "one-to-many"
+	case ManyToOne:
+		return "many-to-one"
+	case OneToOne:
+		return "one-to-one"
+	}
+	return fmt.Sprintf("Cardinality(%d)", int(c))
+}
+
+// uniqueSlots returns the slots whose entity may take part in one link only.
+func (c Cardinality) uniqueSlots() []int {
+	switch c {
+	case OneToMany:
+		return []int{1}
+	case ManyToOne:
+		return []int{0}
+	case OneToOne:
+		return []int{0, 1}
+	}
+	return nil
+}
+
+// CardinalityDeclarer is implemented by link types that limit the links
+// their entities take part in:
+//
+//	func (p *PinkSlip) Cardinality() dynamo.Cardinality {
+//		return dynamo.OneToMany // a car has one owner
+//	}
+//
+// Link and TransactLink then fail with ErrCardinality rather than link an
+// entity again, and Relink replaces the entity's link instead. The limit is
+// enforced with conditional writes on a sentinel row per limited entity,
+// which records the entity's link, so concurrent Links cannot both succeed.
+// Unlink, TransactUnlink, DeleteCascade and SweepOrphanLinks delete the
+// sentinels of the links they delete.
+type CardinalityDeclarer interface {
+	Cardinality() Cardinality
+}
+
+// cardinalityOf returns the cardinality the link declares.
+func cardinalityOf(link types.Linkable) Cardinality {
+	if c, ok := link.(CardinalityDeclarer); ok {
+		return c.Cardinality()
+	}
+	return ManyToMany
+}
+
+// ErrCardinality is returned when a link would link an entity that may take
+// part in one link of the type only, and already does.
+type ErrCardinality struct {
+	LinkType    string
+	Cardinality Cardinality
+	// Slot is the slot of the entity, 0 for Entity0 and 1 for Entity1.
+	Slot int
+	// Entity is the stored key of the entity.
+	Entity EntityKey
+	// Link is the stored key of the link the entity takes part in.
+	Link EntityKey
+}
+
+func (e ErrCardinality) Error() string {
+	return fmt.Sprintf("%s links are %s, but Entity%d %s is already linked by %s", e.LinkType, e.Cardinality, e.Slot, e.Entity.Pk, e.Link.Pk)
+}
+
+const (
+	sentinelType   = "linkSentinel"
+	sentinelLinkPk = "linkpk"
+	sentinelLinkSk = "linksk"
+)
+
+// linkSentinel is the row recording the link an entity that may take part in
+// one link of a type only takes part in.
+type linkSentinel struct {
+	key    map[string]awstypes.AttributeValue
+	slot   int
+	entity EntityKey
+}
+
+// linkSentinels returns the sentinels of the entities of the link that may
+// take part in one link only. The link keys must have been generated.
+func linkSentinels(link types.Linkable, l linkSlots) ([]linkSentinel, error) {
+	var sentinels []linkSentinel
+	for _, slot := range cardinalityOf(link).uniqueSlots() {
+		if slot >= len(l.slots) {
+			continue
+		}
+		entity := EntityKey{Pk: *l.slots[slot].pk, Sk: *l.slots[slot].sk}
+		key, err := sentinelKey(link.Type(), slot, entity)
+		if err != nil {
+			return nil, err
+		}
+		sentinels = append(sentinels, linkSentinel{key: key, slot: slot, entity: entity})
+	}
+	return sentinels, nil
+}
+
+// sentinelKey returns the key of the sentinel of the entity in the slot of
+// links of the type, /cardinality(type)/e<N>pk(pk) and /e<N>sk(sk).
+func sentinelKey(linkType string, slot int, entity EntityKey) (map[string]awstypes.AttributeValue, error) {
+	_, pkLabel, skLabel := slotLabels(slot)
+	pk, err := addLinkKeySegment(cardinality, linkType)
+	if err != nil {
+		return nil, err
+	}
+	seg, err := addLinkKeySegment(pkLabel, entity.Pk)
+	if err != nil {
+		return nil, err
+	}
+	sk, err := addLinkKeySegment(skLabel, entity.Sk)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]awstypes.AttributeValue{
+		"pk": &awstypes.AttributeValueMemberS{Value: hashLongKey(pk+seg, maxPartitionKeyBytes)},
+		"sk": &awstypes.AttributeValueMemberS{Value: hashLongKey(sk, maxSortKeyBytes)},
+	}, nil
+}
+
+// sentinelLink returns the link a stored sentinel records.
+func sentinelLink(item map[string]awstypes.AttributeValue) (EntityKey, bool) {
+	pk, ok := item[sentinelLinkPk].(*awstypes.AttributeValueMemberS)
+	if !ok {
+		return EntityKey{}, false
+	}
+	sk, ok := item[sentinelLinkSk].(*awstypes.AttributeValueMemberS)
+	if !ok {
+		return EntityKey{}, false
+	}
+	return EntityKey{Pk: pk.Value, Sk: sk.Value}, true
+}
+
+// recordsLink is the condition that the sentinel records the link.
+func recordsLink(link EntityKey) Condition {
+	return And(Equal(sentinelLinkPk, link.Pk), Equal(sentinelLinkSk, link.Sk))
+}
+
+// claimSentinel puts the sentinel recording the link, on the condition that
+// the stored sentinel records the link expected, or, when expected is nil,
+// that there is none or it already records the link. Otherwise the
+// operation fails with ErrCardinality.
+func (t *Transaction) claimSentinel(s linkSentinel, linkType string, c Cardinality, link EntityKey, expected *EntityKey) *Transaction {
+	cond := Or(AttributeNotExists("pk"), recordsLink(link))
+	if expected != nil {
+		cond = recordsLink(*expected)
+	}
+	failed := func(current map[string]awstypes.AttributeValue) error {
+		existing, _ := sentinelLink(current)
+		return ErrCardinality{LinkType: linkType, Cardinality: c, Slot: s.slot, Entity: s.entity, Link: existing}
+	}
+	return t.add(transactOp{failed: failed, build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+		if tablename == "" {
+			tablename = clients.TableName(ctx)
+		}
+		expr, names, values, err := cond.Render()
+		if err != nil {
+			return awstypes.TransactWriteItem{}, err
+		}
+		item := map[string]awstypes.AttributeValue{
+			"type":         &awstypes.AttributeValueMemberS{Value: sentinelType},
+			sentinelLinkPk: &awstypes.AttributeValueMemberS{Value: link.Pk},
+			sentinelLinkSk: &awstypes.AttributeValueMemberS{Value: link.Sk},
+		}
+		for name, value := range s.key {
+			item[name] = value
+		}
+		return awstypes.TransactWriteItem{Put: &awstypes.Put{
+			TableName:                           aws.String(tablename),
+			Item:                                item,
+			ConditionExpression:                 aws.String(expr),
+			ExpressionAttributeNames:            names,
+			ExpressionAttributeValues:           values,
+			ReturnValuesOnConditionCheckFailure: awstypes.ReturnValuesOnConditionCheckFailureAllOld,
+		}}, nil
+	}})
+}
+
+// releaseSentinel deletes the sentinel on the condition that it records the
+// link.
+func (t *Transaction) releaseSentinel(key map[string]awstypes.AttributeValue, link EntityKey) *Transaction {
+	return t.add(transactOp{build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+		if tablename == "" {
+			tablename = clients.TableName(ctx)
+		}
+		expr, names, values, err := recordsLink(link).Render()
+		if err != nil {
+			return awstypes.TransactWriteItem{}, err
+		}
+		return awstypes.TransactWriteItem{Delete: &awstypes.Delete{
+			TableName:                 aws.String(tablename),
+			Key:                       key,
+			ConditionExpression:       aws.String(expr),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}}, nil
+	}})
+}
+
+// storedKey returns the key the row is stored with.
+func storedKey(row types.Linkable) (EntityKey, error) {
+	key, err := rowKey(row)
+	if err != nil {
+		return EntityKey{}, err
+	}
+	stored, _ := itemKey(key)
+	return stored, nil
+}
+
+// recordedSentinel is a stored sentinel and the link it records.
+type recordedSentinel struct {
+	key  map[string]awstypes.AttributeValue
+	link EntityKey
+}
+
+// recordedSentinels returns the sentinels that record the links, given their
+// items. The sentinels of Entity0 and Entity1 are read with batched reads,
+// whatever Cardinality the link types declare now.
+func (d *DBManager) recordedSentinels(ctx context.Context, links []map[string]awstypes.AttributeValue) ([]recordedSentinel, error) {
+	var candidates []recordedSentinel
+	for _, item := range links {
+		link, ok := itemKey(item)
+		linkType, isString := item["type"].(*awstypes.AttributeValueMemberS)
+		if !ok || !isString {
+			continue
+		}
+		for slot := 0; slot < 2; slot++ {
+			entity, ok := slotKey(item, slot)
+			if !ok {
+				continue
+			}
+			key, err := sentinelKey(linkType.Value, slot, entity)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, recordedSentinel{key: key, link: link})
+		}
+	}
+	rows := make([]types.Linkable, len(candidates))
+	for i := range rows {
+		rows[i] = &storedRow{}
+	}
+	results := d.batchGet(ctx, rows, func(i int) (map[string]awstypes.AttributeValue, error) {
+		return candidates[i].key, nil
+	})
+	var recorded []recordedSentinel
+	for i, result := range results {
+		if errors.As(result.Error, new(*ErrItemNotFound)) {
+			continue
+		}
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if link, ok := sentinelLink(getRowData(rows[i])); ok && link == candidates[i].link {
+			recorded = append(recorded, candidates[i])
+		}
+	}
+	return recorded, nil
+}
+
+// getItem returns the item stored with the key, or nil.
+func (d *DBManager) getItem(ctx context.Context, client *clients.Client, key map[string]awstypes.AttributeValue) (map[string]awstypes.AttributeValue, error) {
+	tn := d.TableName(ctx)
+	out, err := client.Dynamo().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &tn,
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Item, nil
+}
+
+// addSentinels adds the sentinel writes of a link write to the transaction.
+// A put claims the sentinels; a delete releases those that record the link,
+// so a link written before its type declared a cardinality can be deleted.
+func (d *DBManager) addSentinels(ctx context.Context, client *clients.Client, tx *Transaction, row types.Linkable, l linkSlots, release bool) error {
+	sentinels, err := linkSentinels(row, l)
+	if err != nil || len(sentinels) == 0 {
+		return err
+	}
+	link, err := storedKey(row)
+	if err != nil {
+		return err
+	}
+	for _, s := range sentinels {
+		if !release {
+			tx.claimSentinel(s, row.Type(), cardinalityOf(row), link, nil)
+			continue
+		}
+		item, err := d.getItem(ctx, client, s.key)
+		if err != nil {
+			return err
+		}
+		if recorded, ok := sentinelLink(item); ok && recorded == link {
+			tx.releaseSentinel(s.key, link)
+		}
+	}
+	return nil
+}
+
+// relink puts the link and replaces the links its entities that may take
+// part in one link only already take part in, in one transaction.
+func (d *DBManager) relink(ctx context.Context, row types.Linkable, l linkSlots) error {
+	if _, _, err := rowKeys(row, 0); err != nil {
+		return err
+	}
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+	sentinels, err := linkSentinels(row, l)
+	if err != nil {
+		return err
+	}
+	link, err := storedKey(row)
+	if err != nil {
+		return err
+	}
+	claimed := map[string]bool{}
+	for _, s := range sentinels {
+		claimed[keyID(s.key)] = true
+	}
+
+	tx := NewTransaction().Put(row)
+	replaced := map[EntityKey]bool{}
+	for _, s := range sentinels {
+		item, err := d.getItem(ctx, client, s.key)
+		if err != nil {
+			return err
+		}
+		existing, ok := sentinelLink(item)
+		if !ok || existing == link {
+			tx.claimSentinel(s, row.Type(), cardinalityOf(row), link, nil)
+			continue
+		}
+		tx.claimSentinel(s, row.Type(), cardinalityOf(row), link, &existing)
+		if replaced[existing] {
+			continue
+		}
+		replaced[existing] = true
+		tx.Delete(newStoredRow(existing))
+
+		// The other sentinels of the replaced link are released, unless the
+		// new link claims them.
+		old, err := d.getItem(ctx, client, map[string]awstypes.AttributeValue{
+			"pk": &awstypes.AttributeValueMemberS{Value: existing.Pk},
+			"sk": &awstypes.AttributeValueMemberS{Value: existing.Sk},
+		})
+		if err != nil {
+			return err
+		}
+		for _, slot := range cardinalityOf(row).uniqueSlots() {
+			entity, ok := slotKey(old, slot)
+			if !ok {
+				continue
+			}
+			other, err := sentinelKey(row.Type(), slot, entity)
+			if err != nil {
+				return err
+			}
+			if claimed[keyID(other)] {
+				continue
+			}
+			item, err := d.getItem(ctx, client, other)
+			if err != nil {
+				return err
+			}
+			if recorded, ok := sentinelLink(item); ok && recorded == existing {
+				tx.releaseSentinel(other, existing)
+			}
+		}
+	}
+	_, err = tx.WithClient(client).WithTableName(d.TableName(ctx)).Exec(ctx)
+	return err
+}
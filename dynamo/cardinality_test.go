@@ -0,0 +1,166 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/entegral/gobox/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Ownership links a car to its owner: a user owns many cars, a car has one
+// owner.
+type Ownership struct {
+	DiLink[*User, *Car]
+}
+
+func (t *Ownership) Type() string {
+	return "ownership"
+}
+
+func (t *Ownership) Cardinality() Cardinality {
+	return OneToMany
+}
+
+// Marriage links two users to each other only.
+type Marriage struct {
+	DiLink[*User, *User]
+}
+
+func (m *Marriage) Type() string {
+	return "marriage"
+}
+
+func (m *Marriage) Cardinality() Cardinality {
+	return OneToOne
+}
+
+// Seat gives a user one seat, a car on a date.
+type Seat struct {
+	TriLink[*User, *Car, *Date]
+}
+
+func (s *Seat) Type() string {
+	return "seat"
+}
+
+func (s *Seat) Cardinality() Cardinality {
+	return ManyToOne
+}
+
+func newOwnership(owner *User, car *Car) *Ownership {
+	return &Ownership{DiLink: *NewDiLink(owner, car)}
+}
+
+func TestCardinality(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	alice := &User{Email: "alice@example.com"}
+	bob := &User{Email: "bob@example.com"}
+	carol := &User{Email: "carol@example.com"}
+	dave := &User{Email: "dave@example.com"}
+	beetle := &Car{Make: "VW", Model: "Beetle", Year: 1970}
+	golf := &Car{Make: "VW", Model: "Golf", Year: 1990}
+	for _, row := range []types.Linkable{alice, bob, carol, dave, beetle, golf} {
+		require.NoError(t, (&DBManager{}).Put(ctx, row))
+	}
+
+	t.Run("rejects a second link of a limited entity", func(t *testing.T) {
+		title := newOwnership(alice, beetle)
+		require.NoError(t, title.Link(ctx, title))
+		require.NoError(t, title.Link(ctx, title), "linking again is idempotent")
+		other := newOwnership(alice, golf)
+		require.NoError(t, other.Link(ctx, other), "Entity0 may have many links")
+
+		stolen := newOwnership(bob, beetle)
+		err := stolen.Link(ctx, stolen)
+		var cardinalityErr ErrCardinality
+		require.True(t, errors.As(err, &cardinalityErr), err)
+		assert.Equal(t, 1, cardinalityErr.Slot)
+		assert.Equal(t, OneToMany, cardinalityErr.Cardinality)
+		key, err := storedKey(title)
+		require.NoError(t, err)
+		assert.Equal(t, key, cardinalityErr.Link)
+		assert.False(t, isStored(t, stolen))
+	})
+	t.Run("replaces the link of a limited entity", func(t *testing.T) {
+		sold := newOwnership(bob, beetle)
+		require.NoError(t, sold.Relink(ctx, sold))
+		assert.True(t, isStored(t, sold))
+		assert.False(t, isStored(t, newOwnership(alice, beetle)))
+
+		stolen := newOwnership(carol, beetle)
+		assert.True(t, errors.As(stolen.Link(ctx, stolen), new(ErrCardinality)))
+	})
+	t.Run("releases the entity on unlink", func(t *testing.T) {
+		sold := newOwnership(bob, beetle)
+		require.NoError(t, sold.Unlink(ctx, sold))
+		bought := newOwnership(carol, beetle)
+		require.NoError(t, bought.Link(ctx, bought))
+	})
+	t.Run("unlinks links written without a sentinel", func(t *testing.T) {
+		legacy := newOwnership(dave, &Car{Make: "VW", Model: "Polo", Year: 1980})
+		require.NoError(t, legacy.Put(ctx, legacy))
+		require.NoError(t, legacy.Unlink(ctx, legacy))
+		assert.False(t, isStored(t, legacy))
+	})
+	t.Run("limits both entities of one-to-one links", func(t *testing.T) {
+		m := &Marriage{DiLink: *NewDiLink(alice, bob)}
+		require.NoError(t, m.Link(ctx, m))
+		for _, pair := range [][2]*User{{alice, carol}, {carol, bob}} {
+			other := &Marriage{DiLink: *NewDiLink(pair[0], pair[1])}
+			assert.True(t, errors.As(other.Link(ctx, other), new(ErrCardinality)))
+		}
+
+		// Replacing alice's marriage also frees bob.
+		remarried := &Marriage{DiLink: *NewDiLink(alice, carol)}
+		require.NoError(t, remarried.Relink(ctx, remarried))
+		assert.False(t, isStored(t, m))
+		m = &Marriage{DiLink: *NewDiLink(dave, bob)}
+		require.NoError(t, m.Link(ctx, m))
+	})
+	t.Run("limits the entities of a TriLink", func(t *testing.T) {
+		date := &Date{}
+		seat := &Seat{TriLink: *NewTriLink(dave, golf, date)}
+		require.NoError(t, seat.Link(ctx, seat))
+		other := &Seat{TriLink: *NewTriLink(dave, beetle, date)}
+		assert.True(t, errors.As(other.Link(ctx, other), new(ErrCardinality)))
+		require.NoError(t, other.Relink(ctx, other))
+		assert.False(t, isStored(t, seat))
+	})
+	t.Run("lets one of concurrent links win", func(t *testing.T) {
+		car := &Car{Make: "VW", Model: "Passat", Year: 2000}
+		require.NoError(t, car.Put(ctx, car))
+		var wg sync.WaitGroup
+		errs := make([]error, 10)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				owner := &User{Email: fmt.Sprintf("buyer%d@example.com", i)}
+				title := newOwnership(owner, car)
+				errs[i] = title.Link(ctx, title)
+			}(i)
+		}
+		wg.Wait()
+		won := 0
+		for _, err := range errs {
+			if err == nil {
+				won++
+			} else {
+				assert.True(t, errors.As(err, new(ErrCardinality)), err)
+			}
+		}
+		assert.Equal(t, 1, won)
+	})
+	t.Run("releases the entity on a cascading delete", func(t *testing.T) {
+		require.NoError(t, alice.DeleteCascade(ctx, alice))
+		bought := newOwnership(carol, golf)
+		require.NoError(t, bought.Link(ctx, bought))
+	})
+}
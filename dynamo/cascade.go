@@ -0,0 +1,321 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+)
+
+// cascadeTransactLimit is the most links and sentinels DeleteCascade deletes
+// in the same transaction as the row, which TransactWriteItems limits to 100
+// items.
+const cascadeTransactLimit = 99
+
+// storedRow is a row of any type, known only by the key it is stored with.
+// It is used to get and delete the links and entities that are found by
+// their stored items rather than loaded into their own types.
+type storedRow struct {
+	Row
+	stored EntityKey
+}
+
+func newStoredRow(key EntityKey) *storedRow {
+	row := &storedRow{stored: key}
+	row.UnmarshalledType, _ = storedRowType(key.Pk)
+	return row
+}
+
+func (s *storedRow) Keys(gsi int) (string, string, error) {
+	pk, ok := storedRowPk(s.stored.Pk)
+	if !ok || s.UnmarshalledType == "" {
+		return "", "", fmt.Errorf("%q is not a stored key", s.stored.Pk)
+	}
+	return pk, s.stored.Sk, nil
+}
+
+// itemKey returns the primary key of a stored item.
+func itemKey(item map[string]awstypes.AttributeValue) (EntityKey, bool) {
+	pk, ok := item["pk"].(*awstypes.AttributeValueMemberS)
+	if !ok {
+		return EntityKey{}, false
+	}
+	sk, ok := item["sk"].(*awstypes.AttributeValueMemberS)
+	if !ok {
+		return EntityKey{}, false
+	}
+	return EntityKey{Pk: pk.Value, Sk: sk.Value}, true
+}
+
+// linksReferencing returns the items of the links of every type whose
+// entity in one of the slots is the row, each link once. The first three
+// slots are always searched.
+func linksReferencing(ctx context.Context, client *clients.Client, row types.Linkable, slots []LinkSlot) ([]map[string]awstypes.AttributeValue, error) {
+	_, sk, err := rowKeys(row, 0)
+	if err != nil {
+		return nil, err
+	}
+	slots = append([]LinkSlot{EntitySlot(0), EntitySlot(1), EntitySlot(2)}, slots...)
+	var links []map[string]awstypes.AttributeValue
+	seen := map[EntityKey]bool{}
+	for _, slot := range slots {
+		input, err := entityGSIQueryInput(ctx, row, slot, "")
+		it := newIterator[map[string]awstypes.AttributeValue](client, input, err)
+		it.decode = func(item map[string]awstypes.AttributeValue) (map[string]awstypes.AttributeValue, error) {
+			return item, nil
+		}
+		items, err := it.All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			// The GSI is queried with begins_with on the sort key, which
+			// also matches entities whose sort key only starts with the row's.
+			entity, ok := slotKey(item, slot.Index)
+			if !ok || entity.Sk != sk {
+				continue
+			}
+			link, ok := itemKey(item)
+			if ok && !seen[link] {
+				seen[link] = true
+				links = append(links, item)
+			}
+		}
+	}
+	return links, nil
+}
+
+// DeleteCascade deletes the row together with every link that references it,
+// whatever the link's type. The links are found on the GSIs of the first
+// three entity slots, and of any other slots passed, such as those of a
+// MultiLink. The sentinels that record the links for their Cardinality are
+// deleted with them.
+//
+// When fewer than 100 links and sentinels are deleted, they are deleted in
+// one transaction with the row. Otherwise they are deleted with batched
+// writes and the row is only deleted once all of them are; if some fail,
+// the error joins a ResultError for each and DeleteCascade can be called
+// again. Links written while a cascade runs are not deleted;
+// SweepOrphanLinks finds them.
+//
+// The links are deleted by key, so their own lifecycle hooks do not run.
+func (d *DBManager) DeleteCascade(ctx context.Context, row types.Linkable, slots ...LinkSlot) error {
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+	links, err := linksReferencing(ctx, client, row, slots)
+	if err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		d.DeleteItemOutput, err = d.deleteItemPrependTypeWithClient(ctx, client, row)
+		return err
+	}
+	sentinels, err := d.recordedSentinels(ctx, links)
+	if err != nil {
+		return err
+	}
+	if len(links)+len(sentinels) <= cascadeTransactLimit {
+		tx := NewTransaction().Delete(row)
+		for _, link := range links {
+			key, _ := itemKey(link)
+			tx.Delete(newStoredRow(key))
+		}
+		for _, s := range sentinels {
+			tx.releaseSentinel(s.key, s.link)
+		}
+		_, err = tx.WithClient(client).WithTableName(d.TableName(ctx)).Exec(ctx)
+		return err
+	}
+	if err := d.deleteLinks(ctx, links, sentinels); err != nil {
+		return err
+	}
+	d.DeleteItemOutput, err = d.deleteItemPrependTypeWithClient(ctx, client, row)
+	return err
+}
+
+// deleteLinks deletes the links, given their items, and the sentinels that
+// record them in batches.
+func (d *DBManager) deleteLinks(ctx context.Context, links []map[string]awstypes.AttributeValue, sentinels []recordedSentinel) error {
+	keys := make([]map[string]awstypes.AttributeValue, 0, len(links)+len(sentinels))
+	for _, link := range links {
+		key, _ := itemKey(link)
+		keys = append(keys, map[string]awstypes.AttributeValue{
+			"pk": &awstypes.AttributeValueMemberS{Value: key.Pk},
+			"sk": &awstypes.AttributeValueMemberS{Value: key.Sk},
+		})
+	}
+	for _, s := range sentinels {
+		keys = append(keys, s.key)
+	}
+	rows := make([]types.Linkable, len(keys))
+	for i := range rows {
+		rows[i] = &storedRow{}
+	}
+	var errs []error
+	results := d.batchDelete(ctx, rows, func(i int) (map[string]awstypes.AttributeValue, error) {
+		return keys[i], nil
+	})
+	for i, result := range results {
+		if result.Error != nil {
+			errs = append(errs, ResultError{Index: i, Err: result.Error})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OrphanLink is a stored link one or more of whose entities no longer exist.
+type OrphanLink struct {
+	// Key is the key the link is stored with.
+	Key EntityKey
+	// Missing are the stored keys of the entities that do not exist, by
+	// slot.
+	Missing map[int]EntityKey
+
+	item map[string]awstypes.AttributeValue
+}
+
+// FindOrphanLinks returns the links of the link's type one or more of whose
+// entities no longer exist. Every link of the type is read, from each shard
+// of the pkshard-index GSI, and the entities are checked with batched reads,
+// so it is meant to run as a periodic sweep rather than on a request path.
+// The shards are those Put writes for the link's type and MaxShard; a link
+// that sets its own pkshard fails with ErrCustomShard, as its links cannot
+// all be found.
+func (d *DBManager) FindOrphanLinks(ctx context.Context, link types.Linkable) ([]OrphanLink, error) {
+	var orphans []OrphanLink
+	err := d.eachTypeShard(ctx, link, func(items []map[string]awstypes.AttributeValue) error {
+		found, err := d.orphans(ctx, items)
+		orphans = append(orphans, found...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orphans, nil
+}
+
+// eachTypeShard reads the items of the rows of the row's type from each
+// shard of the pkshard-index GSI, and calls fn with the items of each shard.
+// The shards are those returned by typeShards.
+func (d *DBManager) eachTypeShard(ctx context.Context, row types.Linkable, fn func(items []map[string]awstypes.AttributeValue) error) error {
+	shards, err := typeShards(row)
+	if err != nil {
+		return err
+	}
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+	tn := d.TableName(ctx)
+	for _, shard := range shards {
+		kce := "pkshard = :pkshard"
+		it := newIterator[map[string]awstypes.AttributeValue](client, &dynamodb.QueryInput{
+			TableName:              &tn,
+			IndexName:              aws.String("pkshard-index"),
+			KeyConditionExpression: &kce,
+			FilterExpression:       aws.String("#type = :type"),
+			ExpressionAttributeNames: map[string]string{
+				"#type": "type",
+			},
+			ExpressionAttributeValues: map[string]awstypes.AttributeValue{
+				":pkshard": &awstypes.AttributeValueMemberS{Value: shard},
+				":type":    &awstypes.AttributeValueMemberS{Value: row.Type()},
+			},
+		}, nil)
+		it.decode = func(item map[string]awstypes.AttributeValue) (map[string]awstypes.AttributeValue, error) {
+			return item, nil
+		}
+		items, err := it.All(ctx)
+		if err != nil {
+			return err
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orphans returns the links among the items one or more of whose entities
+// do not exist.
+func (d *DBManager) orphans(ctx context.Context, items []map[string]awstypes.AttributeValue) ([]OrphanLink, error) {
+	var entities []EntityKey
+	seen := map[EntityKey]bool{}
+	for _, item := range items {
+		for i := 0; ; i++ {
+			key, ok := slotKey(item, i)
+			if !ok {
+				break
+			}
+			if !seen[key] {
+				seen[key] = true
+				entities = append(entities, key)
+			}
+		}
+	}
+	rows := make([]types.Linkable, len(entities))
+	for i, key := range entities {
+		rows[i] = newStoredRow(key)
+	}
+	exists := make(map[EntityKey]bool, len(entities))
+	for i, result := range d.BatchGetItems(ctx, rows) {
+		if errors.As(result.Error, new(*ErrItemNotFound)) {
+			continue
+		}
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		exists[entities[i]] = true
+	}
+	var orphans []OrphanLink
+	for _, item := range items {
+		link, ok := itemKey(item)
+		if !ok {
+			continue
+		}
+		orphan := OrphanLink{Key: link, item: item}
+		for i := 0; ; i++ {
+			key, ok := slotKey(item, i)
+			if !ok {
+				break
+			}
+			if !exists[key] {
+				if orphan.Missing == nil {
+					orphan.Missing = map[int]EntityKey{}
+				}
+				orphan.Missing[i] = key
+			}
+		}
+		if orphan.Missing != nil {
+			orphans = append(orphans, orphan)
+		}
+	}
+	return orphans, nil
+}
+
+// SweepOrphanLinks deletes the links of the link's type one or more of whose
+// entities no longer exist, as found by FindOrphanLinks, and the sentinels
+// that record them, with batched writes. It returns the links it found; if
+// some could not be deleted, the error joins a ResultError for each.
+func (d *DBManager) SweepOrphanLinks(ctx context.Context, link types.Linkable) ([]OrphanLink, error) {
+	orphans, err := d.FindOrphanLinks(ctx, link)
+	if err != nil || len(orphans) == 0 {
+		return orphans, err
+	}
+	items := make([]map[string]awstypes.AttributeValue, len(orphans))
+	for i, orphan := range orphans {
+		items[i] = orphan.item
+	}
+	sentinels, err := d.recordedSentinels(ctx, items)
+	if err != nil {
+		return orphans, err
+	}
+	return orphans, d.deleteLinks(ctx, items, sentinels)
+}
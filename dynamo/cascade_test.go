@@ -0,0 +1,139 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isStored reports whether the row exists in the table.
+func isStored(t *testing.T, row types.Linkable) bool {
+	t.Helper()
+	_, err := (&DBManager{}).GetItem(context.Background(), row)
+	if _, ok := err.(*ErrItemNotFound); ok {
+		return false
+	}
+	require.NoError(t, err)
+	return true
+}
+
+func TestDeleteCascade(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	user := &User{Email: "cascade@example.com"}
+	acme, initech := &Org{Name: "acme"}, &Org{Name: "initech"}
+	admin := &Role{Name: "admin"}
+	for _, row := range []types.Linkable{user, acme, initech, admin} {
+		require.NoError(t, (&DBManager{}).Put(ctx, row))
+	}
+
+	t.Run("deletes the links of every type in one transaction", func(t *testing.T) {
+		atAcme := &Grant{TriLink: *NewTriLink(user, acme, admin)}
+		atInitech := &Grant{TriLink: *NewTriLink(user, initech, admin)}
+		membership := &Membership{MultiLink: *NewMultiLink(user, acme)}
+		require.NoError(t, atAcme.Link(ctx, atAcme))
+		require.NoError(t, atInitech.Link(ctx, atInitech))
+		require.NoError(t, membership.Link(ctx, membership))
+
+		require.NoError(t, acme.DeleteCascade(ctx, acme))
+		assert.False(t, isStored(t, acme))
+		assert.False(t, isStored(t, atAcme))
+		assert.False(t, isStored(t, membership))
+		assert.True(t, isStored(t, atInitech))
+	})
+	t.Run("searches the slots passed", func(t *testing.T) {
+		rockets := &Project{Org: "initech", Name: "rockets"}
+		rockets2 := &Project{Org: "initech", Name: "rockets2"}
+		require.NoError(t, rockets.Put(ctx, rockets))
+		require.NoError(t, rockets2.Put(ctx, rockets2))
+		m1 := &Membership{MultiLink: *NewMultiLink(user, initech, rockets, admin)}
+		m2 := &Membership{MultiLink: *NewMultiLink(user, initech, rockets2)}
+		require.NoError(t, m1.Link(ctx, m1))
+		require.NoError(t, m2.Link(ctx, m2))
+
+		// The GSI of slot 2 also matches rockets2, whose sort key starts
+		// with rockets'.
+		require.NoError(t, rockets.DeleteCascade(ctx, rockets))
+		assert.False(t, isStored(t, m1))
+		assert.True(t, isStored(t, m2))
+
+		m3 := &Membership{MultiLink: *NewMultiLink(user, initech, rockets2, admin)}
+		require.NoError(t, m3.Link(ctx, m3))
+		require.NoError(t, admin.DeleteCascade(ctx, admin, EntitySlot(3)))
+		assert.False(t, isStored(t, admin))
+		assert.False(t, isStored(t, m3))
+		assert.True(t, isStored(t, m2))
+	})
+	t.Run("deletes large fan-outs in batches", func(t *testing.T) {
+		viewer := &Role{Name: "viewer"}
+		require.NoError(t, viewer.Put(ctx, viewer))
+		var grants []*Grant
+		for i := 0; i < cascadeTransactLimit+21; i++ {
+			u := &User{Email: fmt.Sprintf("viewer%d@example.com", i)}
+			g := &Grant{TriLink: *NewTriLink(u, initech, viewer)}
+			require.NoError(t, g.Link(ctx, g))
+			grants = append(grants, g)
+		}
+		require.NoError(t, viewer.DeleteCascade(ctx, viewer))
+		assert.False(t, isStored(t, viewer))
+		for _, g := range grants {
+			assert.False(t, isStored(t, g))
+		}
+		assert.True(t, isStored(t, initech))
+	})
+}
+
+func TestSweepOrphanLinks(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	alice, bob := &User{Email: "alice@example.com"}, &User{Email: "bob@example.com"}
+	acme, admin := &Org{Name: "acme"}, &Role{Name: "admin"}
+	for _, row := range []types.Linkable{alice, bob, acme, admin} {
+		require.NoError(t, (&DBManager{}).Put(ctx, row))
+	}
+	kept := &Grant{TriLink: *NewTriLink(alice, acme, admin)}
+	orphaned := &Grant{TriLink: *NewTriLink(bob, acme, admin)}
+	require.NoError(t, kept.Link(ctx, kept))
+	require.NoError(t, orphaned.Link(ctx, orphaned))
+	require.NoError(t, bob.Delete(ctx, bob))
+
+	orphans, err := (&DBManager{}).FindOrphanLinks(ctx, &Grant{})
+	require.NoError(t, err)
+	require.Len(t, orphans, 1)
+	key, err := rowKey(orphaned)
+	require.NoError(t, err)
+	assert.Equal(t, key["pk"].(*awstypes.AttributeValueMemberS).Value, orphans[0].Key.Pk)
+	assert.Equal(t, map[int]EntityKey{0: {Pk: orphaned.E0pk, Sk: orphaned.E0sk}}, orphans[0].Missing)
+	assert.True(t, isStored(t, orphaned))
+
+	swept, err := (&DBManager{}).SweepOrphanLinks(ctx, &Grant{})
+	require.NoError(t, err)
+	assert.Equal(t, orphans, swept)
+	assert.False(t, isStored(t, orphaned))
+	assert.True(t, isStored(t, kept))
+
+	orphans, err = (&DBManager{}).FindOrphanLinks(ctx, &Grant{})
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+
+	t.Run("reads the shards put writes", func(t *testing.T) {
+		out, err := (&DBManager{}).GetItem(ctx, kept)
+		require.NoError(t, err)
+		shards, err := typeShards(&Grant{})
+		require.NoError(t, err)
+		assert.Contains(t, shards, out.Item["pkshard"].(*awstypes.AttributeValueMemberS).Value)
+	})
+	t.Run("rejects a link that sets its own shard", func(t *testing.T) {
+		custom := &Grant{}
+		custom.PkShard = "grants"
+		_, err := (&DBManager{}).FindOrphanLinks(ctx, custom)
+		assert.Equal(t, ErrCustomShard{Type: "grant", Shard: "grants"}, err)
+	})
+}
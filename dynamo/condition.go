@@ -0,0 +1,213 @@
+package dynamo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Condition is a condition expression for a conditional write. Build one
+// with the functions below rather than by hand: attribute names and values
+// are always passed as expression attribute placeholders, so they never
+// collide with reserved words or need escaping.
+//
+//	cond := dynamo.And(
+//		dynamo.AttributeExists("Email"),
+//		dynamo.NotEqual("Status", "banned"),
+//	)
+//
+// Attribute names may be document paths: dots separate the names of nested
+// map attributes and [n] indexes into a list, as in "Details.tags[0]".
+// Values are marshalled with attributevalue.Marshal, unless they already are
+// an AttributeValue.
+type Condition struct {
+	build func(e *expression) string
+}
+
+// IsZero reports whether the condition is empty.
+func (c Condition) IsZero() bool {
+	return c.build == nil
+}
+
+// Render returns the condition expression with its expression attribute
+// names and values.
+func (c Condition) Render() (string, map[string]string, map[string]awstypes.AttributeValue, error) {
+	if c.IsZero() {
+		return "", nil, nil, nil
+	}
+	e := newExpression("C")
+	expr := c.build(e)
+	if e.err != nil {
+		return "", nil, nil, e.err
+	}
+	return expr, e.names, e.values, nil
+}
+
+func comparison(name, op string, value any) Condition {
+	return Condition{build: func(e *expression) string {
+		return fmt.Sprintf("%s %s %s", e.name(name), op, e.value(value))
+	}}
+}
+
+func function(fn, name string, args ...any) Condition {
+	return Condition{build: func(e *expression) string {
+		operands := []string{e.name(name)}
+		for _, arg := range args {
+			operands = append(operands, e.value(arg))
+		}
+		return fmt.Sprintf("%s(%s)", fn, strings.Join(operands, ", "))
+	}}
+}
+
+// Equal holds when the attribute equals the value.
+func Equal(name string, value any) Condition { return comparison(name, "=", value) }
+
+// NotEqual holds when the attribute does not equal the value, including
+// when the attribute does not exist.
+func NotEqual(name string, value any) Condition { return comparison(name, "<>", value) }
+
+// LessThan holds when the attribute sorts before the value.
+func LessThan(name string, value any) Condition { return comparison(name, "<", value) }
+
+// LessThanOrEqual holds when the attribute does not sort after the value.
+func LessThanOrEqual(name string, value any) Condition { return comparison(name, "<=", value) }
+
+// GreaterThan holds when the attribute sorts after the value.
+func GreaterThan(name string, value any) Condition { return comparison(name, ">", value) }
+
+// GreaterThanOrEqual holds when the attribute does not sort before the value.
+func GreaterThanOrEqual(name string, value any) Condition { return comparison(name, ">=", value) }
+
+// Between holds when the attribute is between low and high, inclusive.
+func Between(name string, low, high any) Condition {
+	return Condition{build: func(e *expression) string {
+		return fmt.Sprintf("%s BETWEEN %s AND %s", e.name(name), e.value(low), e.value(high))
+	}}
+}
+
+// In holds when the attribute equals one of the values.
+func In(name string, values ...any) Condition {
+	return Condition{build: func(e *expression) string {
+		placeholders := make([]string, len(values))
+		for i, v := range values {
+			placeholders[i] = e.value(v)
+		}
+		return fmt.Sprintf("%s IN (%s)", e.name(name), strings.Join(placeholders, ", "))
+	}}
+}
+
+// BeginsWith holds when the string attribute begins with the prefix.
+func BeginsWith(name, prefix string) Condition { return function("begins_with", name, prefix) }
+
+// Contains holds when the string attribute contains the substring, or the
+// set or list attribute contains the value.
+func Contains(name string, value any) Condition { return function("contains", name, value) }
+
+// AttributeExists holds when the item has the attribute.
+func AttributeExists(name string) Condition { return function("attribute_exists", name) }
+
+// AttributeNotExists holds when the item does not have the attribute. On the
+// pk attribute, it holds when the item does not exist.
+func AttributeNotExists(name string) Condition { return function("attribute_not_exists", name) }
+
+// And holds when every condition holds. Empty conditions are ignored.
+func And(conditions ...Condition) Condition { return join("AND", conditions) }
+
+// Or holds when any condition holds. Empty conditions are ignored.
+func Or(conditions ...Condition) Condition { return join("OR", conditions) }
+
+// Not holds when the condition does not.
+func Not(condition Condition) Condition {
+	if condition.IsZero() {
+		return condition
+	}
+	return Condition{build: func(e *expression) string {
+		return fmt.Sprintf("NOT (%s)", condition.build(e))
+	}}
+}
+
+func join(op string, conditions []Condition) Condition {
+	var nonEmpty []Condition
+	for _, c := range conditions {
+		if !c.IsZero() {
+			nonEmpty = append(nonEmpty, c)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return Condition{}
+	case 1:
+		return nonEmpty[0]
+	}
+	return Condition{build: func(e *expression) string {
+		parts := make([]string, len(nonEmpty))
+		for i, c := range nonEmpty {
+			parts[i] = "(" + c.build(e) + ")"
+		}
+		return strings.Join(parts, " "+op+" ")
+	}}
+}
+
+// expression collects the expression attribute names and values of an
+// expression. Placeholders are numbered and prefixed with "gb" and the
+// expression's prefix, so expressions with different prefixes can share one
+// request.
+type expression struct {
+	prefix string
+	names  map[string]string
+	values map[string]awstypes.AttributeValue
+	err    error
+}
+
+func newExpression(prefix string) *expression {
+	return &expression{
+		prefix: prefix,
+		names:  map[string]string{},
+		values: map[string]awstypes.AttributeValue{},
+	}
+}
+
+// name returns the placeholder path for the attribute path. Each attribute
+// name gets a single placeholder however often it is used.
+func (e *expression) name(path string) string {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		index := ""
+		if j := strings.IndexByte(part, '['); j > 0 {
+			part, index = part[:j], part[j:]
+		}
+		if part == "" && e.err == nil {
+			e.err = fmt.Errorf("invalid attribute path %q", path)
+		}
+		placeholder := ""
+		for p, name := range e.names {
+			if name == part {
+				placeholder = p
+				break
+			}
+		}
+		if placeholder == "" {
+			placeholder = "#gb" + e.prefix + strconv.Itoa(len(e.names))
+			e.names[placeholder] = part
+		}
+		parts[i] = placeholder + index
+	}
+	return strings.Join(parts, ".")
+}
+
+// value returns a new placeholder for the value.
+func (e *expression) value(v any) string {
+	av, ok := v.(awstypes.AttributeValue)
+	if !ok {
+		var err error
+		if av, err = attributevalue.Marshal(v); err != nil && e.err == nil {
+			e.err = err
+		}
+	}
+	placeholder := ":gb" + e.prefix + strconv.Itoa(len(e.values))
+	e.values[placeholder] = av
+	return placeholder
+}
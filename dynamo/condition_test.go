@@ -0,0 +1,41 @@
+package dynamo
+
+import (
+	"testing"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCondition(t *testing.T) {
+	t.Run("names and values become placeholders", func(t *testing.T) {
+		expr, names, values, err := And(
+			AttributeExists("Email"),
+			Or(Equal("Age", 30), Between("Age", 40, 50)),
+			Not(In("Status", "banned", "deleted")),
+			BeginsWith("Details.tags[0]", "vip"),
+		).Render()
+		require.NoError(t, err)
+		assert.Equal(t, "(attribute_exists(#gbC0)) AND ((#gbC1 = :gbC0) OR (#gbC1 BETWEEN :gbC1 AND :gbC2)) AND (NOT (#gbC2 IN (:gbC3, :gbC4))) AND (begins_with(#gbC3.#gbC4[0], :gbC5))", expr)
+		assert.Equal(t, map[string]string{"#gbC0": "Email", "#gbC1": "Age", "#gbC2": "Status", "#gbC3": "Details", "#gbC4": "tags"}, names)
+		assert.Equal(t, &awstypes.AttributeValueMemberN{Value: "30"}, values[":gbC0"])
+		assert.Equal(t, &awstypes.AttributeValueMemberS{Value: "vip"}, values[":gbC5"])
+	})
+	t.Run("empty conditions are ignored", func(t *testing.T) {
+		assert.True(t, And().IsZero())
+		expr, _, _, err := And(Condition{}, AttributeNotExists("pk")).Render()
+		require.NoError(t, err)
+		assert.Equal(t, "attribute_not_exists(#gbC0)", expr)
+	})
+	t.Run("attribute values are used as is", func(t *testing.T) {
+		av := &awstypes.AttributeValueMemberSS{Value: []string{"a"}}
+		_, _, values, err := Contains("Tags", av).Render()
+		require.NoError(t, err)
+		assert.Same(t, av, values[":gbC0"])
+	})
+	t.Run("invalid paths are an error", func(t *testing.T) {
+		_, _, _, err := Equal("Details..color", "red").Render()
+		assert.Error(t, err)
+	})
+}
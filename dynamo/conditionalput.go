@@ -0,0 +1,77 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/entegral/gobox/keys"
+	"github.com/entegral/gobox/types"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrAlreadyExists is returned by Create when an item with the row's key
+// already exists. Current is the stored item.
+type ErrAlreadyExists struct {
+	Row     types.Linkable
+	Current map[string]awstypes.AttributeValue
+}
+
+func (e ErrAlreadyExists) Error() string {
+	return fmt.Sprintf("%s already exists", e.Row.Type())
+}
+
+// ErrConditionFailed is returned by PutIf when the condition does not hold.
+// Current is the stored item, or nil if the item does not exist.
+type ErrConditionFailed struct {
+	Row     types.Linkable
+	Current map[string]awstypes.AttributeValue
+}
+
+func (e ErrConditionFailed) Error() string {
+	return fmt.Sprintf("condition failed for %s", e.Row.Type())
+}
+
+// Create puts the row only if no item with its key exists yet, returning
+// ErrAlreadyExists otherwise. Use it to insert rows that must be unique,
+// like a user signing up with an email address. On links it creates the
+// link only if it does not exist.
+func (d *DBManager) Create(ctx context.Context, row types.Linkable) (err error) {
+	d.PutItemOutput, err = d.putIf(ctx, row, AttributeNotExists(keys.PkKey), func(current map[string]awstypes.AttributeValue) error {
+		if current == nil {
+			return nil
+		}
+		return ErrAlreadyExists{Row: row, Current: current}
+	})
+	return err
+}
+
+// Replace puts the row only if an item with its key already exists,
+// returning an ErrItemNotFound otherwise.
+func (d *DBManager) Replace(ctx context.Context, row types.Linkable) (err error) {
+	d.PutItemOutput, err = d.putIf(ctx, row, AttributeExists(keys.PkKey), func(current map[string]awstypes.AttributeValue) error {
+		if current != nil {
+			return nil
+		}
+		return &ErrItemNotFound{Row: row}
+	})
+	return err
+}
+
+// PutIf puts the row only if the condition holds for the stored item,
+// returning ErrConditionFailed otherwise:
+//
+//	err := user.PutIf(ctx, user, dynamo.NotEqual("Status", "banned"))
+func (d *DBManager) PutIf(ctx context.Context, row types.Linkable, cond Condition) (err error) {
+	d.PutItemOutput, err = d.putIf(ctx, row, cond, nil)
+	return err
+}
+
+func (d *DBManager) putIf(ctx context.Context, row types.Linkable, cond Condition, failed func(current map[string]awstypes.AttributeValue) error) (*dynamodb.PutItemOutput, error) {
+	client, err := d.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.putItemIfWithClient(ctx, client, row, cond, failed)
+}
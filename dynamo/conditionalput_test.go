@@ -0,0 +1,69 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalPut(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	t.Run("Create inserts only if absent", func(t *testing.T) {
+		user := &User{Email: "signup@gmail.com", Name: "First"}
+		require.NoError(t, user.Create(ctx, user))
+
+		again := &User{Email: "signup@gmail.com", Name: "Second"}
+		err := again.Create(ctx, again)
+		var exists ErrAlreadyExists
+		require.True(t, errors.As(err, &exists))
+		assert.NotNil(t, exists.Current)
+
+		loaded := CreateUser(user.Email)
+		_, err = loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, "First", loaded.Name)
+	})
+	t.Run("Replace writes only if present", func(t *testing.T) {
+		missing := &User{Email: "nobody@gmail.com"}
+		err := missing.Replace(ctx, missing)
+		assert.IsType(t, &ErrItemNotFound{}, err)
+
+		user := &User{Email: "signup@gmail.com", Name: "Edited"}
+		require.NoError(t, user.Replace(ctx, user))
+		assert.NotEmpty(t, user.OldPutValues())
+	})
+	t.Run("PutIf evaluates the condition", func(t *testing.T) {
+		user := &User{Email: "signup@gmail.com", Name: "Older", Age: 50}
+		err := user.PutIf(ctx, user, Equal("Name", "First"))
+		var failed ErrConditionFailed
+		require.True(t, errors.As(err, &failed))
+		assert.NotNil(t, failed.Current)
+
+		require.NoError(t, user.PutIf(ctx, user, Equal("Name", "Edited")))
+	})
+	t.Run("links can be created once", func(t *testing.T) {
+		user := &User{Email: "signup@gmail.com"}
+		car := &Car{Make: "CondMake", Model: "CondModel", Year: 2001}
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car), VIN: "1"}
+		require.NoError(t, slip.Create(ctx, slip))
+		err := slip.Create(ctx, slip)
+		assert.True(t, errors.As(err, new(ErrAlreadyExists)))
+	})
+	t.Run("versioned rows report the mode error first", func(t *testing.T) {
+		account := &Account{ID: "cond"}
+		require.NoError(t, account.Create(ctx, account))
+		assert.Equal(t, int64(1), account.GetVersion())
+		err := account.Create(ctx, account)
+		assert.True(t, errors.As(err, new(ErrAlreadyExists)))
+
+		stale := &Account{ID: "cond"}
+		stale.SetVersion(7)
+		err = stale.Replace(ctx, stale)
+		assert.True(t, errors.As(err, new(ErrVersionConflict)))
+	})
+}
@@ -25,12 +25,17 @@ var validLabels = []linkLabels{
 	entity1pk,
 	entity1sk,
 	entity1Type,
+	keyHash,
+	cardinality,
 }
 
 func (ll linkLabels) IsValidLabel() bool {
 	if ll == "" {
 		return false
 	}
+	if _, ok := slotLabelIndex(ll.String()); ok {
+		return true
+	}
 	for _, label := range validLabels {
 		if label.String() == ll.String() {
 			return true
@@ -45,6 +50,9 @@ func (ll linkLabels) IsValidValue(value string) error {
 			return errors.New("value must not match any linkLabel")
 		}
 	}
+	if _, ok := slotLabelIndex(value); ok {
+		return errors.New("value must not match any linkLabel")
+	}
 	return nil
 }
 
@@ -68,6 +76,11 @@ const (
 	rowType     linkLabels = "rowType"
 	rowPk       linkLabels = "rowPk"
 	rowSk       linkLabels = "rowSk"
+
+	// keyHash labels the hashed form of a link key too long to be stored.
+	keyHash linkLabels = "keyHash"
+	// cardinality labels the link type in the key of a link sentinel.
+	cardinality linkLabels = "cardinality"
 )
 
 const (
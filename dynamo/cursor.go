@@ -0,0 +1,87 @@
+package dynamo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// cursorValue is the JSON form of a key attribute. Key attributes can only
+// be strings, numbers or binary.
+type cursorValue struct {
+	S *string `json:"s,omitempty"`
+	N *string `json:"n,omitempty"`
+	B []byte  `json:"b,omitempty"`
+}
+
+// ErrInvalidCursor is returned when a cursor token cannot be decoded.
+type ErrInvalidCursor struct {
+	Cursor string
+	Err    error
+}
+
+func (e ErrInvalidCursor) Error() string {
+	return fmt.Sprintf("invalid cursor %q: %v", e.Cursor, e.Err)
+}
+
+func (e ErrInvalidCursor) Unwrap() error {
+	return e.Err
+}
+
+// EncodeCursor encodes a LastEvaluatedKey as an opaque, URL-safe token. An
+// empty key encodes to the empty string, which marks the last page.
+func EncodeCursor(key map[string]awstypes.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	values := make(map[string]cursorValue, len(key))
+	for name, av := range key {
+		switch v := av.(type) {
+		case *awstypes.AttributeValueMemberS:
+			values[name] = cursorValue{S: &v.Value}
+		case *awstypes.AttributeValueMemberN:
+			values[name] = cursorValue{N: &v.Value}
+		case *awstypes.AttributeValueMemberB:
+			values[name] = cursorValue{B: v.Value}
+		default:
+			return "", fmt.Errorf("unsupported key attribute type %T for %s", av, name)
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a token produced by EncodeCursor back into an
+// ExclusiveStartKey. The empty string decodes to a nil key.
+func DecodeCursor(cursor string) (map[string]awstypes.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor{Cursor: cursor, Err: err}
+	}
+	var values map[string]cursorValue
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, ErrInvalidCursor{Cursor: cursor, Err: err}
+	}
+	key := make(map[string]awstypes.AttributeValue, len(values))
+	for name, v := range values {
+		switch {
+		case v.S != nil:
+			key[name] = &awstypes.AttributeValueMemberS{Value: *v.S}
+		case v.N != nil:
+			key[name] = &awstypes.AttributeValueMemberN{Value: *v.N}
+		case v.B != nil:
+			key[name] = &awstypes.AttributeValueMemberB{Value: v.B}
+		default:
+			return nil, ErrInvalidCursor{Cursor: cursor, Err: fmt.Errorf("empty value for %s", name)}
+		}
+	}
+	return key, nil
+}
@@ -7,8 +7,9 @@ import (
 )
 
 // DiLink is a generic type that can link two entities together in dynamo.
-// By Default the DiLink will establish a one-to-one relationship between the two
-// entities using the primary keys. If you need to save or modify fields in the
+// By default an entity may take part in any number of links of a type; a
+// link type that implements CardinalityDeclarer limits that to one for
+// Entity0, Entity1 or both. If you need to save or modify fields in the
 // linked record, you will need to override this method.
 type DiLink[T0 types.Linkable, T1 types.Linkable] struct {
 	MonoLink[T0] // Embedding the MonoLink type for DynamoDB requirements
@@ -38,15 +39,3 @@ func (r *DiLink[T0, T1]) Type() string {
 	}
 	return r.UnmarshalledType
 }
-
-// Link is a generic method to establish a connection between the two entities.
-// Any two entities that embed the Row type can be linked together while maintaining
-// primary key entropy equal to the sum of the two entities.
-func (m *DiLink[T0, T1]) Link(ctx context.Context, row types.Linkable) error {
-	return m.Put(ctx, row)
-}
-
-// Unlink method removes the connection between the two entities by deleting the link record.
-func (m *DiLink[T0, T1]) Unlink(ctx context.Context, row types.Linkable) error {
-	return m.Delete(ctx, row)
-}
@@ -6,6 +6,8 @@ import (
 	ttypes "github.com/entegral/gobox/types"
 )
 
+// LoadEntity0s returns the Entity0 of every link of the type whose Entity1
+// is that of m. The entities are loaded with batched reads.
 func (m *DiLink[T0, T1]) LoadEntity0s(ctx context.Context, linkWrapper ttypes.Linkable) ([]T0, error) {
 	loaded, err := m.LoadEntity1(ctx)
 	if err != nil {
@@ -14,19 +16,6 @@ func (m *DiLink[T0, T1]) LoadEntity0s(ctx context.Context, linkWrapper ttypes.Li
 	if !loaded {
 		return nil, ErrEntityNotFound[T1]{Entity: m.Entity1}
 	}
-	links, err := FindLinksByEntity1[T1, *DiLink[T0, T1]](ctx, m.Entity1, linkWrapper.Type())
-	if err != nil {
-		return nil, err
-	}
-	var entities []T0
-	for _, link := range links {
-		loaded, err := link.LoadEntity0(ctx)
-		if err != nil {
-			return nil, err
-		}
-		if loaded {
-			entities = append(entities, link.Entity0)
-		}
-	}
-	return entities, nil
+	fixed := fixedEntities(nil, m.Entity1)
+	return loadSlotEntities[T0](ctx, &m.DBManager, linkWrapper.Type(), 0, fixed)
 }
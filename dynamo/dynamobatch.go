@@ -0,0 +1,355 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// batchGetLimit is the maximum number of keys in a BatchGetItem request.
+	batchGetLimit = 100
+	// batchWriteLimit is the maximum number of requests in a BatchWriteItem request.
+	batchWriteLimit = 25
+	// batchMaxAttempts is the number of times unprocessed keys and items are
+	// sent before they are reported as ErrUnprocessed.
+	batchMaxAttempts = 8
+	batchBaseDelay   = 25 * time.Millisecond
+	batchMaxDelay    = 2 * time.Second
+)
+
+// ErrUnprocessed is returned for a row that DynamoDB still reported as
+// unprocessed after every retry of a batch request.
+type ErrUnprocessed struct {
+	Row      types.Linkable
+	Attempts int
+}
+
+func (e ErrUnprocessed) Error() string {
+	return fmt.Sprintf("%s was not processed after %d attempts", e.Row.Type(), e.Attempts)
+}
+
+// batchEntry tracks the rows that share a primary key within a batch.
+type batchEntry struct {
+	key     map[string]awstypes.AttributeValue
+	request awstypes.WriteRequest
+	indices []int
+	done    bool
+}
+
+// BatchGetItems loads the rows with BatchGetItem requests of up to 100 keys,
+// retrying unprocessed keys with exponential backoff. The returned results
+// are in the same order as rows. A row that does not exist gets an
+// ErrItemNotFound, like Get.
+func (d *DBManager) BatchGetItems(ctx context.Context, rows []types.Linkable) []Result {
+	return d.batchGet(ctx, rows, func(i int) (map[string]awstypes.AttributeValue, error) {
+		return rowKey(rows[i])
+	})
+}
+
+// batchGet loads the rows like BatchGetItems, getting row i with key(i).
+func (d *DBManager) batchGet(ctx context.Context, rows []types.Linkable, key func(i int) (map[string]awstypes.AttributeValue, error)) []Result {
+	client, clientErr := d.client(ctx)
+	results, entries := newBatchEntries(rows, func(i int, row types.Linkable) (*batchEntry, error) {
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		if err := beforeGet(ctx, client, row); err != nil {
+			return nil, err
+		}
+		key, err := key(i)
+		return &batchEntry{key: key}, err
+	})
+	tn := d.TableName(ctx)
+	// ctxErr is set once ctx is done, failing the keys still unprocessed and
+	// every later chunk.
+	var ctxErr error
+	for _, chunk := range chunkEntries(entries, batchGetLimit) {
+		if ctxErr != nil {
+			failBatch(results, chunk, ctxErr)
+			continue
+		}
+		byKey := make(map[string]*batchEntry, len(chunk))
+		keys := make([]map[string]awstypes.AttributeValue, len(chunk))
+		for i, e := range chunk {
+			byKey[keyID(e.key)] = e
+			keys[i] = e.key
+		}
+		attempts := 0
+		for len(keys) > 0 && attempts < batchMaxAttempts {
+			if attempts > 0 {
+				if ctxErr = batchBackoff(ctx, attempts); ctxErr != nil {
+					break
+				}
+			}
+			attempts++
+			out, err := client.Dynamo().BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems:           map[string]awstypes.KeysAndAttributes{tn: {Keys: keys}},
+				ReturnConsumedCapacity: consumedCapacity(),
+			})
+			if err != nil {
+				failBatch(results, chunk, err)
+				break
+			}
+			for _, item := range out.Responses[tn] {
+				e, ok := byKey[keyID(item)]
+				if !ok {
+					continue
+				}
+				e.done = true
+				for _, i := range e.indices {
+					if err := unmarshalItemInto(rows[i], item); err != nil {
+						results[i].Error = err
+						continue
+					}
+					results[i].Loaded = true
+					results[i].Error = afterGet(ctx, client, rows[i])
+				}
+			}
+			keys = out.UnprocessedKeys[tn].Keys
+		}
+		unprocessed := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			unprocessed[keyID(key)] = true
+		}
+		for _, e := range chunk {
+			if e.done {
+				continue
+			}
+			for _, i := range e.indices {
+				if results[i].Error != nil {
+					continue
+				}
+				if unprocessed[keyID(e.key)] && ctxErr != nil {
+					results[i].Error = ctxErr
+				} else if unprocessed[keyID(e.key)] {
+					results[i].Error = &ErrUnprocessed{Row: rows[i], Attempts: attempts}
+				} else {
+					results[i].Error = &ErrItemNotFound{Row: rows[i]}
+				}
+			}
+		}
+	}
+	return results
+}
+
+// BatchPutItems writes the rows with BatchWriteItem requests of up to 25
+// items, retrying unprocessed items with exponential backoff. Rows are
+// written with the same keys, type and shard as Put, but BatchWriteItem
+// cannot return the old values or evaluate conditions, so the version of
+// types.Versionable rows is neither checked nor incremented, and
+// types.Timestampable rows keep the createdAt they carry rather than the
+// stored one. The returned results are in the same order as rows.
+func (d *DBManager) BatchPutItems(ctx context.Context, rows []types.Linkable) []Result {
+	client, clientErr := d.client(ctx)
+	results, entries := newBatchEntries(rows, func(_ int, row types.Linkable) (*batchEntry, error) {
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		if err := beforePut(ctx, client, row); err != nil {
+			return nil, err
+		}
+		av, err := putItemAttributes(row, rowTTL(row))
+		if err != nil {
+			return nil, err
+		}
+		stampItem(row, av)
+		return &batchEntry{
+			key:     map[string]awstypes.AttributeValue{"pk": av["pk"], "sk": av["sk"]},
+			request: awstypes.WriteRequest{PutRequest: &awstypes.PutRequest{Item: av}},
+		}, nil
+	})
+	return d.batchWrite(ctx, client, rows, results, entries, afterPut)
+}
+
+// BatchDeleteItems deletes the rows with BatchWriteItem requests of up to
+// 25 keys, retrying unprocessed items with exponential backoff. The returned
+// results are in the same order as rows.
+func (d *DBManager) BatchDeleteItems(ctx context.Context, rows []types.Linkable) []Result {
+	return d.batchDelete(ctx, rows, func(i int) (map[string]awstypes.AttributeValue, error) {
+		return rowKey(rows[i])
+	})
+}
+
+// batchDelete deletes the rows like BatchDeleteItems, deleting row i by
+// key(i).
+func (d *DBManager) batchDelete(ctx context.Context, rows []types.Linkable, key func(i int) (map[string]awstypes.AttributeValue, error)) []Result {
+	client, clientErr := d.client(ctx)
+	results, entries := newBatchEntries(rows, func(i int, row types.Linkable) (*batchEntry, error) {
+		if clientErr != nil {
+			return nil, clientErr
+		}
+		if err := beforeDelete(ctx, client, row); err != nil {
+			return nil, err
+		}
+		key, err := key(i)
+		if err != nil {
+			return nil, err
+		}
+		return &batchEntry{
+			key:     key,
+			request: awstypes.WriteRequest{DeleteRequest: &awstypes.DeleteRequest{Key: key}},
+		}, nil
+	})
+	return d.batchWrite(ctx, client, rows, results, entries, afterDelete)
+}
+
+// batchWrite sends the write requests of the entries and runs the after
+// hook of every row that was written.
+func (d *DBManager) batchWrite(ctx context.Context, client *clients.Client, rows []types.Linkable, results []Result, entries []*batchEntry, after func(context.Context, *clients.Client, any) error) []Result {
+	tn := d.TableName(ctx)
+	var ctxErr error
+	for _, chunk := range chunkEntries(entries, batchWriteLimit) {
+		if ctxErr != nil {
+			failBatch(results, chunk, ctxErr)
+			continue
+		}
+		requests := make([]awstypes.WriteRequest, len(chunk))
+		for i, e := range chunk {
+			requests[i] = e.request
+		}
+		attempts := 0
+		for len(requests) > 0 && attempts < batchMaxAttempts {
+			if attempts > 0 {
+				if ctxErr = batchBackoff(ctx, attempts); ctxErr != nil {
+					break
+				}
+			}
+			attempts++
+			out, err := client.Dynamo().BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems:           map[string][]awstypes.WriteRequest{tn: requests},
+				ReturnConsumedCapacity: consumedCapacity(),
+			})
+			if err != nil {
+				failBatch(results, chunk, err)
+				requests = nil
+				break
+			}
+			requests = out.UnprocessedItems[tn]
+		}
+		pending := make(map[string]bool, len(requests))
+		for _, req := range requests {
+			if req.PutRequest != nil {
+				pending[keyID(req.PutRequest.Item)] = true
+			} else if req.DeleteRequest != nil {
+				pending[keyID(req.DeleteRequest.Key)] = true
+			}
+		}
+		for _, e := range chunk {
+			for _, i := range e.indices {
+				if results[i].Error != nil {
+					continue
+				}
+				if pending[keyID(e.key)] && ctxErr != nil {
+					results[i].Error = ctxErr
+					continue
+				}
+				if pending[keyID(e.key)] {
+					results[i].Error = &ErrUnprocessed{Row: rows[i], Attempts: attempts}
+					continue
+				}
+				results[i].Loaded = true
+				results[i].Error = after(ctx, client, rows[i])
+			}
+		}
+	}
+	return results
+}
+
+// newBatchEntries builds one entry per distinct primary key. Rows whose
+// entry cannot be built get the error in their result. For writes, a later
+// row with the same key replaces the earlier request, matching the outcome
+// of issuing the writes in order.
+func newBatchEntries(rows []types.Linkable, build func(int, types.Linkable) (*batchEntry, error)) ([]Result, []*batchEntry) {
+	results := make([]Result, len(rows))
+	var entries []*batchEntry
+	byKey := map[string]*batchEntry{}
+	for i, row := range rows {
+		results[i].Index = i
+		e, err := build(i, row)
+		if err != nil {
+			results[i].Error = err
+			continue
+		}
+		id := keyID(e.key)
+		if existing, ok := byKey[id]; ok {
+			existing.indices = append(existing.indices, i)
+			existing.request = e.request
+			continue
+		}
+		e.indices = []int{i}
+		byKey[id] = e
+		entries = append(entries, e)
+	}
+	return results, entries
+}
+
+func chunkEntries(entries []*batchEntry, size int) [][]*batchEntry {
+	var chunks [][]*batchEntry
+	for len(entries) > size {
+		chunks = append(chunks, entries[:size])
+		entries = entries[size:]
+	}
+	if len(entries) > 0 {
+		chunks = append(chunks, entries)
+	}
+	return chunks
+}
+
+// failBatch records err for every row of the entries that has no result yet.
+func failBatch(results []Result, entries []*batchEntry, err error) {
+	for _, e := range entries {
+		for _, i := range e.indices {
+			if !results[i].Loaded && results[i].Error == nil {
+				results[i].Error = err
+			}
+		}
+	}
+}
+
+// keyID returns a string identifying the primary key of an item or key map.
+func keyID(item map[string]awstypes.AttributeValue) string {
+	var pk, sk string
+	if v, ok := item["pk"].(*awstypes.AttributeValueMemberS); ok {
+		pk = v.Value
+	}
+	if v, ok := item["sk"].(*awstypes.AttributeValueMemberS); ok {
+		sk = v.Value
+	}
+	return pk + "\x00" + sk
+}
+
+// rowTTL returns the TTL of rows that embed a DBManager.
+func rowTTL(row types.Linkable) *UnixTime {
+	if r, ok := row.(interface{ GetDynamoTTL() *UnixTime }); ok {
+		return r.GetDynamoTTL()
+	}
+	return nil
+}
+
+// batchBackoff waits before the given retry attempt using exponential
+// backoff with full jitter, returning early if ctx is done.
+func batchBackoff(ctx context.Context, attempt int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	delay := batchBaseDelay << (attempt - 1)
+	if delay > batchMaxDelay {
+		delay = batchMaxDelay
+	}
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(delay)) + 1))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/memdb"
+	"github.com/entegral/gobox/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchItems(t *testing.T) {
+	db := useMemDB(t, memdb.WithBatchCapacity(20))
+	ctx := context.Background()
+	manager := &DBManager{}
+
+	var rows []types.Linkable
+	for i := 0; i < 130; i++ {
+		rows = append(rows, &User{Email: fmt.Sprintf("batch%03d@gmail.com", i), Name: "Batch", Age: i})
+	}
+
+	t.Run("put chunks and retries unprocessed items", func(t *testing.T) {
+		results := manager.BatchPutItems(ctx, rows)
+		require.Len(t, results, len(rows))
+		for i, r := range results {
+			assert.Equal(t, i, r.Index)
+			assert.NoError(t, r.Error)
+			assert.True(t, r.Loaded)
+		}
+		items := db.Items("gobox-memdb")
+		require.Len(t, items, len(rows))
+		pk, ok := items[0]["pk"].(*awstypes.AttributeValueMemberS)
+		require.True(t, ok)
+		assert.Equal(t, "/rowType(user)/rowPk(batch000@gmail.com)", pk.Value)
+		assert.Contains(t, items[0], "pkshard")
+	})
+
+	t.Run("get loads rows and reports missing ones", func(t *testing.T) {
+		var loaded []types.Linkable
+		for i := 0; i < 120; i++ {
+			loaded = append(loaded, CreateUser(fmt.Sprintf("batch%03d@gmail.com", i)))
+		}
+		missing := CreateUser("missing@gmail.com")
+		loaded = append(loaded, missing)
+
+		results := manager.BatchGetItems(ctx, loaded)
+		require.Len(t, results, len(loaded))
+		for i, r := range results[:120] {
+			require.NoError(t, r.Error)
+			assert.True(t, r.Loaded)
+			user := loaded[i].(*User)
+			assert.Equal(t, i, user.Age)
+			assert.NotNil(t, user.RowData)
+		}
+		assert.False(t, results[120].Loaded)
+		assert.IsType(t, &ErrItemNotFound{}, results[120].Error)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		results := manager.BatchDeleteItems(ctx, rows[:30])
+		for _, r := range results {
+			assert.NoError(t, r.Error)
+		}
+		assert.Len(t, db.Items("gobox-memdb"), 100)
+	})
+
+	t.Run("rows left unprocessed after every retry", func(t *testing.T) {
+		useMemDB(t, memdb.WithBatchCapacity(1))
+		results := manager.BatchDeleteItems(ctx, rows[:batchMaxAttempts+1])
+		for _, r := range results[:batchMaxAttempts] {
+			assert.NoError(t, r.Error)
+		}
+		assert.IsType(t, &ErrUnprocessed{}, results[batchMaxAttempts].Error)
+	})
+
+	t.Run("key errors are reported per row", func(t *testing.T) {
+		results := manager.BatchPutItems(ctx, []types.Linkable{&User{}, rows[0]})
+		assert.IsType(t, &ErrMissingEmail{}, results[0].Error)
+		assert.NoError(t, results[1].Error)
+	})
+}
+
+// cancelAfterBatch cancels the context after the first batch request it
+// serves, so the retries that follow see a done context.
+type cancelAfterBatch struct {
+	clients.DynamoMethods
+	cancel context.CancelFunc
+}
+
+func (c cancelAfterBatch) BatchGetItem(ctx context.Context, in *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	defer c.cancel()
+	return c.DynamoMethods.BatchGetItem(ctx, in, optFns...)
+}
+
+func (c cancelAfterBatch) BatchWriteItem(ctx context.Context, in *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	defer c.cancel()
+	return c.DynamoMethods.BatchWriteItem(ctx, in, optFns...)
+}
+
+func TestBatchItemsCancelled(t *testing.T) {
+	db := useMemDB(t, memdb.WithBatchCapacity(1))
+	manager := &DBManager{}
+	var rows []types.Linkable
+	for i := 0; i < 60; i++ {
+		rows = append(rows, &User{Email: fmt.Sprintf("cancel%03d@gmail.com", i), Name: "Cancel", Age: i})
+	}
+	cancelled := func(t *testing.T) context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		clients.SetDefaultClient(ctx, db.Client().WithDynamo(cancelAfterBatch{DynamoMethods: db, cancel: cancel}))
+		return ctx
+	}
+
+	t.Run("put", func(t *testing.T) {
+		results := manager.BatchPutItems(cancelled(t), rows)
+		require.Len(t, results, len(rows))
+		assert.True(t, results[0].Loaded, "the item processed before the cancellation is written")
+		assert.NoError(t, results[0].Error)
+		for _, r := range results[1:] {
+			assert.ErrorIs(t, r.Error, context.Canceled, "row %d", r.Index)
+			assert.False(t, r.Loaded)
+		}
+		assert.Len(t, db.Items("gobox-memdb"), 1)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		loaded := make([]types.Linkable, len(rows))
+		for i := range rows {
+			loaded[i] = CreateUser(fmt.Sprintf("cancel%03d@gmail.com", i))
+		}
+		results := manager.BatchGetItems(cancelled(t), loaded)
+		require.Len(t, results, len(rows))
+		assert.True(t, results[0].Loaded)
+		for _, r := range results[1:] {
+			assert.ErrorIs(t, r.Error, context.Canceled, "row %d", r.Index)
+			assert.False(t, r.Loaded)
+		}
+	})
+}
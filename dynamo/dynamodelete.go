@@ -11,42 +11,33 @@ import (
 )
 
 // DeleteItem deletes a row from DynamoDB. The row must implement the Keyable
-// interface. This method uses the default client. If you need to use a specific
-// client, use DeleteItemWithClient instead, or use the client.SetDefaultClient method.
+// interface. This method uses the client set on the table, then the client
+// carried by ctx (see clients.WithClient), then the default client.
 func (d *DBManager) DeleteItem(ctx context.Context, row types.Linkable) (*dynamodb.DeleteItemOutput, error) {
-	if d.Client != nil {
-		return d.deleteItemPrependTypeWithClient(ctx, d.Client, row)
+	client, err := d.client(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return d.deleteItemPrependTypeWithClient(ctx, clients.GetDefaultClient(ctx), row)
+	return d.deleteItemPrependTypeWithClient(ctx, client, row)
 }
 
 func (d *DBManager) deleteItemPrependTypeWithClient(ctx context.Context, client *clients.Client, row types.Linkable) (*dynamodb.DeleteItemOutput, error) {
-	pk, sk, err := row.Keys(0)
-	if err != nil {
-		return nil, err
-	}
-	properPk, err := addKeySegment(rowType, row.Type())
-	if err != nil {
+	if err := beforeDelete(ctx, client, row); err != nil {
 		return nil, err
 	}
-	seg, err := addKeySegment(rowPk, pk)
+	key, err := rowKey(row)
 	if err != nil {
 		return nil, err
 	}
-	properPk += seg
-	rcc := awstypes.ReturnConsumedCapacityNone
-	if checkTesting() {
-		rcc = awstypes.ReturnConsumedCapacityTotal
-	}
-	key := map[string]awstypes.AttributeValue{
-		"pk": &awstypes.AttributeValueMemberS{Value: properPk},
-		"sk": &awstypes.AttributeValueMemberS{Value: sk},
-	}
 	tn := d.TableName(ctx)
-	return client.Dynamo().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+	out, err := client.Dynamo().DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName:              &tn,
 		Key:                    key,
 		ReturnValues:           awstypes.ReturnValueAllOld,
-		ReturnConsumedCapacity: rcc,
+		ReturnConsumedCapacity: consumedCapacity(),
 	})
+	if err != nil {
+		return nil, err
+	}
+	return out, afterDelete(ctx, client, row)
 }
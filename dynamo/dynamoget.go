@@ -3,7 +3,6 @@ package dynamo
 import (
 	"context"
 	"fmt"
-	"reflect"
 
 	"github.com/entegral/gobox/clients"
 	"github.com/entegral/gobox/types"
@@ -17,45 +16,30 @@ import (
 // GetItem gets a row from DynamoDB. The row must implement the Keyable
 // interface.
 func (d *DBManager) GetItem(ctx context.Context, row types.Linkable) (*dynamodb.GetItemOutput, error) {
-	return d.getItemPrependTypeWithClient(ctx, clients.GetDefaultClient(ctx), row)
+	client, err := d.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.getItemPrependTypeWithClient(ctx, client, row)
 }
 
 func (d *DBManager) GetItemWithTablename(ctx context.Context, row types.Linkable) (*dynamodb.GetItemOutput, error) {
-	if d.Client != nil {
-		return d.getItemPrependTypeWithClient(ctx, d.Client, row)
-	}
-	return d.getItemPrependTypeWithClient(ctx, clients.GetDefaultClient(ctx), row)
+	return d.GetItem(ctx, row)
 }
 
 func (d *DBManager) getItemPrependTypeWithClient(ctx context.Context, client *clients.Client, row types.Linkable) (*dynamodb.GetItemOutput, error) {
-	pk, sk, err := row.Keys(0)
-	if err != nil {
+	if err := beforeGet(ctx, client, row); err != nil {
 		return nil, err
 	}
-
-	pkWithTypePrefix, err := addKeySegment(rowType, row.Type())
+	key, err := rowKey(row)
 	if err != nil {
 		return nil, err
 	}
-	seg, err := addKeySegment(rowPk, pk)
-	if err != nil {
-		return nil, err
-	}
-	pkWithTypePrefix += seg
-
-	key := map[string]awstypes.AttributeValue{
-		"pk": &awstypes.AttributeValueMemberS{Value: pkWithTypePrefix},
-		"sk": &awstypes.AttributeValueMemberS{Value: sk},
-	}
-	rcc := awstypes.ReturnConsumedCapacityNone
-	if checkTesting() {
-		rcc = awstypes.ReturnConsumedCapacityTotal
-	}
 	tablename := d.TableName(ctx)
 	out, err := client.Dynamo().GetItem(ctx, &dynamodb.GetItemInput{
 		TableName:              aws.String(tablename),
 		Key:                    key,
-		ReturnConsumedCapacity: rcc,
+		ReturnConsumedCapacity: consumedCapacity(),
 	})
 	if err != nil {
 		return nil, err
@@ -64,32 +48,58 @@ func (d *DBManager) getItemPrependTypeWithClient(ctx context.Context, client *cl
 		return out, &ErrItemNotFound{Row: row}
 	}
 
-	// var newRow T
-
-	// Check if T implements CustomDynamoMarshaller
-	if marshaller, ok := any(row).(types.CustomDynamoMarshaller); ok {
-		err = marshaller.UnmarshalItem(out.Item)
-	} else {
-		err = attributevalue.UnmarshalMap(out.Item, row)
+	if err := unmarshalItemInto(row, out.Item); err != nil {
+		return nil, err
 	}
+	err = d.TTL.UnmarshalAttribute(out.Item["ttl"])
 	if err != nil {
 		return nil, err
 	}
-	err = d.TTL.UnmarshalAttribute(out.Item["ttl"])
+	return out, afterGet(ctx, client, row)
+}
+
+// rowKey returns the primary key of the row as stored in DynamoDB, with the
+// partition key prefixed by the row type.
+func rowKey(row types.Linkable) (map[string]awstypes.AttributeValue, error) {
+	pk, sk, err := rowKeys(row, 0)
 	if err != nil {
 		return nil, err
 	}
-	// if the row has a RowData field by embedding the Row struct, set it
-	rowValue := reflect.ValueOf(row).Elem()
-	if rowDataField := rowValue.FieldByName("Row"); rowDataField.IsValid() {
-		if DBManager := rowDataField.FieldByName("DBManager"); DBManager.IsValid() {
-			rowData := DBManager.FieldByName("RowData")
-			if rowData.CanSet() {
-				rowData.Set(reflect.ValueOf(out.Item))
-			}
+	pkWithTypePrefix, err := prependWithRowType(row, pk)
+	if err != nil {
+		return nil, err
+	}
+	key := map[string]awstypes.AttributeValue{
+		"pk": &awstypes.AttributeValueMemberS{Value: pkWithTypePrefix},
+		"sk": &awstypes.AttributeValueMemberS{Value: sk},
+	}
+	if err := validateKeySizes(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// unmarshalItemInto unmarshals the item into the row, honouring
+// types.CustomDynamoMarshaller. If the row has a RowData field by embedding
+// the Row struct, it is set to the item. The entity keys of a MultiLink are
+// read from their attributes.
+func unmarshalItemInto(row any, item map[string]awstypes.AttributeValue) error {
+	var err error
+	if marshaller, ok := row.(types.CustomDynamoMarshaller); ok {
+		err = marshaller.UnmarshalItem(item)
+	} else {
+		err = attributevalue.UnmarshalMap(item, row)
+	}
+	if err != nil {
+		return err
+	}
+	if link, ok := row.(slotted); ok {
+		if multi := link.slots().multi; multi != nil {
+			multi.setSlotAttributes(item)
 		}
 	}
-	return out, nil
+	setRowData(row, item)
+	return nil
 }
 
 type ErrItemNotFound struct {
@@ -99,7 +109,7 @@ type ErrItemNotFound struct {
 // Error implements the error interface and will
 // return the row type and keys.
 func (e ErrItemNotFound) Error() string {
-	pk, sk, err := e.Row.Keys(0)
+	pk, sk, err := rowKeys(e.Row, 0)
 	if err != nil {
 		return fmt.Sprintf("item not found: %s with Pk: %s and Sk: %s, error: %v", e.Row.Type(), pk, sk, err)
 	}
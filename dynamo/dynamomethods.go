@@ -23,11 +23,16 @@ type DBManager struct {
 	GetItemOutput    *dynamodb.GetItemOutput    `dynamodbav:"-" json:"-"`
 	PutItemOutput    *dynamodb.PutItemOutput    `dynamodbav:"-" json:"-"`
 	DeleteItemOutput *dynamodb.DeleteItemOutput `dynamodbav:"-" json:"-"`
+	UpdateItemOutput *dynamodb.UpdateItemOutput `dynamodbav:"-" json:"-"`
 
 	// TTL is a UnixTime timestamp that is used to set the Time To Live
 	// (TTL) for the item in DynamoDB.
 	TTL UnixTime `dynamodbav:"ttl,omitempty" json:"ttl,omitempty"`
 
+	// Concurrency is the number of rows BatchGet, BatchPut, BatchDelete and
+	// BatchLoadFromMessage process at once. Zero uses DefaultConcurrency.
+	Concurrency int `dynamodbav:"-" json:"-"`
+
 	// RowData is a map of data retrieved from DynamoDB during the last
 	// GetItem operation. This is useful for comparing the old values
 	// with the new values after a PutItem operation.
@@ -2,106 +2,131 @@ package dynamo
 
 import (
 	"context"
-	"sync"
+	"errors"
+	"fmt"
+	"sort"
 
 	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/entegral/gobox/types"
 )
 
+// DefaultConcurrency is the number of rows the concurrent DBManager methods
+// process at once when DBManager.Concurrency is not set.
+var DefaultConcurrency = 10
+
 type Result struct {
 	Index  int
 	Loaded bool
 	Error  error
 }
 
-// BatchGet gets multiple rows from DynamoDB concurrently. The rows must implement the Keyable interface.
-func (d *DBManager) BatchGet(ctx context.Context, rows []types.Linkable) <-chan Result {
-	results := make(chan Result)
+// ResultError is the error of a single row in the error returned by
+// CollectResults.
+type ResultError struct {
+	Index int
+	Err   error
+}
 
-	go func() {
-		var wg sync.WaitGroup
+func (e ResultError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Index, e.Err)
+}
 
-		for i, row := range rows {
-			wg.Add(1)
-			go func(i int, row types.Linkable) {
-				defer wg.Done()
-				loaded, err := d.Get(ctx, row)
-				results <- Result{i, loaded, err}
-			}(i, row)
-		}
+func (e ResultError) Unwrap() error {
+	return e.Err
+}
 
-		wg.Wait()
-		close(results)
-	}()
+// CollectResults reads every result from the channel and returns them
+// ordered by Index. The error joins a ResultError for every failed row, so
+// errors.As can recover the index of each failure.
+func CollectResults(results <-chan Result) ([]Result, error) {
+	var collected []Result
+	for r := range results {
+		collected = append(collected, r)
+	}
+	sort.Slice(collected, func(i, j int) bool {
+		return collected[i].Index < collected[j].Index
+	})
+	var errs []error
+	for _, r := range collected {
+		if r.Error != nil {
+			errs = append(errs, ResultError{Index: r.Index, Err: r.Error})
+		}
+	}
+	return collected, errors.Join(errs...)
+}
 
-	return results
+// BatchGet gets multiple rows from DynamoDB concurrently. The rows must implement the Keyable interface.
+func (d *DBManager) BatchGet(ctx context.Context, rows []types.Linkable) <-chan Result {
+	return d.concurrently(ctx, len(rows), func(ctx context.Context, m *DBManager, i int) (bool, error) {
+		return m.Get(ctx, rows[i])
+	})
 }
 
 // BatchPut puts multiple rows into DynamoDB concurrently. The rows must implement the Linkable interface.
 func (d *DBManager) BatchPut(ctx context.Context, rows []types.Linkable) <-chan Result {
-	results := make(chan Result)
-
-	go func() {
-		var wg sync.WaitGroup
-
-		for i, row := range rows {
-			wg.Add(1)
-			go func(i int, row types.Linkable) {
-				defer wg.Done()
-				err := d.Put(ctx, row)
-				results <- Result{i, err == nil, err}
-			}(i, row)
-		}
-
-		wg.Wait()
-		close(results)
-	}()
-
-	return results
+	return d.concurrently(ctx, len(rows), func(ctx context.Context, m *DBManager, i int) (bool, error) {
+		err := m.Put(ctx, rows[i])
+		return err == nil, err
+	})
 }
 
 // BatchDelete deletes multiple rows from DynamoDB concurrently. The rows must implement the Keyable interface.
 func (d *DBManager) BatchDelete(ctx context.Context, rows []types.Linkable) <-chan Result {
-	results := make(chan Result)
-
-	go func() {
-		var wg sync.WaitGroup
-
-		for i, row := range rows {
-			wg.Add(1)
-			go func(i int, row types.Linkable) {
-				defer wg.Done()
-				err := d.Delete(ctx, row)
-				results <- Result{i, err == nil, err}
-			}(i, row)
-		}
-
-		wg.Wait()
-		close(results)
-	}()
-
-	return results
+	return d.concurrently(ctx, len(rows), func(ctx context.Context, m *DBManager, i int) (bool, error) {
+		err := m.Delete(ctx, rows[i])
+		return err == nil, err
+	})
 }
 
 // BatchLoadFromMessage unmarshals multiple SQS messages into Rows and then loads the full items from DynamoDB concurrently.
 func (d *DBManager) BatchLoadFromMessage(ctx context.Context, messages []sqstypes.Message, rows []types.Linkable) <-chan Result {
-	results := make(chan Result)
-
-	go func() {
-		var wg sync.WaitGroup
+	return d.concurrently(ctx, len(messages), func(ctx context.Context, m *DBManager, i int) (bool, error) {
+		if i >= len(rows) {
+			return false, fmt.Errorf("no row provided for message %d", i)
+		}
+		return m.LoadFromMessage(ctx, messages[i], rows[i])
+	})
+}
 
-		for i, message := range messages {
-			wg.Add(1)
-			go func(i int, message sqstypes.Message, row types.Linkable) {
-				defer wg.Done()
-				loaded, err := d.LoadFromMessage(ctx, message, row)
+// concurrently calls fn for the indices 0 to n-1 on a pool of at most
+// d.Concurrency workers. Each worker uses its own copy of the DBManager so
+// the Get, Put and Delete outputs of different rows do not race. The
+// returned channel is buffered for every result, so workers never block on
+// a caller that stops reading. Once ctx is done, the remaining rows are not
+// processed and report ctx.Err().
+func (d *DBManager) concurrently(ctx context.Context, n int, fn func(ctx context.Context, m *DBManager, i int) (bool, error)) <-chan Result {
+	results := make(chan Result, n)
+	workers := d.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+	workers = min(workers, n)
+
+	indices := make(chan int)
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			m := DBManager{Table: d.Table, Tablename: d.Tablename, TTL: d.TTL}
+			for i := range indices {
+				if err := ctx.Err(); err != nil {
+					results <- Result{i, false, err}
+					continue
+				}
+				loaded, err := fn(ctx, &m, i)
 				results <- Result{i, loaded, err}
-			}(i, message, rows[i])
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			indices <- i
+		}
+		close(indices)
+		for w := 0; w < workers; w++ {
+			<-done
 		}
-
-		wg.Wait()
 		close(results)
 	}()
-
 	return results
 }
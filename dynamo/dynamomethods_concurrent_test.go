@@ -2,12 +2,16 @@ package dynamo
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/entegral/gobox/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockLinkable struct {
@@ -132,3 +136,64 @@ func TestBatchLoadFromMessage(t *testing.T) {
 		}
 	})
 }
+
+func TestConcurrentLimit(t *testing.T) {
+	db := &DBManager{Concurrency: 3}
+	var inFlight, peak int32
+	results := db.concurrently(context.Background(), 20, func(ctx context.Context, m *DBManager, i int) (bool, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return true, nil
+	})
+	collected, err := CollectResults(results)
+	require.NoError(t, err)
+	assert.Len(t, collected, 20)
+	assert.LessOrEqual(t, peak, int32(3))
+}
+
+func TestConcurrentCancel(t *testing.T) {
+	db := &DBManager{Concurrency: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+	results := db.concurrently(ctx, 10, func(ctx context.Context, m *DBManager, i int) (bool, error) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			cancel()
+		}
+		return true, nil
+	})
+	collected, err := CollectResults(results)
+	require.Len(t, collected, 10)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	for i, r := range collected {
+		assert.Equal(t, i, r.Index)
+	}
+}
+
+func TestCollectResults(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+	db := &DBManager{Concurrency: 2}
+	present := &User{Email: "collect@gmail.com"}
+	require.NoError(t, present.Put(ctx, present))
+
+	rows := []types.Linkable{CreateUser("collect@gmail.com"), CreateUser("absent@gmail.com"), &User{}}
+	collected, err := CollectResults(db.BatchGet(ctx, rows))
+	require.Len(t, collected, 3)
+	assert.True(t, collected[0].Loaded)
+	assert.Nil(t, db.GetItemOutput)
+
+	var resultErr ResultError
+	require.True(t, errors.As(err, &resultErr))
+	assert.Equal(t, 1, resultErr.Index)
+	assert.IsType(t, &ErrItemNotFound{}, resultErr.Err)
+	var missingEmail *ErrMissingEmail
+	assert.True(t, errors.As(err, &missingEmail))
+}
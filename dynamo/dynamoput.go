@@ -2,6 +2,7 @@ package dynamo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 
@@ -14,58 +15,184 @@ import (
 )
 
 // PutItem puts a row into DynamoDB. The row must implement the
-// Keyable interface. This method uses the default client. If you need to use a specific
-// client, use PutItemWithClient instead, or use the client.SetDefaultClient method.
+// Keyable interface. This method uses the client set on the table, then the
+// client carried by ctx (see clients.WithClient), then the default client.
 func (d *DBManager) PutItem(ctx context.Context, row types.Linkable) (*dynamodb.PutItemOutput, error) {
-	if d.Client != nil {
-		return d.putItemPrependTypeWithClient(ctx, d.Client, row)
+	client, err := d.client(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return d.putItemPrependTypeWithClient(ctx, clients.GetDefaultClient(ctx), row)
+	return d.putItemPrependTypeWithClient(ctx, client, row)
 }
 
 func (d *DBManager) putItemPrependTypeWithClient(ctx context.Context, client *clients.Client, row types.Linkable) (*dynamodb.PutItemOutput, error) {
-	pk, sk, err := row.Keys(0)
+	return d.putItemIfWithClient(ctx, client, row, Condition{}, nil)
+}
+
+// putItemIfWithClient puts the row if the condition holds. The version of
+// types.Versionable rows is enforced as well, and types.Timestampable rows
+// carry the createdAt of the item they replace. When the put fails its
+// condition, failed is given the stored item and returns the error to
+// report, or nil to fall back to ErrVersionConflict or ErrConditionFailed.
+func (d *DBManager) putItemIfWithClient(ctx context.Context, client *clients.Client, row types.Linkable, cond Condition, failed func(current map[string]awstypes.AttributeValue) error) (*dynamodb.PutItemOutput, error) {
+	if err := beforePut(ctx, client, row); err != nil {
+		return nil, err
+	}
+	av, err := putItemAttributes(row, d.GetDynamoTTL())
 	if err != nil {
 		return nil, err
 	}
-	av, err := attributevalue.MarshalMap(row)
+	tn := d.TableName(ctx)
+	vp, versioned := newVersionedPut(row, av)
+	if versioned {
+		cond = And(cond, vp.condition())
+	}
+	tp, timestamped := newTimestampedPut(row, av)
+	if cond.IsZero() && !timestamped {
+		out, err := putItemWithClient(ctx, client, tn, av)
+		if err != nil {
+			return nil, err
+		}
+		return out, afterPut(ctx, client, row)
+	}
+	var out *dynamodb.PutItemOutput
+	if timestamped {
+		out, err = tp.putWithClient(ctx, client, tn, cond)
+	} else {
+		out, err = conditionalPutWithClient(ctx, client, tn, av, cond)
+	}
+	var ccf *awstypes.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		if failed != nil {
+			if err := failed(ccf.Item); err != nil {
+				return nil, err
+			}
+		}
+		if versioned && vp.mismatch(ccf.Item) {
+			return nil, vp.conflict(ccf.Item)
+		}
+		return nil, ErrConditionFailed{Row: row, Current: ccf.Item}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if versioned {
+		vp.commit()
+	}
+	return out, afterPut(ctx, client, row)
+}
+
+// conditionalPutWithClient puts the item if the condition holds.
+func conditionalPutWithClient(ctx context.Context, client *clients.Client, tablename string, av map[string]awstypes.AttributeValue, cond Condition) (*dynamodb.PutItemOutput, error) {
+	expr, names, values, err := cond.Render()
+	if err != nil {
+		return nil, err
+	}
+	return client.Dynamo().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                           &tablename,
+		Item:                                av,
+		ConditionExpression:                 &expr,
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValues:                        awstypes.ReturnValueAllOld,
+		ReturnValuesOnConditionCheckFailure: awstypes.ReturnValuesOnConditionCheckFailureAllOld,
+		ReturnConsumedCapacity:              consumedCapacity(),
+	})
+}
+
+// putItemAttributes marshals the row into the item written by PutItem: the
+// row's attributes plus its type-prefixed keys, type, shard and ttl, the
+// entity keys of a MultiLink and the sort value of a LinkSorter.
+func putItemAttributes(row types.Linkable, ttl *UnixTime) (map[string]awstypes.AttributeValue, error) {
+	key, err := rowKey(row)
 	if err != nil {
 		return nil, err
 	}
-	pkWithTypePrefix, err := prependWithRowType(row, pk)
+	av, err := attributevalue.MarshalMap(row)
 	if err != nil {
 		return nil, err
 	}
-	av["pk"] = &awstypes.AttributeValueMemberS{Value: pkWithTypePrefix}
-	av["sk"] = &awstypes.AttributeValueMemberS{Value: sk}
+	if link, ok := row.(slotted); ok {
+		if multi := link.slots().multi; multi != nil {
+			for name, value := range multi.slotAttributes() {
+				av[name] = value
+			}
+		}
+	}
+	if sort := linkSort(row); sort != "" {
+		av[linkSortAttribute] = &awstypes.AttributeValueMemberS{Value: sort}
+	}
+	av["pk"] = key["pk"]
+	av["sk"] = key["sk"]
 	av["type"] = &awstypes.AttributeValueMemberS{Value: row.Type()}
 	if av["pkshard"] == nil {
 		av["pkshard"] = &awstypes.AttributeValueMemberS{
 			Value: getTypeShardKey(row.Type(), row.MaxShard()),
 		}
 	}
-	if !d.GetDynamoTTL().IsZero() {
-		av["ttl"] = &awstypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", d.GetDynamoTTL().Unix())}
+	if ttl != nil && !ttl.IsZero() {
+		av["ttl"] = &awstypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", ttl.Unix())}
 	}
-	tn := d.TableName(ctx)
-	return putItemWithClient(ctx, client, tn, av)
+	if err := validateKeySizes(av); err != nil {
+		return nil, err
+	}
+	return av, nil
 }
 
 // putItemWithClient puts a row into DynamoDB using the provided client.
 func putItemWithClient(ctx context.Context, client *clients.Client, tablename string, av map[string]awstypes.AttributeValue) (*dynamodb.PutItemOutput, error) {
-	rcc := awstypes.ReturnConsumedCapacityNone
-	if checkTesting() {
-		rcc = awstypes.ReturnConsumedCapacityTotal
-	}
 	return client.Dynamo().PutItem(ctx, &dynamodb.PutItemInput{
 		TableName:              &tablename,
 		Item:                   av,
 		ReturnValues:           awstypes.ReturnValueAllOld,
-		ReturnConsumedCapacity: rcc,
+		ReturnConsumedCapacity: consumedCapacity(),
 	})
 }
 
 func getTypeShardKey(pk string, maxShard int) string {
-	shard := rand.Intn(maxShard)
-	return fmt.Sprintf("%s.%d", pk, shard)
+	return typeShardKey(pk, rand.Intn(maxShard))
+}
+
+// typeShardKey returns the pkshard that putItemAttributes writes for a row
+// of the type in the shard.
+func typeShardKey(rowType string, shard int) string {
+	return fmt.Sprintf("%s.%d", rowType, shard)
+}
+
+// ErrCustomShard is returned when the rows of a type cannot be read by
+// shard because the row sets its own pkshard, which Put keeps in place of
+// the type's shards.
+type ErrCustomShard struct {
+	Type  string
+	Shard string
+}
+
+func (e ErrCustomShard) Error() string {
+	return fmt.Sprintf("%s sets its own pkshard %q, so its rows cannot be read by shard", e.Type, e.Shard)
+}
+
+// typeShards returns every pkshard that putItemAttributes writes for the
+// rows of the row's type, from its Type and MaxShard. It fails with
+// ErrCustomShard when the row marshals a pkshard of its own.
+func typeShards(row types.Linkable) ([]string, error) {
+	av, err := attributevalue.MarshalMap(row)
+	if err != nil {
+		return nil, err
+	}
+	if av["pkshard"] != nil {
+		var shard string
+		if s, ok := av["pkshard"].(*awstypes.AttributeValueMemberS); ok {
+			shard = s.Value
+		}
+		return nil, ErrCustomShard{Type: row.Type(), Shard: shard}
+	}
+	maxShard := row.MaxShard()
+	if maxShard < 1 {
+		return nil, fmt.Errorf("%s has no shards: MaxShard is %d", row.Type(), maxShard)
+	}
+	shards := make([]string, maxShard)
+	for i := range shards {
+		shards[i] = typeShardKey(row.Type(), i)
+	}
+	return shards, nil
 }
@@ -12,11 +12,15 @@ import (
 // UpdateItem updates a row in DynamoDB. The row must implement the
 // DynamoUpdater interface. Consider embedding your type into a wrapper
 // that implements DynamoUpdater in order to issue the desired update behavior.
+// Its DynamoUpdateInput method can build the input with Update.Input.
 //
-// This method uses the default client. If you need to use a specific client,
-// use UpdateItemWithClient instead, or use the client.SetDefaultClient method.
+// This method uses the client carried by ctx, or the default client. If you
+// need to use a specific client, use UpdateItemWithClient instead.
 func UpdateItem(ctx context.Context, row types.DynamoUpdater) (*dynamodb.UpdateItemOutput, error) {
-	client := clients.GetDefaultClient(ctx)
+	client, err := clients.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return UpdateItemWithClient(ctx, client, row)
 }
 
@@ -24,9 +28,16 @@ func UpdateItem(ctx context.Context, row types.DynamoUpdater) (*dynamodb.UpdateI
 // DynamoUpdater interface. Consider embedding your type into a wrapper
 // that implements DynamoUpdater in order to issue a specific update behavior.
 func UpdateItemWithClient(ctx context.Context, client *clients.Client, row types.DynamoUpdater) (*dynamodb.UpdateItemOutput, error) {
+	if err := beforeUpdate(ctx, client, row); err != nil {
+		return nil, err
+	}
 	input, err := row.DynamoUpdateInput(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return client.Dynamo().UpdateItem(ctx, input)
+	out, err := client.Dynamo().UpdateItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return out, afterUpdate(ctx, client, row)
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/entegral/gobox/clients"
@@ -12,110 +11,129 @@ import (
 )
 
 // findLinkRowsByEntityGSI is a generic method to query for a list of rows based on the Entity1.
-func findLinkRowsByEntityGSI[T ttypes.Linkable](ctx context.Context, clients *clients.Client, entity T, entityGSI EntityGSI, linkType string) ([]map[string]types.AttributeValue, error) {
-	var epkKey, eskKey string
+// Every page of the query is read.
+func findLinkRowsByEntityGSI[T ttypes.Linkable](ctx context.Context, client *clients.Client, entity T, entityGSI EntityGSI, linkType string) ([]map[string]types.AttributeValue, error) {
+	input, err := entityGSIQueryInput(ctx, entity, entityGSI.slot(), linkType)
+	it := newIterator[map[string]types.AttributeValue](client, input, err)
+	it.decode = func(item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+		return item, nil
+	}
+	return it.All(ctx)
+}
 
-	switch entityGSI {
-	case Entity0GSI:
-		epkKey = entity0pk.String()
-		eskKey = entity0sk.String()
-	case Entity1GSI:
-		epkKey = entity1pk.String()
-		eskKey = entity1sk.String()
-	case Entity2GSI:
-		epkKey = entity2pk.String()
-		eskKey = entity2sk.String()
+// slot returns the slot Entity0GSI, Entity1GSI or Entity2GSI is the GSI of.
+// Other GSIs have no index, as links can give a slot any GSI name.
+func (g EntityGSI) slot() LinkSlot {
+	for i, gsi := range []EntityGSI{Entity0GSI, Entity1GSI, Entity2GSI} {
+		if g == gsi {
+			return LinkSlot{Index: i, GSI: g}
+		}
 	}
+	return LinkSlot{Index: -1, GSI: g}
+}
 
-	ePk, eSk, err := entity.Keys(0)
-	if err != nil {
-		return nil, err
+// entityGSIQueryInput builds the query for the link rows of the provided
+// type that reference the entity in the slot, on the slot's GSI.
+func entityGSIQueryInput[T ttypes.Linkable](ctx context.Context, entity T, slot LinkSlot, linkType string) (*dynamodb.QueryInput, error) {
+	if slot.Index < 0 || slot.GSI == "" {
+		return nil, fmt.Errorf("invalid link slot %d with GSI %q", slot.Index, slot.GSI)
 	}
-	linkedPk, err := addKeySegment(rowType, entity.Type())
+	_, epkKey, eskKey := slotLabels(slot.Index)
+
+	ePk, eSk, err := rowKeys(entity, 0)
 	if err != nil {
 		return nil, err
 	}
-	seg, err := addKeySegment(rowPk, ePk)
+	// Links store the key of their entities as the entities store it,
+	// without escaping, so it is matched as is.
+	linkedPk, err := prependWithRowType(entity, ePk)
 	if err != nil {
 		return nil, err
 	}
-	linkedPk += seg
 
 	kce := fmt.Sprintf("%s = :pk AND begins_with(%s, :sk)", epkKey, eskKey)
 	tn := entity.TableName(ctx)
-	index := entityGSI.String()
-	fe := "#type = :type"
+	index := slot.GSI.String()
 	qi := dynamodb.QueryInput{
 		TableName:              &tn,
 		KeyConditionExpression: &kce,
 		IndexName:              &index,
-		FilterExpression:       &fe,
-		ExpressionAttributeNames: map[string]string{
-			"#type": "type",
-		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk":   &types.AttributeValueMemberS{Value: linkedPk},
-			":sk":   &types.AttributeValueMemberS{Value: eSk},
-			":type": &types.AttributeValueMemberS{Value: linkType},
+			":pk": &types.AttributeValueMemberS{Value: linkedPk},
+			":sk": &types.AttributeValueMemberS{Value: eSk},
 		},
 	}
-	out, err := clients.Dynamo().Query(ctx, &qi)
-	if err != nil {
-		return nil, err
+	// An empty link type matches the links of every type.
+	if linkType != "" {
+		fe := "#type = :type"
+		qi.FilterExpression = &fe
+		qi.ExpressionAttributeNames = map[string]string{"#type": "type"}
+		qi.ExpressionAttributeValues[":type"] = &types.AttributeValueMemberS{Value: linkType}
 	}
-	if len(out.Items) == 0 {
-		return nil, nil
+	return &qi, nil
+}
+
+// IterLinksByEntity0 returns an iterator over the links of the provided
+// type whose Entity0 is e0.
+func IterLinksByEntity0[T0, CustomLinkType ttypes.Linkable](ctx context.Context, e0 T0, linkType string) *Iterator[CustomLinkType] {
+	return IterLinksBySlot[T0, CustomLinkType](ctx, e0, EntitySlot(0), linkType)
+}
+
+// IterLinksByEntity1 returns an iterator over the links of the provided
+// type whose Entity1 is e1.
+func IterLinksByEntity1[T1, CustomLinkType ttypes.Linkable](ctx context.Context, e1 T1, linkType string) *Iterator[CustomLinkType] {
+	return IterLinksBySlot[T1, CustomLinkType](ctx, e1, EntitySlot(1), linkType)
+}
+
+// IterLinksByEntity2 returns an iterator over the links of the provided
+// type whose Entity2 is e2.
+func IterLinksByEntity2[T2, CustomLinkType ttypes.Linkable](ctx context.Context, e2 T2, linkType string) *Iterator[CustomLinkType] {
+	return IterLinksBySlot[T2, CustomLinkType](ctx, e2, EntitySlot(2), linkType)
+}
+
+// IterLinksBySlot returns an iterator over the links of the provided type
+// whose entity in the slot is entity, such as the links returned by the
+// Slot method of a MultiLink.
+func IterLinksBySlot[T, CustomLinkType ttypes.Linkable](ctx context.Context, entity T, slot LinkSlot, linkType string) *Iterator[CustomLinkType] {
+	client, err := clients.Resolve(ctx)
+	var input *dynamodb.QueryInput
+	if err == nil {
+		input, err = entityGSIQueryInput(ctx, entity, slot, linkType)
 	}
-	return out.Items, nil
+	return newIterator[CustomLinkType](client, input, err)
+}
+
+// FindLinksBySlot is a generic method to query for a list of links based on
+// the entity in the slot.
+func FindLinksBySlot[T, CustomLinkType ttypes.Linkable](ctx context.Context, entity T, slot LinkSlot, linkType string) ([]CustomLinkType, error) {
+	return IterLinksBySlot[T, CustomLinkType](ctx, entity, slot, linkType).All(ctx)
 }
 
 // FindLinksByEntity0 is a generic method to query for a list of links based on the Entity0.
 func FindLinksByEntity0[T0, CustomLinkType ttypes.Linkable](ctx context.Context, e0 T0, linkType string) ([]CustomLinkType, error) {
-	client := clients.GetDefaultClient(ctx)
-	rows, err := findLinkRowsByEntityGSI[T0](ctx, client, e0, Entity0GSI, linkType)
-	if err != nil {
-		return nil, err
-	}
-	var links []CustomLinkType
-	for _, item := range rows {
-		var link CustomLinkType
-		if err := attributevalue.UnmarshalMap(item, &link); err != nil {
-			return nil, err
-		}
-		links = append(links, link)
-	}
-	return links, nil
+	return IterLinksByEntity0[T0, CustomLinkType](ctx, e0, linkType).All(ctx)
 }
 
 // FindLinksByEntity1 is a generic method to query for a list of links based on the Entity1.
 func FindLinksByEntity1[T1, CustomLinkType ttypes.Linkable](ctx context.Context, e1 T1, linkType string) ([]CustomLinkType, error) {
-	clients := clients.GetDefaultClient(ctx)
-	rows, err := findLinkRowsByEntityGSI[T1](ctx, clients, e1, Entity1GSI, linkType)
-	if err != nil {
-		return nil, err
-	}
-	var links []CustomLinkType
-	for _, item := range rows {
-		var link CustomLinkType
-		if err := attributevalue.UnmarshalMap(item, &link); err != nil {
-			return nil, err
-		}
-		links = append(links, link)
-	}
-	return links, err
+	return IterLinksByEntity1[T1, CustomLinkType](ctx, e1, linkType).All(ctx)
 }
 
 // FindLinksByEntity2 is a generic method to query for a list of links based on the Entity2.
+// Rows whose type is not the type of CustomLinkType are left out.
 func FindLinksByEntity2[T2, CustomLinkType ttypes.Linkable](ctx context.Context, e2 T2, linkType string) ([]CustomLinkType, error) {
-	clients := clients.GetDefaultClient(ctx)
-	rows, err := findLinkRowsByEntityGSI[T2](ctx, clients, e2, Entity2GSI, linkType)
+	client, err := clients.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := findLinkRowsByEntityGSI[T2](ctx, client, e2, Entity2GSI, linkType)
 	if err != nil {
 		return nil, err
 	}
 	var links []CustomLinkType
 	for _, item := range rows {
-		var link CustomLinkType
-		if err := attributevalue.UnmarshalMap(item, &link); err != nil {
+		link, err := unmarshalRow[CustomLinkType](item)
+		if err != nil {
 			return nil, err
 		}
 		if err := validateDynamoRowType[CustomLinkType](item, link); err == nil {
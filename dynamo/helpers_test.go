@@ -3,8 +3,24 @@ package dynamo
 import (
 	"context"
 	"fmt"
+	"os"
+	"testing"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/memdb"
 )
 
+// TestMain runs the package tests against an in-memory table unless
+// TABLENAME points them at a real one.
+func TestMain(m *testing.M) {
+	if os.Getenv("TABLENAME") == "" {
+		os.Setenv("TABLENAME", "arctica")
+		os.Setenv("GOBOX_TESTING", "true")
+		clients.SetDefaultClient(context.Background(), memdb.New(memdb.WithTable("arctica")).Client())
+	}
+	os.Exit(m.Run())
+}
+
 func SeedUsers(ctx context.Context, count int) []*User {
 	var users []*User
 	for i := 0; i < count; i++ {
@@ -0,0 +1,127 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+)
+
+// ErrHook is returned when a lifecycle hook of a row fails. A failing Before
+// hook aborts the operation. After hooks run once the operation succeeded,
+// so their failure does not undo it.
+type ErrHook struct {
+	Hook string
+	Err  error
+}
+
+func (e ErrHook) Error() string {
+	return fmt.Sprintf("%s hook: %v", e.Hook, e.Err)
+}
+
+func (e ErrHook) Unwrap() error {
+	return e.Err
+}
+
+func hookErr(hook string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return ErrHook{Hook: hook, Err: err}
+}
+
+// beforeGet runs the BeforeGet hook of the row.
+func beforeGet(ctx context.Context, client *clients.Client, row any) error {
+	if h, ok := row.(types.BeforeGetHook); ok {
+		return hookErr("BeforeGet", h.BeforeGet(ctx, client))
+	}
+	return nil
+}
+
+// afterGet runs the AfterGet hook of a loaded row.
+func afterGet(ctx context.Context, client *clients.Client, row any) error {
+	if h, ok := row.(types.AfterGetHook); ok {
+		return hookErr("AfterGet", h.AfterGet(ctx, client))
+	}
+	return nil
+}
+
+// beforePut runs the Before and BeforePut hooks of the row.
+func beforePut(ctx context.Context, client *clients.Client, row any) error {
+	if err := beforeWrite(ctx, client, row); err != nil {
+		return err
+	}
+	if h, ok := row.(types.BeforePutHook); ok {
+		return hookErr("BeforePut", h.BeforePut(ctx, client))
+	}
+	return nil
+}
+
+// afterPut runs the AfterPut and After hooks of the row.
+func afterPut(ctx context.Context, client *clients.Client, row any) error {
+	if h, ok := row.(types.AfterPutHook); ok {
+		if err := hookErr("AfterPut", h.AfterPut(ctx, client)); err != nil {
+			return err
+		}
+	}
+	return afterWrite(ctx, client, row)
+}
+
+// beforeDelete runs the Before and BeforeDelete hooks of the row.
+func beforeDelete(ctx context.Context, client *clients.Client, row any) error {
+	if err := beforeWrite(ctx, client, row); err != nil {
+		return err
+	}
+	if h, ok := row.(types.BeforeDeleteHook); ok {
+		return hookErr("BeforeDelete", h.BeforeDelete(ctx, client))
+	}
+	return nil
+}
+
+// afterDelete runs the AfterDelete and After hooks of the row.
+func afterDelete(ctx context.Context, client *clients.Client, row any) error {
+	if h, ok := row.(types.AfterDeleteHook); ok {
+		if err := hookErr("AfterDelete", h.AfterDelete(ctx, client)); err != nil {
+			return err
+		}
+	}
+	return afterWrite(ctx, client, row)
+}
+
+// beforeUpdate runs the Before and BeforeUpdate hooks of the row.
+func beforeUpdate(ctx context.Context, client *clients.Client, row any) error {
+	if err := beforeWrite(ctx, client, row); err != nil {
+		return err
+	}
+	if h, ok := row.(types.BeforeUpdateHook); ok {
+		return hookErr("BeforeUpdate", h.BeforeUpdate(ctx, client))
+	}
+	return nil
+}
+
+// afterUpdate runs the AfterUpdate and After hooks of the row.
+func afterUpdate(ctx context.Context, client *clients.Client, row any) error {
+	if h, ok := row.(types.AfterUpdateHook); ok {
+		if err := hookErr("AfterUpdate", h.AfterUpdate(ctx, client)); err != nil {
+			return err
+		}
+	}
+	return afterWrite(ctx, client, row)
+}
+
+// beforeWrite runs the Before hook of types.Hookable rows.
+func beforeWrite(ctx context.Context, client *clients.Client, row any) error {
+	if h, ok := row.(types.Hookable); ok {
+		return hookErr("Before", h.Before(ctx, client))
+	}
+	return nil
+}
+
+// afterWrite runs the After hook of types.Hookable rows.
+func afterWrite(ctx context.Context, client *clients.Client, row any) error {
+	if h, ok := row.(types.Hookable); ok {
+		return hookErr("After", h.After(ctx, client))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errInvalidSlug = errors.New("slug must not be empty")
+
+// Article records the hooks that run on it and derives its key in BeforePut.
+type Article struct {
+	Row
+	Title string
+	Slug  string
+	calls []string
+}
+
+func (a *Article) Type() string {
+	return "article"
+}
+
+func (a *Article) Keys(gsi int) (string, string, error) {
+	a.PartitionKey = a.Slug
+	a.SortKey = "article"
+	return a.PartitionKey, a.SortKey, nil
+}
+
+func (a *Article) record(call string) error {
+	a.calls = append(a.calls, call)
+	return nil
+}
+
+func (a *Article) Before(ctx context.Context, client *clients.Client) error {
+	return a.record("Before")
+}
+
+func (a *Article) After(ctx context.Context, client *clients.Client) error {
+	return a.record("After")
+}
+
+func (a *Article) BeforeGet(ctx context.Context, client *clients.Client) error {
+	return a.record("BeforeGet")
+}
+
+func (a *Article) AfterGet(ctx context.Context, client *clients.Client) error {
+	return a.record("AfterGet")
+}
+
+func (a *Article) BeforePut(ctx context.Context, client *clients.Client) error {
+	a.record("BeforePut")
+	if a.Slug == "" {
+		a.Slug = strings.ReplaceAll(strings.ToLower(a.Title), " ", "-")
+	}
+	if a.Slug == "" {
+		return errInvalidSlug
+	}
+	return nil
+}
+
+func (a *Article) AfterPut(ctx context.Context, client *clients.Client) error {
+	return a.record("AfterPut")
+}
+
+func (a *Article) BeforeDelete(ctx context.Context, client *clients.Client) error {
+	return a.record("BeforeDelete")
+}
+
+func (a *Article) AfterDelete(ctx context.Context, client *clients.Client) error {
+	return a.record("AfterDelete")
+}
+
+func TestHooks(t *testing.T) {
+	db := useMemDB(t)
+	ctx := context.Background()
+
+	t.Run("put runs the hooks in order and writes their changes", func(t *testing.T) {
+		article := &Article{Title: "Hello World"}
+		require.NoError(t, article.Put(ctx, article))
+		assert.Equal(t, []string{"Before", "BeforePut", "AfterPut", "After"}, article.calls)
+
+		loaded := &Article{Slug: "hello-world"}
+		ok, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "Hello World", loaded.Title)
+		assert.Equal(t, []string{"BeforeGet", "AfterGet"}, loaded.calls)
+	})
+	t.Run("a failing before hook aborts the write", func(t *testing.T) {
+		before := len(db.Items("gobox-memdb"))
+		article := &Article{}
+		err := article.Put(ctx, article)
+		var hookErr ErrHook
+		require.True(t, errors.As(err, &hookErr))
+		assert.Equal(t, "BeforePut", hookErr.Hook)
+		assert.ErrorIs(t, err, errInvalidSlug)
+		assert.Len(t, db.Items("gobox-memdb"), before)
+
+		results := (&DBManager{}).BatchPutItems(ctx, []types.Linkable{&Article{Title: "Batch"}, &Article{}})
+		assert.NoError(t, results[0].Error)
+		assert.ErrorIs(t, results[1].Error, errInvalidSlug)
+		assert.Len(t, db.Items("gobox-memdb"), before+1)
+	})
+	t.Run("queries and batch reads run AfterGet", func(t *testing.T) {
+		articles, err := NewQuery[*Article]("hello-world").Exec(ctx)
+		require.NoError(t, err)
+		require.Len(t, articles, 1)
+		assert.Equal(t, []string{"AfterGet"}, articles[0].calls)
+
+		loaded := &Article{Slug: "batch"}
+		results := (&DBManager{}).BatchGetItems(ctx, []types.Linkable{loaded})
+		require.NoError(t, results[0].Error)
+		assert.Equal(t, []string{"BeforeGet", "AfterGet"}, loaded.calls)
+	})
+	t.Run("transactions and deletes run the write hooks", func(t *testing.T) {
+		article := &Article{Slug: "batch"}
+		_, err := NewTransaction().Delete(article).Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Before", "BeforeDelete", "AfterDelete", "After"}, article.calls)
+
+		article = &Article{Slug: "hello-world"}
+		require.NoError(t, article.Delete(ctx, article))
+		assert.Equal(t, []string{"Before", "BeforeDelete", "AfterDelete", "After"}, article.calls)
+	})
+}
@@ -0,0 +1,130 @@
+package dynamo
+
+import (
+	"context"
+
+	"github.com/entegral/gobox/clients"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Iterator pages through the results of a query. Call Next to fetch each
+// page and Page to read it:
+//
+//	it := NewQuery[*User]("user@gmail.com").Iter(ctx)
+//	for it.Next(ctx) {
+//		for _, user := range it.Page() {
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// Cursor returns a token that resumes the query after the current page, so
+// an API can hand it to a caller and continue in a later request with
+// StartAfter.
+type Iterator[T any] struct {
+	client  *clients.Client
+	input   *dynamodb.QueryInput
+	decode  func(map[string]awstypes.AttributeValue) (T, error)
+	page    []T
+	lastKey map[string]awstypes.AttributeValue
+	started bool
+	done    bool
+	err     error
+}
+
+// newIterator returns an iterator over the query input. If err is not nil
+// the iterator yields no pages and Err returns it.
+func newIterator[T any](client *clients.Client, input *dynamodb.QueryInput, err error) *Iterator[T] {
+	return &Iterator[T]{client: client, input: input, decode: unmarshalRow[T], err: err}
+}
+
+// PageSize sets the maximum number of items evaluated per page. It must be
+// called before the first call to Next.
+func (it *Iterator[T]) PageSize(n int32) *Iterator[T] {
+	if it.input != nil && n > 0 {
+		it.input.Limit = aws.Int32(n)
+	}
+	return it
+}
+
+// StartAfter resumes the query from a cursor returned by Cursor. It must be
+// called before the first call to Next. An empty cursor starts from the
+// beginning.
+func (it *Iterator[T]) StartAfter(cursor string) *Iterator[T] {
+	key, err := DecodeCursor(cursor)
+	if err != nil {
+		it.err = err
+		return it
+	}
+	if it.input != nil {
+		it.input.ExclusiveStartKey = key
+	}
+	return it
+}
+
+// Next fetches the next page. It returns false once every page has been
+// read or an error occurs.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil || it.done {
+		return false
+	}
+	if it.started {
+		it.input.ExclusiveStartKey = it.lastKey
+	}
+	it.started = true
+	out, err := it.client.Dynamo().Query(ctx, it.input)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page = make([]T, 0, len(out.Items))
+	for _, item := range out.Items {
+		row, err := it.decode(item)
+		if err == nil {
+			err = afterGet(ctx, it.client, row)
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page = append(it.page, row)
+	}
+	it.lastKey = out.LastEvaluatedKey
+	it.done = len(out.LastEvaluatedKey) == 0
+	return true
+}
+
+// Page returns the rows of the page fetched by the last call to Next.
+func (it *Iterator[T]) Page() []T {
+	return it.page
+}
+
+// Cursor returns a token that resumes the query after the current page, or
+// the empty string when there are no more pages.
+func (it *Iterator[T]) Cursor() string {
+	cursor, err := EncodeCursor(it.lastKey)
+	if err != nil {
+		it.err = err
+		return ""
+	}
+	return cursor
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All reads every remaining page and returns their rows.
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	var rows []T
+	for it.Next(ctx) {
+		rows = append(rows, it.page...)
+	}
+	return rows, it.err
+}
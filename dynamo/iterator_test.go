@@ -0,0 +1,105 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/memdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	key := map[string]awstypes.AttributeValue{
+		"pk":   &awstypes.AttributeValueMemberS{Value: "/rowType(user)/rowPk(a+b/c?)"},
+		"e0sk": &awstypes.AttributeValueMemberN{Value: "12"},
+		"bin":  &awstypes.AttributeValueMemberB{Value: []byte{0, 1, 2}},
+	}
+	cursor, err := EncodeCursor(key)
+	require.NoError(t, err)
+	assert.NotContains(t, cursor, "+")
+	assert.NotContains(t, cursor, "/")
+	assert.NotContains(t, cursor, "=")
+
+	decoded, err := DecodeCursor(cursor)
+	require.NoError(t, err)
+	assert.Equal(t, key, decoded)
+
+	empty, err := EncodeCursor(nil)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	_, err = DecodeCursor("not a cursor")
+	assert.IsType(t, ErrInvalidCursor{}, err)
+}
+
+func TestIterators(t *testing.T) {
+	useMemDB(t, memdb.WithPageSize(2))
+	ctx := context.Background()
+
+	user := &User{Email: "iterator@gmail.com", Name: "Iterator"}
+	require.NoError(t, user.Put(ctx, user))
+	for i := 0; i < 5; i++ {
+		car := &Car{Make: "IterMake", Model: fmt.Sprintf("Model%d", i), Year: 2000 + i}
+		require.NoError(t, car.Put(ctx, car))
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car), VIN: fmt.Sprint(i)}
+		require.NoError(t, slip.Link(ctx, slip))
+	}
+
+	t.Run("FindLinks reads every page", func(t *testing.T) {
+		links, err := FindLinksByEntity0[*User, *PinkSlip](ctx, user, "PinkSlip")
+		require.NoError(t, err)
+		assert.Len(t, links, 5)
+	})
+
+	t.Run("link pages resume from a cursor", func(t *testing.T) {
+		it := IterLinksByEntity0[*User, *PinkSlip](ctx, user, "PinkSlip")
+		require.True(t, it.Next(ctx))
+		first := it.Page()
+		assert.Len(t, first, 2)
+		cursor := it.Cursor()
+		require.NotEmpty(t, cursor)
+
+		var rest []*PinkSlip
+		resumed := IterLinksByEntity0[*User, *PinkSlip](ctx, user, "PinkSlip").StartAfter(cursor)
+		for resumed.Next(ctx) {
+			rest = append(rest, resumed.Page()...)
+		}
+		require.NoError(t, resumed.Err())
+		assert.Empty(t, resumed.Cursor())
+		assert.Len(t, rest, 3)
+		for _, link := range rest {
+			assert.NotContains(t, []string{first[0].VIN, first[1].VIN}, link.VIN)
+		}
+	})
+
+	t.Run("typed query pages", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			book := &Book{Author: "iterator", Title: fmt.Sprintf("volume %d", i), Genre: "reference", Year: 2000}
+			require.NoError(t, book.Put(ctx, book))
+		}
+		var pages [][]string
+		it := NewQuery[*Book]("iterator").Limit(1).Iter(ctx)
+		for it.Next(ctx) {
+			var titles []string
+			for _, b := range it.Page() {
+				titles = append(titles, b.Title)
+			}
+			pages = append(pages, titles)
+		}
+		require.NoError(t, it.Err())
+		assert.Equal(t, [][]string{{"volume 0"}, {"volume 1"}, {"volume 2"}}, pages)
+
+		rows, err := NewQuery[*Book]("iterator").StartAfter(it.Cursor()).Exec(ctx)
+		require.NoError(t, err)
+		assert.Len(t, rows, 3)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		it := IterLinksByEntity0[*User, *PinkSlip](ctx, user, "PinkSlip").StartAfter("%%%")
+		assert.False(t, it.Next(ctx))
+		assert.IsType(t, ErrInvalidCursor{}, it.Err())
+	})
+}
@@ -0,0 +1,135 @@
+package dynamo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeySegment is a /label(value) segment of a key, as written for the type
+// prefix of primary keys and the entity keys of links.
+type KeySegment struct {
+	Label string
+	Value string
+}
+
+// KeySegments are the segments of a key, in order.
+type KeySegments []KeySegment
+
+// Value returns the value of the first segment with the label.
+func (s KeySegments) Value(label string) (string, bool) {
+	for _, seg := range s {
+		if seg.Label == label {
+			return seg.Value, true
+		}
+	}
+	return "", false
+}
+
+// ErrInvalidKey is returned by ParseKey for keys that are not made of
+// /label(value) segments.
+type ErrInvalidKey struct {
+	Key    string
+	Offset int
+}
+
+func (e ErrInvalidKey) Error() string {
+	return fmt.Sprintf("invalid key %q at offset %d", e.Key, e.Offset)
+}
+
+// ParseKey splits a key made of /label(value) segments, such as the stored
+// partition key /rowType(user)/rowPk(jane@example.com) or the key of a
+// link. Values escaped by links are unescaped. Keys written before links
+// escaped their values are read too: a value only ends at a ")" that is
+// followed by the end of the key or by the next /label( segment, so
+// unescaped values that contain parentheses or slashes are kept intact
+// unless they contain a segment themselves.
+func ParseKey(key string) (KeySegments, error) {
+	if key == "" {
+		return nil, ErrInvalidKey{Key: key}
+	}
+	var segments KeySegments
+	for pos := 0; pos < len(key); {
+		label, ok := segmentLabel(key[pos:])
+		if !ok {
+			return nil, ErrInvalidKey{Key: key, Offset: pos}
+		}
+		start := pos + len(label) + 2
+		end := -1
+		for i := start; i < len(key); i++ {
+			if key[i] != ')' {
+				continue
+			}
+			if rest := key[i+1:]; rest == "" {
+				end = i
+				break
+			} else if _, ok := segmentLabel(rest); ok {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, ErrInvalidKey{Key: key, Offset: start}
+		}
+		segments = append(segments, KeySegment{Label: label, Value: unescapeSegmentValue(key[start:end])})
+		pos = end + 1
+	}
+	return segments, nil
+}
+
+// storedRowPk returns the pk of a row from its stored partition key,
+// /rowType(type)/rowPk(pk). The pk is not escaped in stored keys, and as it
+// is the last segment it is read up to the final ")", so the pk of a link,
+// which is made of segments itself, is kept whole.
+func storedRowPk(key string) (string, bool) {
+	_, value, ok := strings.Cut(key, "/"+rowPk.String()+"(")
+	if !ok || !strings.HasSuffix(value, ")") || len(value) == 1 {
+		return "", false
+	}
+	return value[:len(value)-1], true
+}
+
+// storedRowType returns the type of a row from its stored partition key,
+// /rowType(type)/rowPk(pk).
+func storedRowType(key string) (string, bool) {
+	value, ok := strings.CutPrefix(key, "/"+rowType.String()+"(")
+	if !ok {
+		return "", false
+	}
+	t, _, ok := strings.Cut(value, ")/"+rowPk.String()+"(")
+	return t, ok && t != ""
+}
+
+// segmentEscaper escapes the characters that delimit segments, and "%"
+// itself so that escaping can be reversed.
+var segmentEscaper = strings.NewReplacer("%", "%25", "(", "%28", ")", "%29", "/", "%2F")
+
+// segmentUnescaper reverses segmentEscaper. Other uses of "%" are left
+// alone, so most values written unescaped read back unchanged; only an
+// unescaped value that contains one of the four escape sequences is read
+// back decoded.
+var segmentUnescaper = strings.NewReplacer("%25", "%", "%28", "(", "%29", ")", "%2F", "/")
+
+// escapeSegmentValue escapes a value written into the key of a link, so a
+// pk or sk containing parentheses or slashes, such as a URL or the key of
+// another link, can be extracted again.
+func escapeSegmentValue(value string) string {
+	return segmentEscaper.Replace(value)
+}
+
+// unescapeSegmentValue reverses escapeSegmentValue.
+func unescapeSegmentValue(value string) string {
+	return segmentUnescaper.Replace(value)
+}
+
+// segmentLabel returns the label of the segment s begins with, if s begins
+// with "/" followed by a known label and "(".
+func segmentLabel(s string) (string, bool) {
+	if !strings.HasPrefix(s, "/") {
+		return "", false
+	}
+	label, _, ok := strings.Cut(s[1:], "(")
+	if !ok || !linkLabels(label).IsValidLabel() {
+		return "", false
+	}
+	return label, true
+}
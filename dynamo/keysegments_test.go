@@ -0,0 +1,116 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKey(t *testing.T) {
+	t.Run("splits the segments of a link key", func(t *testing.T) {
+		segments, err := ParseKey("/e0Type(RowExample)/e0pk(partitionKey)")
+		require.NoError(t, err)
+		assert.Equal(t, KeySegments{{"e0Type", "RowExample"}, {"e0pk", "partitionKey"}}, segments)
+		value, ok := segments.Value("e0pk")
+		assert.True(t, ok)
+		assert.Equal(t, "partitionKey", value)
+		_, ok = segments.Value("e1pk")
+		assert.False(t, ok)
+	})
+	t.Run("keeps parentheses and slashes in values", func(t *testing.T) {
+		segments, err := ParseKey("/rowType(user)/rowPk(jane (admin)/eu)")
+		require.NoError(t, err)
+		value, _ := segments.Value("rowPk")
+		assert.Equal(t, "jane (admin)/eu", value)
+	})
+	t.Run("round trips the keys written by links", func(t *testing.T) {
+		link := &MonoLinkExample{MonoLink: NewMonoLink(&RowExample{})}
+		pk, sk, err := link.GenerateMonoLinkKeys()
+		require.NoError(t, err)
+		segments, err := ParseKey(pk + sk)
+		require.NoError(t, err)
+		for _, label := range []linkLabels{entity0Type, entity0pk, entity0sk} {
+			value, ok := segments.Value(label.String())
+			assert.True(t, ok)
+			assert.Equal(t, extractKeys(label, pk+sk), value)
+		}
+	})
+	t.Run("rejects keys that are not made of segments", func(t *testing.T) {
+		for _, key := range []string{"", "plain", "/unknown(x)", "/rowPk(unterminated", "/rowPk(x)trailing"} {
+			_, err := ParseKey(key)
+			assert.True(t, errors.As(err, new(ErrInvalidKey)), key)
+		}
+	})
+}
+
+func TestLinkKeyEscaping(t *testing.T) {
+	user := &User{Email: "jane+cars)/eu@example.com"}
+	car := &Car{Make: "https://cars.io/(m)", Model: "50%", Year: 2024}
+
+	t.Run("escapes reserved characters in link keys", func(t *testing.T) {
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car)}
+		pk, sk, err := slip.GenerateDiLinkKeys()
+		require.NoError(t, err)
+		assert.Equal(t, "/e0Type(user)/e0pk(jane+cars%29%2Feu@example.com)/e1Type(car)/e1pk(https:%2F%2Fcars.io%2F%28m%29-50%25)", pk)
+		assert.Equal(t, "/e0sk(info)/e1sk(2024)", sk)
+		assert.Equal(t, user.Email, extractKeys(entity0pk, pk))
+		assert.Equal(t, "https://cars.io/(m)-50%", extractKeys(entity1pk, pk))
+
+		segments, err := ParseKey(pk)
+		require.NoError(t, err)
+		value, _ := segments.Value("e1pk")
+		assert.Equal(t, "https://cars.io/(m)-50%", value)
+	})
+	t.Run("keeps the stored key of entities unescaped", func(t *testing.T) {
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car)}
+		_, _, err := slip.GenerateDiLinkKeys()
+		require.NoError(t, err)
+		assert.Equal(t, "/rowType(user)/rowPk(jane+cars)/eu@example.com)", slip.E0pk)
+		assert.Equal(t, "/rowType(car)/rowPk(https://cars.io/(m)-50%)", slip.E1pk)
+	})
+	t.Run("escapes the key of a nested link", func(t *testing.T) {
+		contact := &ContactInfo{MonoLink: NewMonoLink(user)}
+		contactPk, _, err := contact.GenerateMonoLinkKeys()
+		require.NoError(t, err)
+		seg, err := addLinkKeySegment(entity0pk, contactPk)
+		require.NoError(t, err)
+		assert.Equal(t, "/e0pk(%2Fe0Type%28user%29%2Fe0pk%28jane+cars%2529%252Feu@example.com%29)", seg)
+		assert.Equal(t, contactPk, extractKeys(entity0pk, seg))
+	})
+	t.Run("reads keys written before escaping", func(t *testing.T) {
+		legacy := "/e0Type(car)/e0pk(https://cars.io/(m)-50%)/e1Type(user)/e1pk(jane@example.com)"
+		assert.Equal(t, "https://cars.io/(m)-50%", extractKeys(entity0pk, legacy))
+		assert.Equal(t, "jane@example.com", extractKeys(entity1pk, legacy))
+		pk, ok := storedRowPk("/rowType(PinkSlip)/rowPk(/e0Type(user)/e0pk(a)/e1Type(car)/e1pk(b))")
+		assert.True(t, ok)
+		assert.Equal(t, "/e0Type(user)/e0pk(a)/e1Type(car)/e1pk(b)", pk)
+	})
+	t.Run("finds and loads the entities of links with escaped keys", func(t *testing.T) {
+		useMemDB(t)
+		ctx := context.Background()
+		require.NoError(t, user.Put(ctx, user))
+		require.NoError(t, car.Put(ctx, car))
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car), VIN: "escaped"}
+		require.NoError(t, slip.Put(ctx, slip))
+
+		links, err := FindLinksByEntity1[*Car, *PinkSlip](ctx, car, slip.Type())
+		require.NoError(t, err)
+		require.Len(t, links, 1)
+		assert.Equal(t, "escaped", links[0].VIN)
+
+		loaded := &PinkSlip{DiLink: DiLink[*User, *Car]{E1pk: slip.E1pk, E1sk: slip.E1sk}}
+		loaded.PartitionKey, loaded.SortKey = slip.PartitionKey, slip.SortKey
+		ok, err := loaded.LoadEntity1(ctx)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, car.Make, loaded.Entity1.Make)
+	})
+	t.Run("round trips escaping", func(t *testing.T) {
+		for _, value := range []string{"plain", "a(b)c", "/x/", "%", "%28", "100%29)"} {
+			assert.Equal(t, value, unescapeSegmentValue(escapeSegmentValue(value)), value)
+		}
+	})
+}
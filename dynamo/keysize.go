@@ -0,0 +1,109 @@
+package dynamo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/keys"
+)
+
+// The limits DynamoDB puts on the size of key attributes, of tables and
+// GSIs alike.
+const (
+	maxPartitionKeyBytes = 2048
+	maxSortKeyBytes      = 1024
+)
+
+// linkKeyHeadroom is the room left in the partition key of a link for the
+// /rowType(...)/rowPk(...) prefix it is stored with.
+const linkKeyHeadroom = 256
+
+// ErrKeyTooLarge is returned before a request is sent when a key attribute
+// of the row is larger than DynamoDB allows.
+type ErrKeyTooLarge struct {
+	Attribute string
+	Size      int
+	Limit     int
+}
+
+func (e ErrKeyTooLarge) Error() string {
+	return fmt.Sprintf("key attribute %s is %d bytes, larger than the limit of %d", e.Attribute, e.Size, e.Limit)
+}
+
+// ErrHashedKey is returned when the keys of an entity are extracted from a
+// link key that was hashed. The keys are stored in the link's e0pk, e1pk
+// and e2pk attributes instead.
+type ErrHashedKey struct {
+	Key string
+}
+
+func (e ErrHashedKey) Error() string {
+	return fmt.Sprintf("key %s is hashed, the entity keys are in the e0pk, e1pk and e2pk attributes of the link", e.Key)
+}
+
+// keySizeLimits are the key attributes a row may have, of its table and of
+// the GSIs, with their size limits. The key attributes of link slots,
+// e<N>pk and e<N>sk, are checked too, and linksort is the range key of the
+// sorted entity GSIs.
+var keySizeLimits = map[string]int{
+	keys.PkKey: maxPartitionKeyBytes, keys.SkKey: maxSortKeyBytes,
+	keys.Pk1Key: maxPartitionKeyBytes, keys.Sk1Key: maxSortKeyBytes,
+	keys.Pk2Key: maxPartitionKeyBytes, keys.Sk2Key: maxSortKeyBytes,
+	keys.Pk3Key: maxPartitionKeyBytes, keys.Sk3Key: maxSortKeyBytes,
+	keys.Pk4Key: maxPartitionKeyBytes, keys.Sk4Key: maxSortKeyBytes,
+	keys.Pk5Key: maxPartitionKeyBytes, keys.Sk5Key: maxSortKeyBytes,
+	keys.Pk6Key: maxPartitionKeyBytes, keys.Sk6Key: maxSortKeyBytes,
+	linkSortAttribute: maxSortKeyBytes,
+}
+
+// validateKeySizes returns an ErrKeyTooLarge for the first key attribute
+// of the item that is larger than DynamoDB allows.
+func validateKeySizes(item map[string]awstypes.AttributeValue) error {
+	for _, name := range sortedAttributes(item) {
+		limit, ok := keySizeLimit(name)
+		if !ok {
+			continue
+		}
+		if s, ok := item[name].(*awstypes.AttributeValueMemberS); ok && len(s.Value) > limit {
+			return ErrKeyTooLarge{Attribute: name, Size: len(s.Value), Limit: limit}
+		}
+	}
+	return nil
+}
+
+func keySizeLimit(name string) (int, bool) {
+	if limit, ok := keySizeLimits[name]; ok {
+		return limit, true
+	}
+	index, ok := slotLabelIndex(name)
+	if !ok {
+		return 0, false
+	}
+	_, pk, sk := slotLabels(index)
+	switch name {
+	case pk.String():
+		return maxPartitionKeyBytes, true
+	case sk.String():
+		return maxSortKeyBytes, true
+	}
+	return 0, false
+}
+
+// hashLongKey returns key, or its hashed form if it is longer than limit.
+// The hashed form, /keyHash(sha256 of the key), is deterministic, so the
+// same entities always give the same key.
+func hashLongKey(key string, limit int) string {
+	if len(key) <= limit {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("/%s(%s)", keyHash, hex.EncodeToString(sum[:]))
+}
+
+// isHashedKey reports whether key is the hashed form of a link key.
+func isHashedKey(key string) bool {
+	return strings.HasPrefix(key, "/"+keyHash.String()+"(")
+}
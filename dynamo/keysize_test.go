@@ -0,0 +1,81 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Trip struct {
+	TriLink[*User, *Car, *User]
+}
+
+func (t *Trip) Type() string {
+	return "Trip"
+}
+
+func TestLongLinkKeys(t *testing.T) {
+	driver := &User{Email: strings.Repeat("d", 900) + "@example.com"}
+	passenger := &User{Email: strings.Repeat("p", 900) + "@example.com"}
+	car := &Car{Make: "Long", Model: "Keys", Year: 2024}
+
+	t.Run("hashes partition keys too long to be stored", func(t *testing.T) {
+		trip := &Trip{TriLink: *NewTriLink(driver, car, passenger)}
+		pk, sk, err := trip.GenerateTriLinkCompositeKey()
+		require.NoError(t, err)
+		assert.True(t, isHashedKey(pk))
+		assert.Len(t, pk, len("/keyHash()")+64)
+		assert.Equal(t, "/e0sk(info)/e1sk(2024)/e2sk(info)", sk)
+
+		again := &Trip{TriLink: *NewTriLink(driver, car, passenger)}
+		assert.Equal(t, pk, again.PartitionKey)
+		other := &Trip{TriLink: *NewTriLink(passenger, car, driver)}
+		assert.NotEqual(t, pk, other.PartitionKey)
+	})
+	t.Run("keeps short keys readable", func(t *testing.T) {
+		slip := &PinkSlip{DiLink: *NewDiLink(driver, car)}
+		assert.False(t, isHashedKey(slip.PartitionKey))
+	})
+	t.Run("keeps the entity keys in their attributes", func(t *testing.T) {
+		trip := &Trip{TriLink: *NewTriLink(driver, car, passenger)}
+		pk, sk, err := trip.ExtractE2Keys()
+		require.NoError(t, err)
+		assert.Equal(t, "/rowType(user)/rowPk("+passenger.Email+")", pk)
+		assert.Equal(t, "info", sk)
+
+		trip.E0pk, trip.E0sk = "", ""
+		_, _, err = trip.ExtractE0Keys()
+		assert.True(t, errors.As(err, new(ErrHashedKey)))
+	})
+	t.Run("finds links with hashed keys", func(t *testing.T) {
+		useMemDB(t)
+		ctx := context.Background()
+		trip := &Trip{TriLink: *NewTriLink(driver, car, passenger)}
+		require.NoError(t, trip.Put(ctx, trip))
+
+		links, err := FindLinksByEntity2[*User, *Trip](ctx, passenger, trip.Type())
+		require.NoError(t, err)
+		require.Len(t, links, 1)
+		assert.Equal(t, "/rowType(Trip)/rowPk("+trip.PartitionKey+")", links[0].PartitionKey)
+		assert.Equal(t, trip.E0pk, links[0].E0pk)
+		assert.Equal(t, trip.E2pk, links[0].E2pk)
+	})
+	t.Run("rejects keys too large before sending the request", func(t *testing.T) {
+		db := useMemDB(t)
+		ctx := context.Background()
+		user := &User{Email: strings.Repeat("u", maxPartitionKeyBytes) + "@example.com"}
+		err := user.Put(ctx, user)
+		var tooLarge ErrKeyTooLarge
+		require.True(t, errors.As(err, &tooLarge))
+		assert.Equal(t, "pk", tooLarge.Attribute)
+		assert.Equal(t, maxPartitionKeyBytes, tooLarge.Limit)
+		assert.Empty(t, db.Items("gobox-memdb"))
+
+		_, err = user.Get(ctx, user)
+		assert.True(t, errors.As(err, new(ErrKeyTooLarge)))
+	})
+}
@@ -0,0 +1,258 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/types"
+)
+
+// LinkSlot identifies an entity slot of a link: its index, which names the
+// e<N>pk and e<N>sk attributes holding the key of the entity, and the GSI
+// on those attributes.
+type LinkSlot struct {
+	Index int
+	GSI   EntityGSI
+}
+
+// EntitySlot returns the slot at index with its default GSI,
+// e<N>pk-e<N>sk-index. Entity0GSI, Entity1GSI and Entity2GSI are the
+// default GSIs of the first three slots.
+func EntitySlot(index int) LinkSlot {
+	return LinkSlot{Index: index, GSI: EntityGSI(fmt.Sprintf("e%dpk-e%dsk-index", index, index))}
+}
+
+// slotLabels returns the labels of the type, pk and sk segments of the
+// entity at index, which are also the names of its key attributes.
+func slotLabels(index int) (typ, pk, sk linkLabels) {
+	return linkLabels(fmt.Sprintf("e%dType", index)),
+		linkLabels(fmt.Sprintf("e%dpk", index)),
+		linkLabels(fmt.Sprintf("e%dsk", index))
+}
+
+// slotLabelIndex returns the index of the entity a slot label such as e3pk
+// or e3Type refers to.
+func slotLabelIndex(label string) (int, bool) {
+	rest, ok := strings.CutPrefix(label, "e")
+	if !ok {
+		return 0, false
+	}
+	for _, suffix := range []string{"Type", "pk", "sk"} {
+		if digits, ok := strings.CutSuffix(rest, suffix); ok && digits != "" {
+			index, err := strconv.Atoi(digits)
+			if err != nil || index < 0 || strconv.Itoa(index) != digits {
+				return 0, false
+			}
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// linkSlot is an entity slot of a link: the entity, which may be nil when
+// the link was loaded, the attributes that hold its stored key, and the
+// error reported when the entity does not exist, if not the default one.
+type linkSlot struct {
+	entity  types.Linkable
+	pk, sk  *string
+	missing error
+}
+
+// linkSlots is the view of a link that the key generation, extraction,
+// entity loading and link writes shared by every link type work on.
+type linkSlots struct {
+	row   *Row
+	slots []linkSlot
+	// multi is the MultiLink whose EntityKeys hold the keys of the slots,
+	// or nil for MonoLink, DiLink and TriLink, whose key fields hold them.
+	multi *MultiLink
+}
+
+// slotted is implemented by every link type, and by the types that embed
+// one. MonoLink, DiLink and TriLink bind the slots of the MultiLink they
+// embed to their typed fields.
+type slotted interface {
+	slots() linkSlots
+}
+
+func isNilEntity(entity types.Linkable) bool {
+	if entity == nil {
+		return true
+	}
+	v := reflect.ValueOf(entity)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// generate generates the composite key of the link from its entities, and
+// stores the key of each entity in the slot's attributes. An entity that is
+// nil is read from the attributes instead. Keys too long to be stored are
+// hashed.
+func (l linkSlots) generate() (string, string, error) {
+	l.row.PartitionKey = ""
+	l.row.SortKey = ""
+	for i, slot := range l.slots {
+		entityType, entityPk, entitySk, err := slot.entityKeys(i)
+		if err != nil {
+			return "", "", err
+		}
+
+		// The slot's pk is the stored key of the entity, not escaped, so it
+		// can be used to get the entity and to find the link on the slot's GSI.
+		stored, err := addKeySegment(rowType, entityType)
+		if err != nil {
+			return "", "", err
+		}
+		seg, err := addKeySegment(rowPk, entityPk)
+		if err != nil {
+			return "", "", err
+		}
+		*slot.pk = stored + seg
+		*slot.sk = entitySk
+
+		typeLabel, pkLabel, skLabel := slotLabels(i)
+		seg, err = addLinkKeySegment(typeLabel, entityType)
+		if err != nil {
+			return "", "", err
+		}
+		l.row.PartitionKey += seg
+		seg, err = addLinkKeySegment(pkLabel, entityPk)
+		if err != nil {
+			return "", "", err
+		}
+		l.row.PartitionKey += seg
+		seg, err = addLinkKeySegment(skLabel, entitySk)
+		if err != nil {
+			return "", "", err
+		}
+		l.row.SortKey += seg
+	}
+	l.row.PartitionKey = hashLongKey(l.row.PartitionKey, maxPartitionKeyBytes-linkKeyHeadroom)
+	l.row.SortKey = hashLongKey(l.row.SortKey, maxSortKeyBytes)
+	return l.row.PartitionKey, l.row.SortKey, nil
+}
+
+// entityKeys returns the type and keys of the slot's entity, read from the
+// slot's attributes when the entity is nil.
+func (s linkSlot) entityKeys(index int) (entityType, pk, sk string, err error) {
+	if !isNilEntity(s.entity) {
+		pk, sk, err = rowKeys(s.entity, 0)
+		return s.entity.Type(), pk, sk, err
+	}
+	if *s.pk == "" && *s.sk == "" {
+		return "", "", "", fmt.Errorf("Entity%d is nil and E%dpk and E%dsk are empty", index, index, index)
+	}
+	pk, sk = *s.pk, *s.sk
+	if stored, ok := storedRowPk(pk); ok {
+		pk = stored
+	}
+	if t, ok := storedRowType(*s.pk); ok {
+		return t, pk, sk, nil
+	}
+	if s.entity == nil {
+		return "", "", "", fmt.Errorf("the type of Entity%d is unknown, E%dpk is not a stored key", index, index)
+	}
+	return s.entity.Type(), pk, sk, nil
+}
+
+// extract returns the stored key of the entity at index. It is read from
+// the slot's attributes, or else extracted from the composite key of the
+// link, which is generated first if it is not set.
+func (l linkSlots) extract(index int) (string, string, error) {
+	if l.row.PartitionKey == "" || l.row.SortKey == "" {
+		if _, _, err := l.generate(); err != nil {
+			return "", "", err
+		}
+	}
+	slot := l.slots[index]
+	if *slot.pk != "" && *slot.sk != "" {
+		return *slot.pk, *slot.sk, nil
+	}
+	for _, key := range []string{l.row.PartitionKey, l.row.SortKey} {
+		if isHashedKey(key) {
+			return "", "", ErrHashedKey{Key: key}
+		}
+	}
+	_, pkLabel, skLabel := slotLabels(index)
+	return extractKeys(pkLabel, l.row.PartitionKey), extractKeys(skLabel, l.row.SortKey), nil
+}
+
+// extractSlot returns the stored key of the entity in the slot, like
+// extract, or ErrInvalidSlot if the link has no such slot.
+func (l linkSlots) extractSlot(slot int) (string, string, error) {
+	if slot < 0 || slot >= len(l.slots) {
+		return "", "", ErrInvalidSlot{Slot: slot}
+	}
+	return l.extract(slot)
+}
+
+// keys generates the composite key of the link and returns the keys of the
+// GSI: the composite key for the primary keys, and Pk1 and Sk1 for GSI 1
+// when they are set.
+func (l linkSlots) keys(gsi int) (string, string, error) {
+	if _, _, err := l.generate(); err != nil {
+		return "", "", err
+	}
+	switch gsi {
+	case 0: // Primary keys
+		return l.row.PartitionKey, l.row.SortKey, nil
+	case 1:
+		if l.row.Pk1 != nil && l.row.Sk1 != nil {
+			return *l.row.Pk1, *l.row.Sk1, nil
+		}
+	}
+	return "", "", ErrInvalidGSI{GSI: gsi}
+}
+
+// entityKey returns the key the entity at index is stored with.
+func (l linkSlots) entityKey(index int) (string, string, error) {
+	slot := l.slots[index]
+	if isNilEntity(slot.entity) {
+		return l.extract(index)
+	}
+	pk, sk, err := rowKeys(slot.entity, 0)
+	if err != nil {
+		return "", "", err
+	}
+	pk, err = prependWithRowType(slot.entity, pk)
+	return pk, sk, err
+}
+
+// loadLinkedEntity gets the entity at index into entity, which may point to
+// a nil pointer, and reports whether it was found.
+func loadLinkedEntity[T types.Linkable](ctx context.Context, l linkSlots, index int, entity *T) (bool, error) {
+	pk, sk, err := l.entityKey(index)
+	if err != nil {
+		return false, err
+	}
+	tn := l.row.TableName(ctx)
+	client, err := l.row.client(ctx)
+	if err != nil {
+		return false, err
+	}
+	out, err := client.Dynamo().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &tn,
+		Key: map[string]awstypes.AttributeValue{
+			"pk": &awstypes.AttributeValueMemberS{Value: pk},
+			"sk": &awstypes.AttributeValueMemberS{Value: sk},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	if out.Item == nil {
+		return false, nil
+	}
+	if err := validateDynamoRowType[T](out.Item, *entity); err != nil {
+		return false, err
+	}
+	if err := attributevalue.UnmarshalMap(out.Item, entity); err != nil {
+		return false, err
+	}
+	return true, afterGet(ctx, client, *entity)
+}
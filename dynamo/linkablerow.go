@@ -23,7 +23,7 @@ func (r LinkableRow[T]) TableName(ctx context.Context) string {
 }
 
 func (r LinkableRow[T]) Keys(gsi int) (string, string, error) {
-	return r.Object.Keys(gsi)
+	return rowKeys(r.Object, gsi)
 }
 
 func (r LinkableRow[T]) MaxShard() int {
@@ -0,0 +1,167 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/types"
+)
+
+// ErrNoFixedEntity is returned by the fan-out getters of a link when none of
+// the entities that select the links is set.
+type ErrNoFixedEntity struct{}
+
+func (e ErrNoFixedEntity) Error() string {
+	return "no entity is set to find the links by"
+}
+
+// fixedEntities returns the entities of the slots that are set, by slot.
+func fixedEntities(entities ...types.Linkable) map[int]types.Linkable {
+	fixed := map[int]types.Linkable{}
+	for i, entity := range entities {
+		if !isNilEntity(entity) {
+			fixed[i] = entity
+		}
+	}
+	return fixed
+}
+
+// findLinkItems returns the items of the links of the type whose entities
+// in the slots of fixed are those entities. The links are queried on the
+// GSI of the lowest fixed slot and filtered on the others.
+func findLinkItems(ctx context.Context, d *DBManager, linkType string, fixed map[int]types.Linkable) ([]map[string]awstypes.AttributeValue, error) {
+	first := -1
+	for i := range fixed {
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	if first < 0 {
+		return nil, ErrNoFixedEntity{}
+	}
+	client, err := d.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	input, err := entityGSIQueryInput(ctx, fixed[first], EntitySlot(first), linkType)
+	if err != nil {
+		return nil, err
+	}
+	var filters []string
+	if input.FilterExpression != nil {
+		filters = append(filters, *input.FilterExpression)
+	}
+	for i, entity := range fixed {
+		if i == first {
+			continue
+		}
+		pk, sk, err := rowKeys(entity, 0)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := prependWithRowType(entity, pk)
+		if err != nil {
+			return nil, err
+		}
+		_, pkLabel, skLabel := slotLabels(i)
+		filters = append(filters, fmt.Sprintf("%s = :%s AND %s = :%s", pkLabel, pkLabel, skLabel, skLabel))
+		input.ExpressionAttributeValues[":"+pkLabel.String()] = &awstypes.AttributeValueMemberS{Value: stored}
+		input.ExpressionAttributeValues[":"+skLabel.String()] = &awstypes.AttributeValueMemberS{Value: sk}
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+	it := newIterator[map[string]awstypes.AttributeValue](client, input, nil)
+	it.decode = func(item map[string]awstypes.AttributeValue) (map[string]awstypes.AttributeValue, error) {
+		return item, nil
+	}
+	return it.All(ctx)
+}
+
+// slotKey returns the key of the entity in the slot, as stored in the item
+// of a link.
+func slotKey(item map[string]awstypes.AttributeValue, index int) (EntityKey, bool) {
+	_, pkLabel, skLabel := slotLabels(index)
+	pk, ok := item[pkLabel.String()].(*awstypes.AttributeValueMemberS)
+	if !ok {
+		return EntityKey{}, false
+	}
+	sk, ok := item[skLabel.String()].(*awstypes.AttributeValueMemberS)
+	if !ok {
+		return EntityKey{}, false
+	}
+	return EntityKey{Pk: pk.Value, Sk: sk.Value}, true
+}
+
+// batchLoadEntities loads the entities stored under the keys with batched
+// reads, each key once. Entities that do not exist are left out.
+func batchLoadEntities[T types.Linkable](ctx context.Context, d *DBManager, keys []EntityKey) (map[EntityKey]T, error) {
+	var unique []EntityKey
+	seen := map[EntityKey]bool{}
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+	entities := make([]T, len(unique))
+	rows := make([]types.Linkable, len(unique))
+	for i := range unique {
+		entities[i] = newRow[T]()
+		rows[i] = entities[i]
+	}
+	results := d.batchGet(ctx, rows, func(i int) (map[string]awstypes.AttributeValue, error) {
+		return map[string]awstypes.AttributeValue{
+			"pk": &awstypes.AttributeValueMemberS{Value: unique[i].Pk},
+			"sk": &awstypes.AttributeValueMemberS{Value: unique[i].Sk},
+		}, nil
+	})
+	loaded := make(map[EntityKey]T, len(unique))
+	for i, result := range results {
+		if errors.As(result.Error, new(*ErrItemNotFound)) {
+			continue
+		}
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if item := getRowData(entities[i]); item != nil {
+			if err := validateDynamoRowType[T](item, entities[i]); err != nil {
+				return nil, err
+			}
+		}
+		loaded[unique[i]] = entities[i]
+	}
+	return loaded, nil
+}
+
+// loadSlotEntities returns the entity in slot want of every link of the
+// type whose entities in the slots of fixed are those entities, in the order
+// of the links, each entity once. Entities are loaded with batched reads.
+func loadSlotEntities[T types.Linkable](ctx context.Context, d *DBManager, linkType string, want int, fixed map[int]types.Linkable) ([]T, error) {
+	items, err := findLinkItems(ctx, d, linkType, fixed)
+	if err != nil {
+		return nil, err
+	}
+	var keys []EntityKey
+	for _, item := range items {
+		if key, ok := slotKey(item, want); ok {
+			keys = append(keys, key)
+		}
+	}
+	loaded, err := batchLoadEntities[T](ctx, d, keys)
+	if err != nil {
+		return nil, err
+	}
+	var entities []T
+	for _, key := range keys {
+		if entity, ok := loaded[key]; ok {
+			entities = append(entities, entity)
+			delete(loaded, key)
+		}
+	}
+	return entities, nil
+}
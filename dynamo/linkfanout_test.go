@@ -0,0 +1,104 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/entegral/gobox/memdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Grant links a User to an Org with a Role.
+type Grant struct {
+	TriLink[*User, *Org, *Role]
+}
+
+func (g *Grant) Type() string {
+	return "grant"
+}
+
+func names[T any](entities []T, name func(T) string) []string {
+	var out []string
+	for _, e := range entities {
+		out = append(out, name(e))
+	}
+	return out
+}
+
+func TestTriLinkFanOut(t *testing.T) {
+	// A batch capacity below the number of keys makes BatchGetItem return
+	// unprocessed keys, which must be retried.
+	useMemDB(t, memdb.WithBatchCapacity(3))
+	ctx := context.Background()
+
+	var users []*User
+	for i := 0; i < 5; i++ {
+		user := &User{Email: fmt.Sprintf("user%d@example.com", i)}
+		require.NoError(t, user.Put(ctx, user))
+		users = append(users, user)
+	}
+	acme, initech := &Org{Name: "acme"}, &Org{Name: "initech"}
+	admin, viewer := &Role{Name: "admin"}, &Role{Name: "viewer"}
+	require.NoError(t, acme.Put(ctx, acme))
+	require.NoError(t, initech.Put(ctx, initech))
+	require.NoError(t, admin.Put(ctx, admin))
+	require.NoError(t, viewer.Put(ctx, viewer))
+
+	grant := func(user *User, org *Org, role *Role) {
+		g := &Grant{TriLink: *NewTriLink(user, org, role)}
+		require.NoError(t, g.Link(ctx, g))
+	}
+	grant(users[0], acme, admin)
+	grant(users[1], acme, viewer)
+	grant(users[2], acme, viewer)
+	grant(users[0], acme, viewer)
+	grant(users[3], initech, admin)
+	grant(users[4], acme, admin)
+	require.NoError(t, users[4].Delete(ctx, users[4]))
+
+	email := func(u *User) string { return u.Email }
+	name := func(o *Org) string { return o.Name }
+	roleName := func(r *Role) string { return r.Name }
+
+	t.Run("fixes one entity", func(t *testing.T) {
+		g := &Grant{TriLink: TriLink[*User, *Org, *Role]{DiLink: DiLink[*User, *Org]{Entity1: acme}}}
+		got, err := g.LoadEntity0s(ctx, g)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"user0@example.com", "user1@example.com", "user2@example.com"}, names(got, email))
+	})
+	t.Run("fixes two entities", func(t *testing.T) {
+		g := &Grant{TriLink: TriLink[*User, *Org, *Role]{DiLink: DiLink[*User, *Org]{Entity1: acme}, Entity2: viewer}}
+		got, err := g.LoadEntity0s(ctx, g)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"user0@example.com", "user1@example.com", "user2@example.com"}, names(got, email))
+
+		g.Entity2 = admin
+		got, err = g.LoadEntity0s(ctx, g)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user0@example.com"}, names(got, email))
+
+		g = &Grant{TriLink: TriLink[*User, *Org, *Role]{DiLink: DiLink[*User, *Org]{Entity1: acme}}}
+		g.Entity0 = users[0]
+		roles, err := g.LoadEntity2s(ctx, g)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"admin", "viewer"}, names(roles, roleName))
+	})
+	t.Run("fixes the middle entity by the others", func(t *testing.T) {
+		g := &Grant{TriLink: TriLink[*User, *Org, *Role]{Entity2: admin}}
+		orgs, err := g.LoadEntity1s(ctx, g)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"acme", "initech"}, names(orgs, name))
+	})
+	t.Run("matches links of every type", func(t *testing.T) {
+		items, err := findLinkItems(ctx, &DBManager{}, "", fixedEntities(nil, acme, viewer))
+		require.NoError(t, err)
+		assert.Len(t, items, 3)
+	})
+	t.Run("requires a fixed entity", func(t *testing.T) {
+		g := &Grant{}
+		_, err := g.LoadEntity0s(ctx, g)
+		assert.Equal(t, ErrNoFixedEntity{}, err)
+	})
+}
@@ -0,0 +1,169 @@
+package dynamo
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+)
+
+// MigratedLink is a link that MigrateLinkKeys moved to the key generated for
+// it now.
+type MigratedLink struct {
+	// From is the key the link was stored with.
+	From EntityKey
+	// To is the key the link is stored with now.
+	To EntityKey
+}
+
+// MigrateLinkKeys moves the links of the link's type that are stored under a
+// key other than the one generated for their entities now, such as links
+// written before link key values were escaped, whose entities have a %, (,
+// ) or / in their keys. Until they are moved, Get, Unlink and Delete address
+// them by the new key and miss them, and Link writes a second link next to
+// them.
+//
+// Every link of the type is read, like FindOrphanLinks, so it is meant to
+// run once, or as a periodic sweep. Each link is moved in a transaction that
+// puts it under its new key, deletes it under the old one and makes the
+// sentinels that record the old key for the link's Cardinality record the
+// new one. A link whose new key is already taken, by a link written since,
+// is deleted rather than moved. It returns the links it moved.
+func (d *DBManager) MigrateLinkKeys(ctx context.Context, link types.Linkable) ([]MigratedLink, error) {
+	client, err := d.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tn := d.TableName(ctx)
+	var migrated []MigratedLink
+	err = d.eachTypeShard(ctx, link, func(items []map[string]awstypes.AttributeValue) error {
+		for _, item := range items {
+			moved, ok, err := d.migrateLinkKey(ctx, client, tn, link, item)
+			if err != nil {
+				return err
+			}
+			if ok {
+				migrated = append(migrated, moved)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return migrated, nil
+}
+
+// migrateLinkKey moves the link stored as item to the key generated for it
+// now. It returns false if the link is already stored under that key.
+func (d *DBManager) migrateLinkKey(ctx context.Context, client *clients.Client, tablename string, link types.Linkable, item map[string]awstypes.AttributeValue) (MigratedLink, bool, error) {
+	from, ok := itemKey(item)
+	if !ok {
+		return MigratedLink{}, false, nil
+	}
+	to, ok, err := generatedLinkKey(link, item)
+	if err != nil || !ok || to == from {
+		return MigratedLink{}, false, err
+	}
+	key := map[string]awstypes.AttributeValue{
+		"pk": &awstypes.AttributeValueMemberS{Value: to.Pk},
+		"sk": &awstypes.AttributeValueMemberS{Value: to.Sk},
+	}
+	taken, err := consistentGet(ctx, client, tablename, key)
+	if err != nil {
+		return MigratedLink{}, false, err
+	}
+	sentinels, err := d.recordedSentinels(ctx, []map[string]awstypes.AttributeValue{item})
+	if err != nil {
+		return MigratedLink{}, false, err
+	}
+	tx := NewTransaction()
+	if taken == nil {
+		moved := make(map[string]awstypes.AttributeValue, len(item))
+		for name, value := range item {
+			moved[name] = value
+		}
+		moved["pk"], moved["sk"] = key["pk"], key["sk"]
+		tx.putNewItem(moved)
+	}
+	tx.Delete(newStoredRow(from))
+	for _, s := range sentinels {
+		tx.moveSentinel(s.key, from, to)
+	}
+	if _, err := tx.WithClient(client).WithTableName(tablename).Exec(ctx); err != nil {
+		return MigratedLink{}, false, err
+	}
+	return MigratedLink{From: from, To: to}, true, nil
+}
+
+// generatedLinkKey returns the key the link stored as item is stored with
+// when it is written now, generated from the entity keys in its slot
+// attributes. It returns false if the item has none.
+func generatedLinkKey(link types.Linkable, item map[string]awstypes.AttributeValue) (EntityKey, bool, error) {
+	l := linkSlots{row: &Row{}}
+	for i := 0; ; i++ {
+		key, ok := slotKey(item, i)
+		if !ok {
+			break
+		}
+		l.slots = append(l.slots, linkSlot{pk: &key.Pk, sk: &key.Sk})
+	}
+	if len(l.slots) == 0 {
+		return EntityKey{}, false, nil
+	}
+	pk, sk, err := l.generate()
+	if err != nil {
+		return EntityKey{}, false, err
+	}
+	stored, err := prependWithRowType(link, pk)
+	if err != nil {
+		return EntityKey{}, false, err
+	}
+	return EntityKey{Pk: stored, Sk: sk}, true, nil
+}
+
+// putNewItem puts the item on the condition that no item has its key.
+func (t *Transaction) putNewItem(item map[string]awstypes.AttributeValue) *Transaction {
+	return t.add(transactOp{build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+		if tablename == "" {
+			tablename = clients.TableName(ctx)
+		}
+		expr, names, values, err := AttributeNotExists("pk").Render()
+		if err != nil {
+			return awstypes.TransactWriteItem{}, err
+		}
+		return awstypes.TransactWriteItem{Put: &awstypes.Put{
+			TableName:                 aws.String(tablename),
+			Item:                      item,
+			ConditionExpression:       aws.String(expr),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}}, nil
+	}})
+}
+
+// moveSentinel makes the sentinel that records the link from record the
+// link to instead.
+func (t *Transaction) moveSentinel(key map[string]awstypes.AttributeValue, from, to EntityKey) *Transaction {
+	return t.add(transactOp{build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+		if tablename == "" {
+			tablename = clients.TableName(ctx)
+		}
+		e := newExpression("M")
+		update := "SET " + e.name(sentinelLinkPk) + " = " + e.value(to.Pk) + ", " + e.name(sentinelLinkSk) + " = " + e.value(to.Sk)
+		expr := recordsLink(from).build(e)
+		if e.err != nil {
+			return awstypes.TransactWriteItem{}, e.err
+		}
+		return awstypes.TransactWriteItem{Update: &awstypes.Update{
+			TableName:                 aws.String(tablename),
+			Key:                       key,
+			UpdateExpression:          aws.String(update),
+			ConditionExpression:       aws.String(expr),
+			ExpressionAttributeNames:  e.names,
+			ExpressionAttributeValues: e.values,
+		}}, nil
+	}})
+}
@@ -0,0 +1,104 @@
+package dynamo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateLinkKeys(t *testing.T) {
+	db := useMemDB(t)
+	ctx := context.Background()
+	table := aws.String("gobox-memdb")
+
+	owner := &User{Email: "legacy/(a)@example.com"}
+	beetle := &Car{Make: "VW", Model: "Beetle", Year: 1970}
+	require.NoError(t, owner.Put(ctx, owner))
+	require.NoError(t, beetle.Put(ctx, beetle))
+
+	// Seed the link and the sentinel of its car as they were stored before
+	// link key values were escaped.
+	title := newOwnership(owner, beetle)
+	require.NoError(t, title.Link(ctx, title))
+	escaped, err := storedKey(title)
+	require.NoError(t, err)
+	legacy := EntityKey{Pk: unescapeSegmentValue(escaped.Pk), Sk: unescapeSegmentValue(escaped.Sk)}
+	require.NotEqual(t, escaped, legacy)
+
+	out, err := (&DBManager{}).GetItem(ctx, title)
+	require.NoError(t, err)
+	item := out.Item
+	item["pk"] = &awstypes.AttributeValueMemberS{Value: legacy.Pk}
+	item["sk"] = &awstypes.AttributeValueMemberS{Value: legacy.Sk}
+	_, err = db.PutItem(ctx, &dynamodb.PutItemInput{TableName: table, Item: item})
+	require.NoError(t, err)
+	key, err := rowKey(title)
+	require.NoError(t, err)
+	_, err = db.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: table, Key: key})
+	require.NoError(t, err)
+	sentinel, err := sentinelKey(title.Type(), 1, EntityKey{Pk: title.E1pk, Sk: title.E1sk})
+	require.NoError(t, err)
+	_, err = db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        table,
+		Key:              sentinel,
+		UpdateExpression: aws.String("SET linkpk = :pk, linksk = :sk"),
+		ExpressionAttributeValues: map[string]awstypes.AttributeValue{
+			":pk": &awstypes.AttributeValueMemberS{Value: legacy.Pk},
+			":sk": &awstypes.AttributeValueMemberS{Value: legacy.Sk},
+		},
+	})
+	require.NoError(t, err)
+
+	link := newOwnership(owner, beetle)
+	assert.False(t, isStored(t, link), "the legacy link is not found by its escaped key")
+
+	migrated, err := (&DBManager{}).MigrateLinkKeys(ctx, &Ownership{})
+	require.NoError(t, err)
+	assert.Equal(t, []MigratedLink{{From: legacy, To: escaped}}, migrated)
+	assert.True(t, isStored(t, link))
+	for _, stored := range db.Items("gobox-memdb") {
+		assert.NotEqual(t, legacy.Pk, stored["pk"].(*awstypes.AttributeValueMemberS).Value)
+	}
+
+	migrated, err = (&DBManager{}).MigrateLinkKeys(ctx, &Ownership{})
+	require.NoError(t, err)
+	assert.Empty(t, migrated, "links under their generated key are left alone")
+
+	// The sentinel records the migrated link, so the car can be unlinked
+	// and linked to another owner.
+	require.NoError(t, link.Unlink(ctx, link))
+	assert.False(t, isStored(t, link))
+	bob := &User{Email: "bob@example.com"}
+	require.NoError(t, bob.Put(ctx, bob))
+	sold := newOwnership(bob, beetle)
+	require.NoError(t, sold.Link(ctx, sold))
+
+	t.Run("a link written again since is kept", func(t *testing.T) {
+		acme, admin := &Org{Name: "acme"}, &Role{Name: "admin"}
+		grant := &Grant{TriLink: *NewTriLink[*User, *Org, *Role](owner, acme, admin)}
+		require.NoError(t, grant.Link(ctx, grant))
+		escaped, err := storedKey(grant)
+		require.NoError(t, err)
+		legacy := EntityKey{Pk: unescapeSegmentValue(escaped.Pk), Sk: unescapeSegmentValue(escaped.Sk)}
+		out, err := (&DBManager{}).GetItem(ctx, grant)
+		require.NoError(t, err)
+		item := out.Item
+		item["pk"] = &awstypes.AttributeValueMemberS{Value: legacy.Pk}
+		item["sk"] = &awstypes.AttributeValueMemberS{Value: legacy.Sk}
+		_, err = db.PutItem(ctx, &dynamodb.PutItemInput{TableName: table, Item: item})
+		require.NoError(t, err)
+
+		migrated, err := (&DBManager{}).MigrateLinkKeys(ctx, &Grant{})
+		require.NoError(t, err)
+		assert.Equal(t, []MigratedLink{{From: legacy, To: escaped}}, migrated)
+		assert.True(t, isStored(t, grant))
+		for _, stored := range db.Items("gobox-memdb") {
+			assert.NotEqual(t, legacy.Pk, stored["pk"].(*awstypes.AttributeValueMemberS).Value)
+		}
+	})
+}
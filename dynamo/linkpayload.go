@@ -0,0 +1,81 @@
+package dynamo
+
+import (
+	"reflect"
+
+	"github.com/entegral/gobox/types"
+)
+
+// DiLinkWith is a DiLink carrying a typed payload, such as the role of a
+// member and the time they joined. The payload is stored in the link's
+// payload attribute, apart from the attributes of the link itself, so its
+// fields cannot collide with them:
+//
+//	type Member struct {
+//		Role     string    `dynamodbav:"role"`
+//		JoinedAt time.Time `dynamodbav:"joinedAt"`
+//	}
+//
+//	func (m Member) LinkSort() string { return dynamo.SortTime(m.JoinedAt) }
+//
+//	type Membership struct {
+//		dynamo.DiLinkWith[*User, *Org, Member]
+//	}
+//
+//	m := &Membership{DiLinkWith: *dynamo.NewDiLinkWith(user, org, Member{Role: "admin", JoinedAt: time.Now()})}
+//	err := m.Link(ctx, m)
+//
+// Links loaded with the FindLinks helpers have their payload set. If the
+// payload implements LinkSorter, the links are ordered by it on the sorted
+// entity GSIs.
+type DiLinkWith[T0, T1 types.Linkable, P any] struct {
+	DiLink[T0, T1]
+
+	Payload P `dynamodbav:"payload" json:"payload"`
+}
+
+// NewDiLinkWith creates a new DiLinkWith instance.
+func NewDiLinkWith[T0, T1 types.Linkable, P any](entity0 T0, entity1 T1, payload P) *DiLinkWith[T0, T1, P] {
+	return &DiLinkWith[T0, T1, P]{DiLink: *NewDiLink(entity0, entity1), Payload: payload}
+}
+
+// LinkSort returns the sort value of the payload, or "" if it does not
+// implement LinkSorter.
+func (m *DiLinkWith[T0, T1, P]) LinkSort() string {
+	return payloadSort(&m.Payload)
+}
+
+// TriLinkWith is a TriLink carrying a typed payload, stored like the payload
+// of a DiLinkWith.
+type TriLinkWith[T0, T1, T2 types.Linkable, P any] struct {
+	TriLink[T0, T1, T2]
+
+	Payload P `dynamodbav:"payload" json:"payload"`
+}
+
+// NewTriLinkWith creates a new TriLinkWith instance.
+func NewTriLinkWith[T0, T1, T2 types.Linkable, P any](entity0 T0, entity1 T1, entity2 T2, payload P) *TriLinkWith[T0, T1, T2, P] {
+	return &TriLinkWith[T0, T1, T2, P]{TriLink: *NewTriLink(entity0, entity1, entity2), Payload: payload}
+}
+
+// LinkSort returns the sort value of the payload, or "" if it does not
+// implement LinkSorter.
+func (m *TriLinkWith[T0, T1, T2, P]) LinkSort() string {
+	return payloadSort(&m.Payload)
+}
+
+// payloadSort returns the sort value of the payload, whose LinkSort method
+// may have a pointer or a value receiver.
+func payloadSort[P any](payload *P) string {
+	if sorter, ok := any(payload).(LinkSorter); ok {
+		return sorter.LinkSort()
+	}
+	v := reflect.ValueOf(payload).Elem()
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return ""
+	}
+	if sorter, ok := v.Interface().(LinkSorter); ok {
+		return sorter.LinkSort()
+	}
+	return ""
+}
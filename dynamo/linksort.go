@@ -0,0 +1,172 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+)
+
+// linkSortAttribute is the attribute that orders the links of an entity on
+// the sorted entity GSIs.
+const linkSortAttribute = "linksort"
+
+// LinkSorter is implemented by link types, or the payloads of DiLinkWith and
+// TriLinkWith, that order the links of an entity, for instance by the time
+// they were made:
+//
+//	func (m Membership) LinkSort() string {
+//		return dynamo.SortTime(m.JoinedAt)
+//	}
+//
+// The value is written to the link's linksort attribute, the range key of
+// the sorted entity GSIs, e<N>pk-linksort-index, which FindSortedLinksBySlot
+// and its Entity0 and Entity1 variants query. Values are compared as
+// strings; SortTime and SortInt format times and integers so they sort in
+// order. Links whose value is empty are left out of the sorted GSIs.
+type LinkSorter interface {
+	LinkSort() string
+}
+
+// linkSort returns the sort value of the link, or "".
+func linkSort(row types.Linkable) string {
+	if sorter, ok := row.(LinkSorter); ok {
+		return sorter.LinkSort()
+	}
+	return ""
+}
+
+// sortTimeLayout is RFC 3339 in UTC with a fixed number of fractional
+// digits, so that times sort as strings.
+const sortTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// SortTime formats t as a link sort value, in UTC with nanoseconds.
+func SortTime(t time.Time) string {
+	return t.UTC().Format(sortTimeLayout)
+}
+
+// SortInt formats n as a link sort value. Negative numbers sort before
+// positive ones.
+func SortInt(n int64) string {
+	return fmt.Sprintf("%020d", uint64(n)^(1<<63))
+}
+
+// SortedEntitySlot returns the slot at index with its sorted GSI,
+// e<N>pk-linksort-index, whose range key is the linksort attribute.
+func SortedEntitySlot(index int) LinkSlot {
+	return LinkSlot{Index: index, GSI: EntityGSI(fmt.Sprintf("e%dpk-%s-index", index, linkSortAttribute))}
+}
+
+// LinkRange selects and orders links by their sort value. From and To are
+// inclusive bounds, formatted like the values returned by LinkSort; an
+// empty bound leaves that end open. The zero LinkRange selects every sorted
+// link in ascending order.
+type LinkRange struct {
+	From, To   string
+	Descending bool
+}
+
+// sortedLinksQueryInput returns the query for the links of the type whose
+// entity in the slot is entity, in the range, on the slot's sorted GSI.
+func sortedLinksQueryInput(ctx context.Context, entity types.Linkable, slot LinkSlot, linkType string, r LinkRange) (*dynamodb.QueryInput, error) {
+	if slot.Index < 0 || slot.GSI == "" {
+		return nil, fmt.Errorf("invalid link slot %d with GSI %q", slot.Index, slot.GSI)
+	}
+	_, epkKey, eskKey := slotLabels(slot.Index)
+	ePk, eSk, err := rowKeys(entity, 0)
+	if err != nil {
+		return nil, err
+	}
+	linkedPk, err := prependWithRowType(entity, ePk)
+	if err != nil {
+		return nil, err
+	}
+
+	kce := fmt.Sprintf("%s = :pk", epkKey)
+	values := map[string]awstypes.AttributeValue{
+		":pk": &awstypes.AttributeValueMemberS{Value: linkedPk},
+		":sk": &awstypes.AttributeValueMemberS{Value: eSk},
+	}
+	switch {
+	case r.From != "" && r.To != "":
+		kce += " AND #sort BETWEEN :from AND :to"
+	case r.From != "":
+		kce += " AND #sort >= :from"
+	case r.To != "":
+		kce += " AND #sort <= :to"
+	}
+	if r.From != "" {
+		values[":from"] = &awstypes.AttributeValueMemberS{Value: r.From}
+	}
+	if r.To != "" {
+		values[":to"] = &awstypes.AttributeValueMemberS{Value: r.To}
+	}
+	names := map[string]string{}
+	if r.From != "" || r.To != "" {
+		names["#sort"] = linkSortAttribute
+	}
+	// The GSI is keyed on the entity's partition key only, so entities that
+	// share it are told apart by their sort key.
+	filters := []string{fmt.Sprintf("%s = :sk", eskKey)}
+	// An empty link type matches the links of every type.
+	if linkType != "" {
+		filters = append(filters, "#type = :type")
+		names["#type"] = "type"
+		values[":type"] = &awstypes.AttributeValueMemberS{Value: linkType}
+	}
+	fe := strings.Join(filters, " AND ")
+	// DynamoDB rejects an empty ExpressionAttributeNames.
+	if len(names) == 0 {
+		names = nil
+	}
+	tn := entity.TableName(ctx)
+	index := slot.GSI.String()
+	return &dynamodb.QueryInput{
+		TableName:                 &tn,
+		IndexName:                 &index,
+		KeyConditionExpression:    &kce,
+		FilterExpression:          &fe,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(!r.Descending),
+	}, nil
+}
+
+// IterSortedLinksBySlot returns an iterator over the links of the provided
+// type whose entity in the slot is entity, ordered by their sort value and
+// limited to the range. The slot's GSI must be a sorted GSI, such as the one
+// returned by SortedEntitySlot. An empty link type matches the links of every
+// type.
+func IterSortedLinksBySlot[T, CustomLinkType types.Linkable](ctx context.Context, entity T, slot LinkSlot, linkType string, r LinkRange) *Iterator[CustomLinkType] {
+	client, err := clients.Resolve(ctx)
+	var input *dynamodb.QueryInput
+	if err == nil {
+		input, err = sortedLinksQueryInput(ctx, entity, slot, linkType, r)
+	}
+	return newIterator[CustomLinkType](client, input, err)
+}
+
+// FindSortedLinksBySlot returns the links of the provided type whose entity
+// in the slot is entity, ordered by their sort value and limited to the
+// range.
+func FindSortedLinksBySlot[T, CustomLinkType types.Linkable](ctx context.Context, entity T, slot LinkSlot, linkType string, r LinkRange) ([]CustomLinkType, error) {
+	return IterSortedLinksBySlot[T, CustomLinkType](ctx, entity, slot, linkType, r).All(ctx)
+}
+
+// FindSortedLinksByEntity0 returns the links of the provided type whose
+// Entity0 is e0, ordered by their sort value and limited to the range.
+func FindSortedLinksByEntity0[T0, CustomLinkType types.Linkable](ctx context.Context, e0 T0, linkType string, r LinkRange) ([]CustomLinkType, error) {
+	return FindSortedLinksBySlot[T0, CustomLinkType](ctx, e0, SortedEntitySlot(0), linkType, r)
+}
+
+// FindSortedLinksByEntity1 returns the links of the provided type whose
+// Entity1 is e1, ordered by their sort value and limited to the range.
+func FindSortedLinksByEntity1[T1, CustomLinkType types.Linkable](ctx context.Context, e1 T1, linkType string, r LinkRange) ([]CustomLinkType, error) {
+	return FindSortedLinksBySlot[T1, CustomLinkType](ctx, e1, SortedEntitySlot(1), linkType, r)
+}
@@ -0,0 +1,159 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Member is the payload of an OrgMembership.
+type Member struct {
+	Role     string    `dynamodbav:"role"`
+	JoinedAt time.Time `dynamodbav:"joinedAt"`
+}
+
+func (m Member) LinkSort() string {
+	return SortTime(m.JoinedAt)
+}
+
+type OrgMembership struct {
+	DiLinkWith[*User, *Org, Member]
+}
+
+func (m *OrgMembership) Type() string {
+	return "orgMembership"
+}
+
+type Assignment struct {
+	DiLinkWith[*User, *Project, *Member]
+}
+
+func (a *Assignment) Type() string {
+	return "assignment"
+}
+
+func TestSortValues(t *testing.T) {
+	ints := []int64{-1 << 62, -10, -1, 0, 1, 9, 10, 1 << 62}
+	var values []string
+	for _, n := range ints {
+		values = append(values, SortInt(n))
+	}
+	assert.True(t, sort.StringsAreSorted(values), values)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	assert.Equal(t, "2024-05-01T10:00:00.000000000Z", SortTime(base))
+	assert.Less(t, SortTime(base), SortTime(base.Add(time.Nanosecond)))
+	assert.Less(t, SortTime(base.Add(time.Nanosecond)), SortTime(base.Add(time.Second)))
+
+	assert.Equal(t, "", NewDiLinkWith[*User, *Project, *Member](nil, nil, nil).LinkSort())
+	assert.Equal(t, SortTime(base), NewDiLinkWith[*User, *Project](nil, nil, &Member{JoinedAt: base}).LinkSort())
+}
+
+func TestSortedLinks(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	acme := &Org{Name: "acme"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var users []*User
+	// Links are written out of order.
+	for _, day := range []int{3, 0, 4, 1, 2} {
+		user := &User{Email: fmt.Sprintf("day%d@example.com", day)}
+		users = append(users, user)
+		m := &OrgMembership{DiLinkWith: *NewDiLinkWith(user, acme, Member{Role: "member", JoinedAt: start.AddDate(0, 0, day)})}
+		require.NoError(t, m.Link(ctx, m))
+	}
+	emails := func(links []*OrgMembership) []string {
+		var out []string
+		for _, link := range links {
+			pk, _, err := link.ExtractE0Keys()
+			require.NoError(t, err)
+			out = append(out, pk)
+		}
+		return out
+	}
+	stored := func(days ...int) []string {
+		var out []string
+		for _, day := range days {
+			out = append(out, fmt.Sprintf("/rowType(user)/rowPk(day%d@example.com)", day))
+		}
+		return out
+	}
+
+	t.Run("orders the links by their sort value", func(t *testing.T) {
+		links, err := FindSortedLinksByEntity1[*Org, *OrgMembership](ctx, acme, "orgMembership", LinkRange{})
+		require.NoError(t, err)
+		assert.Equal(t, stored(0, 1, 2, 3, 4), emails(links))
+		assert.Equal(t, Member{Role: "member", JoinedAt: start}, links[0].Payload)
+
+		links, err = FindSortedLinksByEntity1[*Org, *OrgMembership](ctx, acme, "orgMembership", LinkRange{Descending: true})
+		require.NoError(t, err)
+		assert.Equal(t, stored(4, 3, 2, 1, 0), emails(links))
+	})
+	t.Run("filters the links by range", func(t *testing.T) {
+		links, err := FindSortedLinksByEntity1[*Org, *OrgMembership](ctx, acme, "orgMembership", LinkRange{
+			From: SortTime(start.AddDate(0, 0, 1)),
+			To:   SortTime(start.AddDate(0, 0, 3)),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, stored(1, 2, 3), emails(links))
+
+		links, err = FindSortedLinksByEntity1[*Org, *OrgMembership](ctx, acme, "orgMembership", LinkRange{From: SortTime(start.AddDate(0, 0, 3))})
+		require.NoError(t, err)
+		assert.Equal(t, stored(3, 4), emails(links))
+
+		links, err = FindSortedLinksByEntity1[*Org, *OrgMembership](ctx, acme, "orgMembership", LinkRange{To: SortTime(start), Descending: true})
+		require.NoError(t, err)
+		assert.Equal(t, stored(0), emails(links))
+	})
+	t.Run("finds the links of Entity0", func(t *testing.T) {
+		initech := &Org{Name: "initech"}
+		m := &OrgMembership{DiLinkWith: *NewDiLinkWith(users[0], initech, Member{Role: "admin", JoinedAt: start})}
+		require.NoError(t, m.Link(ctx, m))
+
+		links, err := FindSortedLinksByEntity0[*User, *OrgMembership](ctx, users[0], "orgMembership", LinkRange{})
+		require.NoError(t, err)
+		require.Len(t, links, 2)
+		assert.Equal(t, "admin", links[0].Payload.Role)
+		assert.Equal(t, "member", links[1].Payload.Role)
+	})
+	t.Run("matches the entity's sort key", func(t *testing.T) {
+		rockets := &Project{Org: "acme", Name: "rockets"}
+		lasers := &Project{Org: "acme", Name: "lasers"}
+		a := &Assignment{DiLinkWith: *NewDiLinkWith(users[0], rockets, &Member{JoinedAt: start})}
+		b := &Assignment{DiLinkWith: *NewDiLinkWith(users[1], lasers, &Member{JoinedAt: start})}
+		unsorted := &Assignment{DiLinkWith: *NewDiLinkWith[*User, *Project, *Member](users[2], rockets, nil)}
+		for _, link := range []*Assignment{a, b, unsorted} {
+			require.NoError(t, link.Link(ctx, link))
+		}
+
+		links, err := FindSortedLinksByEntity1[*Project, *Assignment](ctx, rockets, "assignment", LinkRange{})
+		require.NoError(t, err)
+		require.Len(t, links, 1, "the link without a sort value is left out")
+		assert.Equal(t, a.E0pk, links[0].E0pk)
+
+		all, err := FindLinksByEntity1[*Project, *Assignment](ctx, rockets, "assignment")
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+	t.Run("matches the links of every type", func(t *testing.T) {
+		input, err := sortedLinksQueryInput(ctx, users[0], SortedEntitySlot(0), "", LinkRange{})
+		require.NoError(t, err)
+		assert.Equal(t, "e0sk = :sk", *input.FilterExpression)
+		assert.Nil(t, input.ExpressionAttributeNames)
+		assert.NotContains(t, input.ExpressionAttributeValues, ":type")
+
+		links, err := FindSortedLinksByEntity0[*User, *OrgMembership](ctx, users[0], "", LinkRange{})
+		require.NoError(t, err)
+		var types []string
+		for _, link := range links {
+			types = append(types, link.UnmarshalledType)
+		}
+		assert.ElementsMatch(t, []string{"orgMembership", "orgMembership", "assignment"}, types)
+	})
+}
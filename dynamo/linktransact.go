@@ -0,0 +1,92 @@
+package dynamo
+
+import (
+	"context"
+
+	"github.com/entegral/gobox/types"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// entityCheck is the key of an entity row a transactional link requires,
+// and the error reported when that row does not exist.
+type entityCheck struct {
+	key map[string]awstypes.AttributeValue
+	err error
+}
+
+func newEntityCheck(pk, sk string, err error) entityCheck {
+	return entityCheck{
+		key: map[string]awstypes.AttributeValue{
+			"pk": &awstypes.AttributeValueMemberS{Value: pk},
+			"sk": &awstypes.AttributeValueMemberS{Value: sk},
+		},
+		err: err,
+	}
+}
+
+// entityChecks returns the entity rows the link requires. The link keys must
+// have been generated. A slot without its own missing error reports an
+// ErrEntityNotFound of the stored entity.
+func (l linkSlots) entityChecks() []entityCheck {
+	checks := make([]entityCheck, len(l.slots))
+	for i, slot := range l.slots {
+		missing := slot.missing
+		if missing == nil {
+			missing = ErrEntityNotFound[types.Linkable]{Entity: newStoredRow(EntityKey{Pk: *slot.pk, Sk: *slot.sk})}
+		}
+		checks[i] = newEntityCheck(*slot.pk, *slot.sk, missing)
+	}
+	return checks
+}
+
+// TransactLink writes the link in a transaction that checks every entity
+// row exists, so a link is never written for an entity deleted after it was
+// loaded. If an entity does not exist, nothing is written and the returned
+// ErrTransactionCanceled wraps an ErrEntityNotFound for each missing entity.
+func (m *MultiLink) TransactLink(ctx context.Context, row types.Linkable) error {
+	l := m.slotsOf(row)
+	return m.transactPut(ctx, row, l, l.entityChecks)
+}
+
+// TransactUnlink deletes the link in a transaction that checks every entity
+// row exists. Use Unlink to remove a link whose entities are already gone.
+func (m *MultiLink) TransactUnlink(ctx context.Context, row types.Linkable) error {
+	l := m.slotsOf(row)
+	return m.transactDelete(ctx, row, l, l.entityChecks)
+}
+
+// transactPut puts the row together with a ConditionCheck for every entity
+// returned by checks, and claims the sentinels of the link's cardinality.
+// checks is called after the row's keys are generated and may be nil.
+func (d *DBManager) transactPut(ctx context.Context, row types.Linkable, l linkSlots, checks func() []entityCheck) error {
+	return d.transactLinkWrite(ctx, row, l, NewTransaction().Put(row), false, checks)
+}
+
+// transactDelete deletes the row together with a ConditionCheck for every
+// entity returned by checks, and releases the sentinels of the link's
+// cardinality. checks is called after the row's keys are generated and may
+// be nil.
+func (d *DBManager) transactDelete(ctx context.Context, row types.Linkable, l linkSlots, checks func() []entityCheck) error {
+	return d.transactLinkWrite(ctx, row, l, NewTransaction().Delete(row), true, checks)
+}
+
+func (d *DBManager) transactLinkWrite(ctx context.Context, row types.Linkable, l linkSlots, tx *Transaction, release bool, checks func() []entityCheck) error {
+	if _, _, err := rowKeys(row, 0); err != nil {
+		return err
+	}
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+	if checks != nil {
+		for _, check := range checks() {
+			tx.checkExists(check.key, check.err)
+		}
+	}
+	if err := d.addSentinels(ctx, client, tx, row, l, release); err != nil {
+		return err
+	}
+	_, err = tx.WithClient(client).WithTableName(d.TableName(ctx)).Exec(ctx)
+	return err
+}
@@ -0,0 +1,79 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactLink(t *testing.T) {
+	db := useMemDB(t)
+	ctx := context.Background()
+
+	user := &User{Email: "transact@gmail.com", Name: "Transact", Age: 30}
+	car := &Car{Make: "TransactMake", Model: "TransactModel", Year: 2024}
+	require.NoError(t, user.Put(ctx, user))
+
+	t.Run("DiLink is not written when an entity is missing", func(t *testing.T) {
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car), VIN: "1"}
+		err := slip.TransactLink(ctx, slip)
+		var notFound ErrEntityNotFound[*Car]
+		require.True(t, errors.As(err, &notFound))
+		assert.Equal(t, car, notFound.Entity)
+		assert.False(t, errors.As(err, new(ErrEntityNotFound[*User])))
+		assert.Len(t, db.Items("gobox-memdb"), 1)
+	})
+	t.Run("DiLink is written when both entities exist", func(t *testing.T) {
+		require.NoError(t, car.Put(ctx, car))
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car), VIN: "1"}
+		require.NoError(t, slip.TransactLink(ctx, slip))
+
+		loaded := &PinkSlip{DiLink: *NewDiLink(user, car)}
+		ok, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "1", loaded.VIN)
+	})
+	t.Run("DiLink unlink requires both entities", func(t *testing.T) {
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car)}
+		require.NoError(t, car.Delete(ctx, car))
+		err := slip.TransactUnlink(ctx, slip)
+		assert.True(t, errors.As(err, new(ErrEntityNotFound[*Car])))
+
+		require.NoError(t, car.Put(ctx, car))
+		require.NoError(t, slip.TransactUnlink(ctx, slip))
+		ok, err := slip.Get(ctx, slip)
+		assert.False(t, ok)
+		assert.IsType(t, &ErrItemNotFound{}, err)
+	})
+	t.Run("MonoLink checks its entity", func(t *testing.T) {
+		stranger := &User{Email: "stranger@gmail.com"}
+		contact := &ContactInfo{MonoLink: NewMonoLink(stranger), Phone: "555"}
+		err := contact.TransactLink(ctx, contact)
+		assert.True(t, errors.As(err, new(ErrEntityNotFound[*User])))
+
+		contact = &ContactInfo{MonoLink: NewMonoLink(user), Phone: "555"}
+		require.NoError(t, contact.TransactLink(ctx, contact))
+	})
+	t.Run("TriLink reports every missing entity", func(t *testing.T) {
+		person := &Person{Name: "TransactPerson"}
+		venue := &Venue{Name: "TransactVenue"}
+		date := &Date{}
+		require.NoError(t, venue.Put(ctx, venue))
+		event := &Event{TriLink: *NewTriLink(person, venue, date), Name: "TransactEvent"}
+		err := event.TransactLink(ctx, event)
+		assert.True(t, errors.As(err, new(ErrEntityNotFound[*Person])))
+		assert.False(t, errors.As(err, new(ErrEntityNotFound[*Venue])))
+		assert.True(t, errors.As(err, new(ErrEntityNotFound[*Date])))
+
+		require.NoError(t, person.Put(ctx, person))
+		require.NoError(t, date.Put(ctx, date))
+		require.NoError(t, event.TransactLink(ctx, event))
+		links, err := FindLinksByEntity2[*Date, *Event](ctx, date, event.Type())
+		require.NoError(t, err)
+		assert.Len(t, links, 1)
+	})
+}
@@ -0,0 +1,144 @@
+package dynamo
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/memdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useMemDB points the default client at a fresh in-memory table for the
+// duration of the test, so it can run without AWS credentials.
+func useMemDB(t *testing.T, opts ...memdb.Option) *memdb.DB {
+	t.Helper()
+	ctx := context.Background()
+	t.Setenv("TABLENAME", "gobox-memdb")
+	previous := *clients.GetDefaultClient(ctx)
+	db := memdb.New(opts...)
+	clients.SetDefaultClient(ctx, db.Client())
+	t.Cleanup(func() {
+		clients.SetDefaultClient(ctx, previous)
+	})
+	return db
+}
+
+func TestMemDB(t *testing.T) {
+	db := useMemDB(t)
+	ctx := context.Background()
+
+	user := &User{Email: "memdb@gmail.com", Name: "MemDB", Age: 40}
+	car := &Car{Make: "MemMake", Model: "MemModel", Year: 2020}
+	car2 := &Car{Make: "MemMake2", Model: "MemModel2", Year: 2021}
+
+	t.Run("Row CRUD", func(t *testing.T) {
+		require.NoError(t, user.Put(ctx, user))
+		loaded := CreateUser(user.Email)
+		ok, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "MemDB", loaded.Name)
+		assert.NotNil(t, loaded.RowData)
+
+		user.Age = 41
+		require.NoError(t, user.Put(ctx, user))
+		assert.NotEmpty(t, user.OldPutValues())
+
+		missing := CreateUser("missing@gmail.com")
+		ok, err = missing.Get(ctx, missing)
+		assert.False(t, ok)
+		assert.IsType(t, &ErrItemNotFound{}, err)
+	})
+
+	t.Run("rows use the table of the client", func(t *testing.T) {
+		client := db.Client().WithTableName("client-table")
+		clientCtx := clients.WithClient(ctx, &client)
+		other := &User{Email: "client-table@gmail.com", Name: "Other"}
+		require.NoError(t, other.Put(clientCtx, other))
+		assert.Len(t, db.Items("client-table"), 1)
+
+		_, err := NewTransaction().Delete(other).Exec(clientCtx)
+		require.NoError(t, err)
+		assert.Empty(t, db.Items("client-table"))
+	})
+
+	t.Run("links", func(t *testing.T) {
+		require.NoError(t, car.Put(ctx, car))
+		require.NoError(t, car2.Put(ctx, car2))
+		slip := &PinkSlip{DiLink: *NewDiLink(user, car), VIN: "1"}
+		require.NoError(t, slip.Link(ctx, slip))
+		slip2 := &PinkSlip{DiLink: *NewDiLink(user, car2), VIN: "2"}
+		require.NoError(t, slip2.Link(ctx, slip2))
+
+		exists, err := slip.CheckLink(ctx, slip, user, car)
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		byUser, err := FindLinksByEntity0[*User, *PinkSlip](ctx, user, slip.Type())
+		require.NoError(t, err)
+		assert.Len(t, byUser, 2)
+
+		byCar, err := FindLinksByEntity1[*Car, *PinkSlip](ctx, car, slip.Type())
+		require.NoError(t, err)
+		require.Len(t, byCar, 1)
+		assert.Equal(t, "1", byCar[0].VIN)
+
+		users, err := slip.LoadEntity0s(ctx, slip)
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, user.Email, users[0].Email)
+
+		cars, err := slip.LoadEntity1s(ctx, slip)
+		require.NoError(t, err)
+		sort.Slice(cars, func(i, j int) bool { return cars[i].Year < cars[j].Year })
+		require.Len(t, cars, 2)
+		assert.Equal(t, car.Make, cars[0].Make)
+		assert.Equal(t, car2.Make, cars[1].Make)
+
+		require.NoError(t, slip2.Unlink(ctx, slip2))
+		byUser, err = FindLinksByEntity0[*User, *PinkSlip](ctx, user, slip.Type())
+		require.NoError(t, err)
+		assert.Len(t, byUser, 1)
+	})
+
+	t.Run("TriLink entity2 lookups", func(t *testing.T) {
+		person := &Person{Name: "MemPerson"}
+		venue := &Venue{Name: "MemVenue"}
+		date := &Date{}
+		require.NoError(t, person.Put(ctx, person))
+		require.NoError(t, venue.Put(ctx, venue))
+		require.NoError(t, date.Put(ctx, date))
+		event := &Event{TriLink: *NewTriLink(person, venue, date), Name: "MemEvent"}
+		require.NoError(t, event.Link(ctx, event))
+
+		events, err := FindLinksByEntity2[*Date, *Event](ctx, date, event.Type())
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "MemEvent", events[0].Name)
+	})
+}
+
+func TestContextClient(t *testing.T) {
+	global := useMemDB(t)
+	scoped := memdb.New()
+	client := scoped.Client()
+	ctx := clients.WithClient(context.Background(), &client)
+
+	user := &User{Email: "scoped@gmail.com", Name: "Scoped"}
+	require.NoError(t, user.Put(ctx, user))
+	assert.Len(t, scoped.Items("gobox-memdb"), 1)
+	assert.Empty(t, global.Items("gobox-memdb"))
+
+	byUser, err := FindLinksByEntity0[*User, *PinkSlip](ctx, user, "PinkSlip")
+	require.NoError(t, err)
+	assert.Empty(t, byUser)
+
+	pinned := memdb.New()
+	pinnedClient := pinned.Client()
+	user.SetClient(&pinnedClient)
+	require.NoError(t, user.Put(ctx, user))
+	assert.Len(t, pinned.Items("gobox-memdb"), 1)
+}
@@ -2,6 +2,8 @@ package dynamo
 
 import (
 	"os"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 var isTesting bool
@@ -15,3 +17,12 @@ func checkTesting() bool {
 	checkedTesting = true
 	return isTesting
 }
+
+// consumedCapacity returns the ReturnConsumedCapacity of every request,
+// which reports the total consumed capacity when testing.
+func consumedCapacity() awstypes.ReturnConsumedCapacity {
+	if checkTesting() {
+		return awstypes.ReturnConsumedCapacityTotal
+	}
+	return awstypes.ReturnConsumedCapacityNone
+}
@@ -8,8 +8,10 @@ import (
 // By Default the MonoLink will establish a one-to-one relationship between the two
 // entities using the primary keys. If you need to save or modify fields in the
 // linked record, you will need to override this method.
+//
+// It is a MultiLink with one slot, bound to Entity0, E0pk and E0sk.
 type MonoLink[T0 types.Linkable] struct {
-	Row // Embedding the Row type for DynamoDB requirements
+	MultiLink // Embedding the MultiLink type, which embeds Row
 
 	E0pk string `dynamodbav:"e0pk" json:"e0pk,omitempty"`
 	E0sk string `dynamodbav:"e0sk" json:"e0sk,omitempty"`
@@ -1,9 +1,7 @@
 package dynamo
 
 import (
-	"errors"
 	"fmt"
-	"reflect"
 	"regexp"
 	"strings"
 	"unicode"
@@ -20,75 +18,35 @@ func (g EntityGSI) String() string {
 	return string(g)
 }
 
-// GenerateMonoLinkKeys generates the composite key for the monolink.
-func (m *MonoLink[T0]) GenerateMonoLinkKeys() (string, string, error) {
-
-	m.PartitionKey = ""
-	m.SortKey = ""
-
-	var e0pk, e0sk string
-	var err error
-
-	if reflect.ValueOf(m.Entity0).IsNil() {
-		if m.E0pk == "" && m.E0sk == "" {
-			return "", "", errors.New("Entity0 is nil and E0pk and E0sk are empty")
-		}
-		e0pk = m.E0pk
-		e0sk = m.E0sk
-	} else {
-		e0pk, e0sk, err = m.Entity0.Keys(0)
-		if err != nil {
-			return "", "", err
-		}
-	}
+// slots returns the entity slot of the monolink.
+func (m *MonoLink[T0]) slots() linkSlots {
+	return linkSlots{row: &m.Row, slots: []linkSlot{
+		{entity: m.Entity0, pk: &m.E0pk, sk: &m.E0sk, missing: ErrEntityNotFound[T0]{Entity: m.Entity0}},
+	}}
+}
 
-	linkedE0Pk, err := addKeySegment(rowType, m.Entity0.Type())
-	if err != nil {
-		return "", "", err
-	}
-	seg, err := addKeySegment(rowPk, e0pk)
-	if err != nil {
-		return "", "", err
-	}
-	linkedE0Pk += seg
+// GenerateLinkKeys generates the composite key for the monolink. Keys too
+// long to be stored are hashed.
+func (m *MonoLink[T0]) GenerateLinkKeys() (string, string, error) {
+	return m.slots().generate()
+}
 
-	m.E0pk = linkedE0Pk
-	m.E0sk = e0sk
+// GenerateMonoLinkKeys generates the composite key for the monolink, like
+// GenerateLinkKeys.
+func (m *MonoLink[T0]) GenerateMonoLinkKeys() (string, string, error) {
+	return m.GenerateLinkKeys()
+}
 
-	// Generate first part of the key using the entity0 type, pk, and sk
-	seg, err = addKeySegment(entity0Type, m.Entity0.Type())
-	if err != nil {
-		return "", "", err
-	}
-	m.PartitionKey += seg
-	seg, err = addKeySegment(entity0pk, e0pk)
-	if err != nil {
-		return "", "", err
-	}
-	m.PartitionKey += seg
-	seg, err = addKeySegment(entity0sk, e0sk)
-	if err != nil {
-		return "", "", err
-	}
-	m.SortKey += seg
-	return m.PartitionKey, m.SortKey, nil
+// ExtractEntityKeys extracts the pk and sk values for the entity in the slot
+// from the primary composite key.
+func (m *MonoLink[T0]) ExtractEntityKeys(slot int) (string, string, error) {
+	return m.slots().extractSlot(slot)
 }
 
 // ExtractE0Keys extracts the pk and sk values for the 0th entity from the
 // primary composite key.
 func (m *MonoLink[T0]) ExtractE0Keys() (string, string, error) {
-	if m.PartitionKey == "" || m.SortKey == "" {
-		_, _, err := m.GenerateMonoLinkKeys()
-		if err != nil {
-			return "", "", err
-		}
-	}
-	if m.E0pk != "" && m.E0sk != "" {
-		return m.E0pk, m.E0sk, nil
-	}
-	pk := extractKeys(entity0pk, m.PartitionKey)
-	sk := extractKeys(entity0sk, m.SortKey)
-	return pk, sk, nil
+	return m.slots().extract(0)
 }
 
 type ErrInvalidKeySegment struct {
@@ -130,6 +88,15 @@ func addKeySegment(label linkLabels, value string) (string, error) {
 	return fmt.Sprintf("/%s(%s)", label, value), nil
 }
 
+// addLinkKeySegment adds a segment to the key of a link, escaping the value
+// so it can be extracted again whatever it contains.
+func addLinkKeySegment(label linkLabels, value string) (string, error) {
+	if len(value) == 0 {
+		return "", ErrInvalidKeySegment{string(label), value}
+	}
+	return addKeySegment(label, escapeSegmentValue(value))
+}
+
 func containsObscureWhitespace(value string) bool {
 	for _, r := range value {
 		if unicode.IsSpace(r) && !unicode.IsPrint(r) {
@@ -139,13 +106,22 @@ func containsObscureWhitespace(value string) bool {
 	return false
 }
 
-// extractKeys extracts the pk and sk values from a given string.
+// extractKeys extracts the value of the segment with the label from a
+// given key, unescaping it. Keys written before values were escaped are
+// read as well.
 func extractKeys(label linkLabels, str string) string {
 	if !label.IsValidLabel() {
 		return "invalid label"
 	}
 
-	// Define regular expressions for pk and sk
+	if segments, err := ParseKey(str); err == nil {
+		if value, ok := segments.Value(label.String()); ok {
+			return value
+		}
+	}
+
+	// Fall back to the first match of the label anywhere in the string,
+	// such as a segment nested inside an unescaped value.
 	// regexFormat - where %d is the entity number and %s either Pk or Sk
 	regexFormat := `(?m)%s\(([^)]+)\)`
 
@@ -154,24 +130,11 @@ func extractKeys(label linkLabels, str string) string {
 	// Find pk and sk
 	pkMatches := regex.FindStringSubmatch(str)
 	if len(pkMatches) == 2 {
-		return pkMatches[1]
+		return unescapeSegmentValue(pkMatches[1])
 	}
 	return "nothing found"
 }
 
 func (m *MonoLink[T0]) Keys(gsi int) (string, string, error) {
-	// by default, we will only prefix the primary keys of both entities with "link-".
-	// this will create a 1-1 relationship between the two entities.
-	_, _, err := m.GenerateMonoLinkKeys()
-	if err != nil {
-		return "", "", err
-	}
-
-	switch gsi {
-	case 0: // Primary keys
-		return m.PartitionKey, m.SortKey, nil
-	default:
-		// Handle other GSIs or return an error
-		return "", "", errors.New("invalid GSI")
-	}
+	return m.slots().keys(gsi)
 }
@@ -0,0 +1,212 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/types"
+)
+
+// MultiLink links any number of entities, one per slot. It is the link
+// implementation every link type shares: MonoLink, DiLink and TriLink embed
+// it and bind its first one, two or three slots to their typed Entity and
+// key fields, so a four-way relationship needs no link type of its own:
+//
+//	type Membership struct {
+//		dynamo.MultiLink
+//		Since time.Time `json:"since"`
+//	}
+//
+//	m := &Membership{MultiLink: *dynamo.NewMultiLink(user, org, project, role)}
+//	err := m.Link(ctx, m)
+//
+// The stored key of the entity in slot N is written to the eNpk and eNsk
+// attributes, so the first three slots are compatible with the other link
+// types. FindLinksBySlot finds the links of an entity on the GSI returned by
+// Slot.
+type MultiLink struct {
+	Row
+
+	// Entities are the linked entities, by slot. The entities of a loaded
+	// link are nil; use LoadLinkEntity to load them. MonoLink, DiLink and
+	// TriLink leave it empty and use their Entity fields instead.
+	Entities []types.Linkable `dynamodbav:"-" json:"-"`
+	// EntityKeys are the stored keys of the entities, by slot. MonoLink,
+	// DiLink and TriLink leave it empty and use their key fields instead.
+	EntityKeys []EntityKey `dynamodbav:"-" json:"entityKeys,omitempty"`
+	// GSIs names the GSI of each slot. Slots without a name use the GSI
+	// returned by EntitySlot.
+	GSIs []EntityGSI `dynamodbav:"-" json:"-"`
+}
+
+// EntityKey is the key an entity of a link is stored with.
+type EntityKey struct {
+	Pk string `json:"pk"`
+	Sk string `json:"sk"`
+}
+
+// ErrInvalidSlot is returned for a slot a link does not have.
+type ErrInvalidSlot struct {
+	Slot int
+}
+
+func (e ErrInvalidSlot) Error() string {
+	return fmt.Sprintf("invalid slot for link: %d", e.Slot)
+}
+
+// NewMultiLink creates a new MultiLink instance linking the entities, in
+// slot order.
+func NewMultiLink(entities ...types.Linkable) *MultiLink {
+	link := MultiLink{Entities: entities}
+	link.GenerateLinkKeys()
+	return &link
+}
+
+// Type returns the type of the record.
+func (m *MultiLink) Type() string {
+	if m.UnmarshalledType == "" {
+		return "MultiLink"
+	}
+	return m.UnmarshalledType
+}
+
+// slots returns the entity slots of the link.
+func (m *MultiLink) slots() linkSlots {
+	n := max(len(m.Entities), len(m.EntityKeys))
+	for len(m.EntityKeys) < n {
+		m.EntityKeys = append(m.EntityKeys, EntityKey{})
+	}
+	l := linkSlots{row: &m.Row, slots: make([]linkSlot, n), multi: m}
+	for i := range l.slots {
+		l.slots[i].pk, l.slots[i].sk = &m.EntityKeys[i].Pk, &m.EntityKeys[i].Sk
+		if i < len(m.Entities) {
+			l.slots[i].entity = m.Entities[i]
+		}
+	}
+	return l
+}
+
+// slotsOf returns the slots of row, the link type that embeds m, which may
+// bind them to its own fields.
+func (m *MultiLink) slotsOf(row types.Linkable) linkSlots {
+	if link, ok := row.(slotted); ok {
+		return link.slots()
+	}
+	return m.slots()
+}
+
+// GenerateLinkKeys generates the composite key for the link. Keys too long
+// to be stored are hashed.
+func (m *MultiLink) GenerateLinkKeys() (string, string, error) {
+	l := m.slots()
+	if len(l.slots) == 0 {
+		return "", "", errors.New("MultiLink has no entities")
+	}
+	return l.generate()
+}
+
+// ExtractEntityKeys extracts the pk and sk values for the entity in the slot
+// from the primary composite key.
+func (m *MultiLink) ExtractEntityKeys(slot int) (string, string, error) {
+	return m.slots().extractSlot(slot)
+}
+
+// Slot returns the slot at index, with the GSI named by GSIs.
+func (m *MultiLink) Slot(index int) LinkSlot {
+	slot := EntitySlot(index)
+	if index >= 0 && index < len(m.GSIs) && m.GSIs[index] != "" {
+		slot.GSI = m.GSIs[index]
+	}
+	return slot
+}
+
+func (m *MultiLink) Keys(gsi int) (string, string, error) {
+	l := m.slots()
+	if len(l.slots) == 0 {
+		return "", "", errors.New("MultiLink has no entities")
+	}
+	return l.keys(gsi)
+}
+
+// Link is a generic method to establish a connection between the entities
+// of row, the link. If the link type declares a Cardinality, the link is
+// written in a transaction that fails with ErrCardinality when an entity
+// limited to one link already has one.
+func (m *MultiLink) Link(ctx context.Context, row types.Linkable) error {
+	if cardinalityOf(row) == ManyToMany {
+		return m.Put(ctx, row)
+	}
+	return m.transactPut(ctx, row, m.slotsOf(row), nil)
+}
+
+// Relink establishes the connection like Link, but atomically deletes the
+// link that an entity limited to one link by the link type's Cardinality
+// already has, instead of failing.
+func (m *MultiLink) Relink(ctx context.Context, row types.Linkable) error {
+	if cardinalityOf(row) == ManyToMany {
+		return m.Put(ctx, row)
+	}
+	return m.relink(ctx, row, m.slotsOf(row))
+}
+
+// Unlink method removes the connection between the entities by deleting the
+// link record.
+func (m *MultiLink) Unlink(ctx context.Context, row types.Linkable) error {
+	if cardinalityOf(row) == ManyToMany {
+		return m.Delete(ctx, row)
+	}
+	return m.transactDelete(ctx, row, m.slotsOf(row), nil)
+}
+
+// LoadLinkEntity loads the entity in the slot of the link as a T, and sets
+// it in the link's Entities.
+func LoadLinkEntity[T types.Linkable](ctx context.Context, m *MultiLink, slot int) (T, bool, error) {
+	var entity T
+	l := m.slots()
+	if slot < 0 || slot >= len(l.slots) {
+		return entity, false, ErrInvalidSlot{Slot: slot}
+	}
+	if e, ok := l.slots[slot].entity.(T); ok {
+		entity = e
+	}
+	loaded, err := loadLinkedEntity(ctx, l, slot, &entity)
+	if loaded {
+		for len(m.Entities) <= slot {
+			m.Entities = append(m.Entities, nil)
+		}
+		m.Entities[slot] = entity
+	}
+	return entity, loaded, err
+}
+
+// slotAttributes returns the eNpk and eNsk attributes of every slot.
+func (m *MultiLink) slotAttributes() map[string]awstypes.AttributeValue {
+	av := make(map[string]awstypes.AttributeValue, 2*len(m.EntityKeys))
+	for i, key := range m.EntityKeys {
+		_, pk, sk := slotLabels(i)
+		av[pk.String()] = &awstypes.AttributeValueMemberS{Value: key.Pk}
+		av[sk.String()] = &awstypes.AttributeValueMemberS{Value: key.Sk}
+	}
+	return av
+}
+
+// setSlotAttributes reads the EntityKeys from the eNpk and eNsk attributes
+// of the item.
+func (m *MultiLink) setSlotAttributes(item map[string]awstypes.AttributeValue) {
+	m.EntityKeys = nil
+	for i := 0; ; i++ {
+		_, pk, sk := slotLabels(i)
+		pkValue, ok := item[pk.String()].(*awstypes.AttributeValueMemberS)
+		if !ok {
+			return
+		}
+		var key EntityKey
+		key.Pk = pkValue.Value
+		if skValue, ok := item[sk.String()].(*awstypes.AttributeValueMemberS); ok {
+			key.Sk = skValue.Value
+		}
+		m.EntityKeys = append(m.EntityKeys, key)
+	}
+}
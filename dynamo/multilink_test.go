@@ -0,0 +1,155 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Org struct {
+	Row
+	Name string `json:"name"`
+}
+
+func (o *Org) Type() string {
+	return "org"
+}
+
+func (o *Org) Keys(gsi int) (string, string, error) {
+	return o.Name, "org", nil
+}
+
+type Project struct {
+	Row
+	Org  string `json:"org"`
+	Name string `json:"name"`
+}
+
+func (p *Project) Type() string {
+	return "project"
+}
+
+func (p *Project) Keys(gsi int) (string, string, error) {
+	return p.Org, p.Name, nil
+}
+
+type Role struct {
+	Row
+	Name string `json:"name"`
+}
+
+func (r *Role) Type() string {
+	return "role"
+}
+
+func (r *Role) Keys(gsi int) (string, string, error) {
+	return r.Name, "role", nil
+}
+
+type Membership struct {
+	MultiLink
+	Since string `json:"since"`
+}
+
+func (m *Membership) Type() string {
+	return "Membership"
+}
+
+func TestMultiLink(t *testing.T) {
+	user := &User{Email: "member@example.com"}
+	org := &Org{Name: "acme"}
+	project := &Project{Org: "acme", Name: "rockets"}
+	role := &Role{Name: "admin"}
+
+	t.Run("generates keys from every slot", func(t *testing.T) {
+		m := NewMultiLink(user, org, project, role)
+		assert.Equal(t, "/e0Type(user)/e0pk(member@example.com)/e1Type(org)/e1pk(acme)/e2Type(project)/e2pk(acme)/e3Type(role)/e3pk(admin)", m.PartitionKey)
+		assert.Equal(t, "/e0sk(info)/e1sk(org)/e2sk(rockets)/e3sk(role)", m.SortKey)
+		assert.Equal(t, EntityKey{Pk: "/rowType(role)/rowPk(admin)", Sk: "role"}, m.EntityKeys[3])
+
+		segments, err := ParseKey(m.PartitionKey)
+		require.NoError(t, err)
+		value, ok := segments.Value("e3pk")
+		assert.True(t, ok)
+		assert.Equal(t, "admin", value)
+	})
+	t.Run("generates the keys of the fixed links", func(t *testing.T) {
+		m := NewMultiLink(user, org)
+		di := NewDiLink(user, org)
+		assert.Equal(t, di.PartitionKey, m.PartitionKey)
+		assert.Equal(t, di.SortKey, m.SortKey)
+		assert.Equal(t, []EntityKey{{di.E0pk, di.E0sk}, {di.E1pk, di.E1sk}}, m.EntityKeys)
+	})
+	t.Run("binds its slots to the fields of the fixed links", func(t *testing.T) {
+		tri := NewTriLink(user, org, project)
+		m := NewMultiLink(user, org, project)
+		assert.Equal(t, m.PartitionKey, tri.PartitionKey)
+		assert.Equal(t, m.SortKey, tri.SortKey)
+		pk, sk, err := tri.ExtractEntityKeys(2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{tri.E2pk, tri.E2sk}, []string{pk, sk})
+		_, _, err = tri.ExtractEntityKeys(3)
+		assert.Equal(t, ErrInvalidSlot{Slot: 3}, err)
+
+		av, err := putItemAttributes(tri, nil)
+		require.NoError(t, err)
+		assert.Equal(t, &awstypes.AttributeValueMemberS{Value: tri.E2pk}, av["e2pk"])
+		assert.NotContains(t, av, "e3pk")
+		assert.Empty(t, tri.EntityKeys)
+	})
+	t.Run("rejects empty links and unknown slots", func(t *testing.T) {
+		_, _, err := (&MultiLink{}).GenerateLinkKeys()
+		assert.Error(t, err)
+		_, _, err = NewMultiLink(user).ExtractEntityKeys(1)
+		assert.Equal(t, ErrInvalidSlot{Slot: 1}, err)
+	})
+	t.Run("names the GSI of each slot", func(t *testing.T) {
+		m := NewMultiLink(user, org, project, role)
+		m.GSIs = []EntityGSI{3: "role-index"}
+		assert.Equal(t, LinkSlot{Index: 3, GSI: "role-index"}, m.Slot(3))
+		assert.Equal(t, LinkSlot{Index: 1, GSI: Entity1GSI}, m.Slot(1))
+
+		input, err := entityGSIQueryInput(context.Background(), role, m.Slot(3), "Membership")
+		require.NoError(t, err)
+		assert.Equal(t, "role-index", *input.IndexName)
+		assert.Equal(t, "e3pk = :pk AND begins_with(e3sk, :sk)", *input.KeyConditionExpression)
+	})
+	t.Run("links four entities", func(t *testing.T) {
+		useMemDB(t)
+		ctx := context.Background()
+		require.NoError(t, user.Put(ctx, user))
+		require.NoError(t, role.Put(ctx, role))
+		membership := &Membership{MultiLink: *NewMultiLink(user, org, project, role), Since: "2024"}
+		require.NoError(t, membership.Link(ctx, membership))
+
+		links, err := FindLinksBySlot[*Role, *Membership](ctx, role, membership.Slot(3), membership.Type())
+		require.NoError(t, err)
+		require.Len(t, links, 1)
+		loaded := links[0]
+		assert.Equal(t, "2024", loaded.Since)
+		assert.Equal(t, membership.EntityKeys, loaded.EntityKeys)
+
+		pk, sk, err := loaded.ExtractEntityKeys(0)
+		require.NoError(t, err)
+		assert.Equal(t, "/rowType(user)/rowPk(member@example.com)", pk)
+		assert.Equal(t, "info", sk)
+
+		got, ok, err := LoadLinkEntity[*Role](ctx, &loaded.MultiLink, 3)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "admin", got.Name)
+		assert.Same(t, got, loaded.Entities[3])
+
+		_, ok, err = LoadLinkEntity[*Org](ctx, &loaded.MultiLink, 1)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		_, _, err = LoadLinkEntity[*Org](ctx, &loaded.MultiLink, 0)
+		assert.True(t, errors.As(err, new(ErrLinkTypeMismatch[*Org])))
+	})
+}
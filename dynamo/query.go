@@ -1,136 +1,342 @@
 package dynamo
 
-// import (
-// 	"context"
-// 	"fmt"
-// 	"os"
-// 	"reflect"
-
-// 	"github.com/entegral/gobox/clients"
-// 	"github.com/entegral/gobox/types"
-
-// 	"github.com/aws/aws-sdk-go-v2/aws"
-// 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-// 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-// 	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-// )
-
-// // Query executes a query against DynamoDB. The method uses the default client.
-// // If you need to use a specific client, use QueryStandardUnmarshal instead, or use the
-// // client.SetDefaultClient method to set the default client.
-// //
-// // It also uses the standard attributevalue.UnmarshalMap to unmarshal the results.
-// // If you need to use a custom unmarshaler, satisfy the interface requred for/used by the
-// // QueryCustomUnmarshal function.
-// func Query[T any](ctx context.Context, input dynamodb.QueryInput) ([]T, error) {
-// 	return QueryListOfMaps[T](ctx, clients.GetDefaultClient(ctx), input)
-// }
-
-// // QueryByGSI executes a query against a specific Global Secondary Index (GSI) or the primary index.
-// // It uses the default client. If you need to use a specific client, use QueryByGSIWithClient instead.
-// func QueryByGSI[T types.Keyable](ctx context.Context, gsi int, partitionKey, sortKeyCondition string) ([]T, error) {
-// 	client := clients.GetDefaultClient(ctx)
-// 	return QueryByGSIWithClient[T](ctx, client, gsi, partitionKey, sortKeyCondition)
-// }
-
-// // QueryByGSIWithClient executes a query against a specific Global Secondary Index (GSI) or the primary index using the provided client.
-// func QueryByGSIWithClient[T types.Keyable](ctx context.Context, client *clients.Client, gsi int, partitionKey, sortKeyCondition string) ([]T, error) {
-// 	tableName := os.Getenv("TABLENAME")
-// 	if tableName == "" {
-// 		return nil, fmt.Errorf("TABLENAME environment variable not set")
-// 	}
-
-// 	var indexName *string
-// 	var keyConditionExpression string
-// 	var expressionAttributeNames map[string]string
-// 	var expressionAttributeValues map[string]awstypes.AttributeValue
-
-// 	// Construct the key condition expression based on the GSI
-// 	if gsi == 0 {
-// 		keyConditionExpression = "pk = :pk"
-// 		if sortKeyCondition != "" {
-// 			keyConditionExpression += " AND begins_with(sk, :sk)"
-// 		}
-// 	} else {
-// 		indexName = aws.String(fmt.Sprintf("pk%d-sk%d-index", gsi, gsi))
-// 		keyConditionExpression = fmt.Sprintf("pk%d = :pk", gsi)
-// 		if sortKeyCondition != "" {
-// 			keyConditionExpression += fmt.Sprintf(" AND begins_with(sk%d, :sk)", gsi)
-// 		}
-// 	}
-
-// 	// Construct the expression attribute values
-// 	expressionAttributeValues = map[string]awstypes.AttributeValue{
-// 		":pk": &awstypes.AttributeValueMemberS{Value: partitionKey},
-// 	}
-// 	if sortKeyCondition != "" {
-// 		expressionAttributeValues[":sk"] = &awstypes.AttributeValueMemberS{Value: sortKeyCondition}
-// 	}
-
-// 	// Create the query input
-// 	queryInput := dynamodb.QueryInput{
-// 		TableName:                 aws.String(tableName),
-// 		IndexName:                 indexName,
-// 		KeyConditionExpression:    aws.String(keyConditionExpression),
-// 		ExpressionAttributeNames:  expressionAttributeNames,
-// 		ExpressionAttributeValues: expressionAttributeValues,
-// 	}
-
-// 	// Execute the query
-// 	return QueryListOfMaps[T](ctx, client, queryInput)
-// }
-
-// // QueryListOfMaps executes a query against DynamoDB and unmarshals using attributevalue.UnmarshalMap.
-// func QueryListOfMaps[T any](ctx context.Context, client *clients.Client, input dynamodb.QueryInput) ([]T, error) {
-// 	out, err := client.Dynamo().Query(ctx, &input)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	var rows []T
-// 	err = attributevalue.UnmarshalListOfMaps(out.Items, &rows)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	for i, row := range rows {
-// 		// Use reflection to check if row embeds the Row type and update RowData
-// 		rowValue := reflect.ValueOf(row).Elem()
-// 		if rowDataField := rowValue.FieldByName("Row"); rowDataField.IsValid() {
-// 			// Set the RowData field with the raw data from DynamoDB
-// 			rowData := rowDataField.FieldByName("RowData")
-// 			if rowData.CanSet() {
-// 				rowData.Set(reflect.ValueOf(out.Items[i]))
-// 			}
-// 		}
-// 	}
-// 	return rows, nil
-// }
-
-// // QueryCustomListOfMaps executes a query against DynamoDB and unmarshals using the custom interface.
-// func QueryCustomListOfMaps[T types.CustomDynamoMarshaller](ctx context.Context, client *clients.Client, input dynamodb.QueryInput) ([]T, error) {
-// 	out, err := client.Dynamo().Query(ctx, &input)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	var rows []T
-// 	for _, item := range out.Items {
-// 		var newRow T
-// 		err := newRow.UnmarshalItem(item)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		// Use reflection to check if row embeds the Row type and update RowData
-// 		rowValue := reflect.ValueOf(newRow).Elem()
-// 		if rowDataField := rowValue.FieldByName("Row"); rowDataField.IsValid() {
-// 			// Set the RowData field with the raw data from DynamoDB
-// 			rowData := rowDataField.FieldByName("RowData")
-// 			if rowData.CanSet() {
-// 				rowData.Set(reflect.ValueOf(item))
-// 			}
-// 		}
-// 		rows = append(rows, newRow)
-// 	}
-// 	return rows, nil
-// }
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/keys"
+	"github.com/entegral/gobox/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Placeholder names used by Query. They are prefixed so they do not collide
+// with the names and values passed to Filter.
+const (
+	queryPkName    = "#gbPk"
+	querySkName    = "#gbSk"
+	queryTypeName  = "#gbType"
+	queryPkValue   = ":gbPk"
+	querySkValue   = ":gbSk"
+	querySkValue2  = ":gbSk2"
+	queryTypeValue = ":gbType"
+)
+
+// sortKeyOperator is a comparison applied to the sort key of a Query.
+type sortKeyOperator string
+
+const (
+	sortKeyEquals      sortKeyOperator = "="
+	sortKeyBeginsWith  sortKeyOperator = "begins_with"
+	sortKeyBetween     sortKeyOperator = "BETWEEN"
+	sortKeyLessThan    sortKeyOperator = "<"
+	sortKeyGreaterThan sortKeyOperator = ">"
+)
+
+// ErrInvalidGSIName is returned when a Query targets an index that is not
+// one of GSI1 through GSI6.
+type ErrInvalidGSIName struct {
+	Index GSIName
+}
+
+func (e ErrInvalidGSIName) Error() string {
+	return fmt.Sprintf("invalid GSI name: %s", e.Index)
+}
+
+// Query builds and executes a query that unmarshals its results into T.
+// Create one with NewQuery, refine it with the chainable methods, then call
+// Exec:
+//
+//	users, err := NewQuery[*User]("user@gmail.com").
+//		SortKeyBeginsWith("info").
+//		Exec(ctx)
+//
+// On the primary index the partition key is the value returned by the row's
+// Keys(0) method; the row type prefix is added automatically. On GSI1
+// through GSI6 the partition key is matched as is and only rows of T's type
+// are returned.
+type Query[T types.Linkable] struct {
+	index        GSIName
+	partitionKey string
+	skOp         sortKeyOperator
+	skValues     []string
+	filter       string
+	names        map[string]string
+	values       map[string]awstypes.AttributeValue
+	projection   []string
+	descending   bool
+	limit        int32
+	cursor       string
+	client       *clients.Client
+	tablename    string
+}
+
+// NewQuery returns a query for rows of type T with the provided partition key
+// on the primary index.
+func NewQuery[T types.Linkable](partitionKey string) *Query[T] {
+	return &Query[T]{partitionKey: partitionKey}
+}
+
+// OnIndex targets one of GSI1 through GSI6 instead of the primary index.
+func (q *Query[T]) OnIndex(index GSIName) *Query[T] {
+	q.index = index
+	return q
+}
+
+// SortKeyEquals matches rows whose sort key equals the value.
+func (q *Query[T]) SortKeyEquals(value string) *Query[T] {
+	return q.sortKey(sortKeyEquals, value)
+}
+
+// SortKeyBeginsWith matches rows whose sort key begins with the prefix.
+func (q *Query[T]) SortKeyBeginsWith(prefix string) *Query[T] {
+	return q.sortKey(sortKeyBeginsWith, prefix)
+}
+
+// SortKeyBetween matches rows whose sort key is between low and high,
+// inclusive.
+func (q *Query[T]) SortKeyBetween(low, high string) *Query[T] {
+	return q.sortKey(sortKeyBetween, low, high)
+}
+
+// SortKeyLessThan matches rows whose sort key sorts before the value.
+func (q *Query[T]) SortKeyLessThan(value string) *Query[T] {
+	return q.sortKey(sortKeyLessThan, value)
+}
+
+// SortKeyGreaterThan matches rows whose sort key sorts after the value.
+func (q *Query[T]) SortKeyGreaterThan(value string) *Query[T] {
+	return q.sortKey(sortKeyGreaterThan, value)
+}
+
+func (q *Query[T]) sortKey(op sortKeyOperator, values ...string) *Query[T] {
+	q.skOp = op
+	q.skValues = values
+	return q
+}
+
+// Filter sets a filter expression evaluated after the key condition. The
+// names and values maps hold its expression attribute names and values and
+// may be nil.
+func (q *Query[T]) Filter(expression string, names map[string]string, values map[string]awstypes.AttributeValue) *Query[T] {
+	q.filter = expression
+	q.names = names
+	q.values = values
+	return q
+}
+
+// Project limits the attributes returned for each row. Attributes that are
+// not projected are left at their zero value in the results.
+func (q *Query[T]) Project(attributes ...string) *Query[T] {
+	q.projection = attributes
+	return q
+}
+
+// Descending returns rows in descending sort key order.
+func (q *Query[T]) Descending() *Query[T] {
+	q.descending = true
+	return q
+}
+
+// Limit caps the number of rows returned by Exec. For Iter it is the number
+// of items evaluated per page.
+func (q *Query[T]) Limit(limit int32) *Query[T] {
+	q.limit = limit
+	return q
+}
+
+// StartAfter resumes the query from a cursor returned by Iterator.Cursor.
+func (q *Query[T]) StartAfter(cursor string) *Query[T] {
+	q.cursor = cursor
+	return q
+}
+
+// WithClient sets the client used to execute the query. By default the
+// client carried by ctx, or the default client, is used.
+func (q *Query[T]) WithClient(client *clients.Client) *Query[T] {
+	q.client = client
+	return q
+}
+
+// WithTableName overrides the table name returned by T's TableName method.
+func (q *Query[T]) WithTableName(tablename string) *Query[T] {
+	q.tablename = tablename
+	return q
+}
+
+// Input builds the dynamodb.QueryInput executed by Exec.
+func (q *Query[T]) Input(ctx context.Context) (*dynamodb.QueryInput, error) {
+	row := newRow[T]()
+	pkKey, skKey := keys.PkKey, keys.SkKey
+	partitionKey := q.partitionKey
+	names := map[string]string{}
+	values := map[string]awstypes.AttributeValue{}
+	var filters []string
+
+	input := &dynamodb.QueryInput{
+		ScanIndexForward:       aws.Bool(!q.descending),
+		ReturnConsumedCapacity: consumedCapacity(),
+	}
+
+	switch q.index {
+	case "":
+		pk, err := prependWithRowType(row, partitionKey)
+		if err != nil {
+			return nil, err
+		}
+		partitionKey = pk
+	case GSI1, GSI2, GSI3, GSI4, GSI5, GSI6:
+		parts := strings.Split(strings.TrimSuffix(q.index.String(), "-index"), "-")
+		pkKey, skKey = parts[0], parts[1]
+		input.IndexName = aws.String(q.index.String())
+		names[queryTypeName] = "type"
+		values[queryTypeValue] = &awstypes.AttributeValueMemberS{Value: row.Type()}
+		filters = append(filters, fmt.Sprintf("%s = %s", queryTypeName, queryTypeValue))
+	default:
+		return nil, ErrInvalidGSIName{Index: q.index}
+	}
+	if partitionKey == "" {
+		return nil, errors.New("query partition key must not be empty")
+	}
+
+	names[queryPkName] = pkKey
+	values[queryPkValue] = &awstypes.AttributeValueMemberS{Value: partitionKey}
+	kce := fmt.Sprintf("%s = %s", queryPkName, queryPkValue)
+	if q.skOp != "" {
+		names[querySkName] = skKey
+		values[querySkValue] = &awstypes.AttributeValueMemberS{Value: q.skValues[0]}
+		switch q.skOp {
+		case sortKeyBeginsWith:
+			kce += fmt.Sprintf(" AND begins_with(%s, %s)", querySkName, querySkValue)
+		case sortKeyBetween:
+			values[querySkValue2] = &awstypes.AttributeValueMemberS{Value: q.skValues[1]}
+			kce += fmt.Sprintf(" AND %s BETWEEN %s AND %s", querySkName, querySkValue, querySkValue2)
+		default:
+			kce += fmt.Sprintf(" AND %s %s %s", querySkName, q.skOp, querySkValue)
+		}
+	}
+	input.KeyConditionExpression = aws.String(kce)
+
+	if q.filter != "" {
+		filters = append(filters, "("+q.filter+")")
+	}
+	for k, v := range q.names {
+		names[k] = v
+	}
+	for k, v := range q.values {
+		values[k] = v
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+	if len(q.projection) > 0 {
+		projected := make([]string, len(q.projection))
+		for i, attr := range q.projection {
+			name := fmt.Sprintf("#gbProj%d", i)
+			names[name] = attr
+			projected[i] = name
+		}
+		input.ProjectionExpression = aws.String(strings.Join(projected, ", "))
+	}
+	input.ExpressionAttributeNames = names
+	input.ExpressionAttributeValues = values
+
+	tablename := q.tablename
+	if tablename == "" {
+		tablename = row.TableName(ctx)
+	}
+	input.TableName = aws.String(tablename)
+	return input, nil
+}
+
+// Iter returns an iterator over the pages of the query.
+func (q *Query[T]) Iter(ctx context.Context) *Iterator[T] {
+	input, err := q.Input(ctx)
+	client := q.client
+	if err == nil && client == nil {
+		client, err = clients.Resolve(ctx)
+	}
+	return newIterator[T](client, input, err).PageSize(q.limit).StartAfter(q.cursor)
+}
+
+// Exec runs the query, following pagination until every matching row has
+// been read or the limit is reached.
+func (q *Query[T]) Exec(ctx context.Context) ([]T, error) {
+	it := q.Iter(ctx)
+	var rows []T
+	for it.Next(ctx) {
+		rows = append(rows, it.Page()...)
+		if q.limit > 0 && int32(len(rows)) >= q.limit {
+			return rows[:q.limit], nil
+		}
+	}
+	return rows, it.Err()
+}
+
+// newRow returns a new instance of T. When T is a pointer type the value it
+// points to is allocated, so methods like Type and TableName can be called.
+func newRow[T any]() T {
+	var row T
+	if t := reflect.TypeOf(row); t != nil && t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()).Interface().(T)
+	}
+	return row
+}
+
+// unmarshalRow unmarshals an item into a new T, honouring
+// types.CustomDynamoMarshaller and populating RowData.
+func unmarshalRow[T any](item map[string]awstypes.AttributeValue) (T, error) {
+	row := newRow[T]()
+	if t := reflect.TypeOf(row); t == nil || t.Kind() != reflect.Pointer {
+		err := attributevalue.UnmarshalMap(item, &row)
+		return row, err
+	}
+	return row, unmarshalItemInto(row, item)
+}
+
+// setRowData sets the RowData field of rows that embed the Row struct.
+func setRowData(row any, item map[string]awstypes.AttributeValue) {
+	rowValue := reflect.ValueOf(row)
+	if rowValue.Kind() != reflect.Pointer || rowValue.IsNil() {
+		return
+	}
+	rowValue = rowValue.Elem()
+	if rowValue.Kind() != reflect.Struct {
+		return
+	}
+	if rowDataField := rowValue.FieldByName("Row"); rowDataField.IsValid() {
+		if DBManager := rowDataField.FieldByName("DBManager"); DBManager.IsValid() {
+			rowData := DBManager.FieldByName("RowData")
+			if rowData.CanSet() {
+				rowData.Set(reflect.ValueOf(item))
+			}
+		}
+	}
+}
+
+// getRowData returns the RowData field of rows that embed the Row struct.
+func getRowData(row any) map[string]awstypes.AttributeValue {
+	rowValue := reflect.ValueOf(row)
+	if rowValue.Kind() != reflect.Pointer || rowValue.IsNil() {
+		return nil
+	}
+	rowValue = rowValue.Elem()
+	if rowValue.Kind() != reflect.Struct {
+		return nil
+	}
+	if rowDataField := rowValue.FieldByName("Row"); rowDataField.IsValid() {
+		if DBManager := rowDataField.FieldByName("DBManager"); DBManager.IsValid() {
+			if rowData, ok := DBManager.FieldByName("RowData").Interface().(map[string]awstypes.AttributeValue); ok {
+				return rowData
+			}
+		}
+	}
+	return nil
+}
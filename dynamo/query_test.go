@@ -0,0 +1,123 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Book is keyed by author and title, and indexed by genre and year on GSI1.
+type Book struct {
+	Row
+	Author string
+	Title  string
+	Genre  string
+	Year   int
+}
+
+func (b *Book) Type() string {
+	return "book"
+}
+
+func (b *Book) Keys(gsi int) (string, string, error) {
+	b.PartitionKey = b.Author
+	b.SortKey = b.Title
+	genre, year := b.Genre, fmt.Sprintf("%d", b.Year)
+	b.Pk1, b.Sk1 = &genre, &year
+	switch gsi {
+	case 1:
+		return *b.Pk1, *b.Sk1, nil
+	default:
+		return b.PartitionKey, b.SortKey, nil
+	}
+}
+
+func TestQuery(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+	books := []*Book{
+		{Author: "le guin", Title: "a wizard of earthsea", Genre: "fantasy", Year: 1968},
+		{Author: "le guin", Title: "the dispossessed", Genre: "scifi", Year: 1974},
+		{Author: "le guin", Title: "the left hand of darkness", Genre: "scifi", Year: 1969},
+		{Author: "herbert", Title: "dune", Genre: "scifi", Year: 1965},
+	}
+	for _, b := range books {
+		_, _, err := b.Keys(0)
+		require.NoError(t, err)
+		require.NoError(t, b.Put(ctx, b))
+	}
+	// a row of another type sharing the GSI1 partition key
+	other := &Car{Make: "scifi", Model: "other", Year: 1970}
+	other.Pk1, other.Sk1 = aws.String("scifi"), aws.String("1970")
+	require.NoError(t, other.Put(ctx, other))
+
+	titles := func(books []*Book) []string {
+		var out []string
+		for _, b := range books {
+			out = append(out, b.Title)
+		}
+		return out
+	}
+
+	t.Run("primary index", func(t *testing.T) {
+		rows, err := NewQuery[*Book]("le guin").Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a wizard of earthsea", "the dispossessed", "the left hand of darkness"}, titles(rows))
+		assert.NotNil(t, rows[0].RowData)
+		assert.Equal(t, "book", rows[0].Type())
+	})
+
+	t.Run("sort key operators", func(t *testing.T) {
+		rows, err := NewQuery[*Book]("le guin").SortKeyBeginsWith("the").Exec(ctx)
+		require.NoError(t, err)
+		assert.Len(t, rows, 2)
+
+		rows, err = NewQuery[*Book]("le guin").SortKeyEquals("the dispossessed").Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the dispossessed"}, titles(rows))
+
+		rows, err = NewQuery[*Book]("le guin").SortKeyLessThan("the").Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a wizard of earthsea"}, titles(rows))
+
+		rows, err = NewQuery[*Book]("le guin").SortKeyGreaterThan("the e").Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the left hand of darkness"}, titles(rows))
+
+		rows, err = NewQuery[*Book]("le guin").SortKeyBetween("b", "the f").Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the dispossessed"}, titles(rows))
+	})
+
+	t.Run("GSI with order and limit", func(t *testing.T) {
+		rows, err := NewQuery[*Book]("scifi").OnIndex(GSI1).Descending().Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the dispossessed", "the left hand of darkness", "dune"}, titles(rows))
+
+		rows, err = NewQuery[*Book]("scifi").OnIndex(GSI1).SortKeyGreaterThan("1965").Limit(1).Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the left hand of darkness"}, titles(rows))
+	})
+
+	t.Run("filter and projection", func(t *testing.T) {
+		rows, err := NewQuery[*Book]("le guin").
+			Filter("#genre = :genre", map[string]string{"#genre": "Genre"}, map[string]awstypes.AttributeValue{
+				":genre": &awstypes.AttributeValueMemberS{Value: "scifi"},
+			}).
+			Project("Title").
+			Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the dispossessed", "the left hand of darkness"}, titles(rows))
+		assert.Empty(t, rows[0].Author)
+	})
+
+	t.Run("invalid index", func(t *testing.T) {
+		_, err := NewQuery[*Book]("scifi").OnIndex(GSIName(Entity0GSI)).Exec(ctx)
+		assert.IsType(t, ErrInvalidGSIName{}, err)
+	})
+}
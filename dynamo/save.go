@@ -0,0 +1,157 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/keys"
+	"github.com/entegral/gobox/types"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Save writes the changes made to a loaded row. The row is compared with the
+// item it was loaded from, held in RowData, and only the attributes that
+// changed are written with an UpdateItem: changed or new attributes are SET
+// and attributes the row no longer has are REMOVEd. Concurrent writers that
+// change other attributes of the same item are therefore not overwritten.
+//
+// Save does nothing if no attribute changed. It returns an ErrItemNotFound
+// if the item was deleted since it was loaded. The version of
+// types.Versionable rows and the timestamps of types.Timestampable rows are
+// maintained as by Put. Rows that were not loaded have nothing to compare
+// with and are written with Put. The UpdateItemOutput response, holding the
+// item as it is stored after the update, is stored in d.UpdateItemOutput.
+func (d *DBManager) Save(ctx context.Context, row types.Linkable) (err error) {
+	loaded := getRowData(row)
+	if loaded == nil {
+		return d.Put(ctx, row)
+	}
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+	d.UpdateItemOutput, err = d.saveWithClient(ctx, client, row, loaded)
+	return err
+}
+
+func (d *DBManager) saveWithClient(ctx context.Context, client *clients.Client, row types.Linkable, loaded map[string]awstypes.AttributeValue) (*dynamodb.UpdateItemOutput, error) {
+	if err := beforeUpdate(ctx, client, row); err != nil {
+		return nil, err
+	}
+	av, err := putItemAttributes(row, d.GetDynamoTTL())
+	if err != nil {
+		return nil, err
+	}
+	skip := []string{"pk", "sk"}
+	vp, versioned := newVersionedPut(row, av)
+	if versioned {
+		skip = append(skip, versionAttribute)
+	}
+	tp, timestamped := newTimestampedPut(row, av)
+	if timestamped {
+		skip = append(skip, createdAtAttribute, updatedAtAttribute)
+	}
+	changed, removed := diffItem(av, loaded, skip)
+	if len(changed) == 0 && len(removed) == 0 {
+		return nil, nil
+	}
+
+	e := newExpression("U")
+	var set []string
+	for _, name := range changed {
+		set = append(set, e.name(name)+" = "+e.value(av[name]))
+	}
+	cond := AttributeExists(keys.PkKey)
+	if versioned {
+		set = append(set, e.name(versionAttribute)+" = "+e.value(av[versionAttribute]))
+		cond = And(cond, vp.condition())
+	}
+	if timestamped {
+		now := e.value(tp.now)
+		created := e.name(createdAtAttribute)
+		set = append(set,
+			created+" = if_not_exists("+created+", "+now+")",
+			e.name(updatedAtAttribute)+" = "+now,
+		)
+	}
+	update := ""
+	if len(set) > 0 {
+		update = "SET " + strings.Join(set, ", ")
+	}
+	if len(removed) > 0 {
+		remove := make([]string, len(removed))
+		for i, name := range removed {
+			remove[i] = e.name(name)
+		}
+		update = strings.TrimSpace(update + " REMOVE " + strings.Join(remove, ", "))
+	}
+	expr := cond.build(e)
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	tn := d.TableName(ctx)
+	out, err := client.Dynamo().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           &tn,
+		Key:                                 map[string]awstypes.AttributeValue{"pk": av["pk"], "sk": av["sk"]},
+		UpdateExpression:                    &update,
+		ConditionExpression:                 &expr,
+		ExpressionAttributeNames:            e.names,
+		ExpressionAttributeValues:           e.values,
+		ReturnValues:                        awstypes.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: awstypes.ReturnValuesOnConditionCheckFailureAllOld,
+		ReturnConsumedCapacity:              consumedCapacity(),
+	})
+	var ccf *awstypes.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		if ccf.Item == nil {
+			return nil, &ErrItemNotFound{Row: row}
+		}
+		if versioned && vp.mismatch(ccf.Item) {
+			return nil, vp.conflict(ccf.Item)
+		}
+		return nil, ErrConditionFailed{Row: row, Current: ccf.Item}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if versioned {
+		vp.commit()
+	}
+	if timestamped {
+		tp.stamp(out.Attributes)
+	}
+	setRowData(row, out.Attributes)
+	return out, afterUpdate(ctx, client, row)
+}
+
+// diffItem compares item, a marshalled row, with loaded, the item it was
+// loaded from. It returns the sorted names of the attributes of item that
+// are new or changed, and of the attributes of loaded that item no longer
+// has. The attributes in skip are left out.
+func diffItem(item, loaded map[string]awstypes.AttributeValue, skip []string) (changed, removed []string) {
+	skipped := func(name string) bool {
+		for _, s := range skip {
+			if s == name {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range sortedAttributes(item) {
+		if old, ok := loaded[name]; !skipped(name) && (!ok || !reflect.DeepEqual(old, item[name])) {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range sortedAttributes(loaded) {
+		if _, ok := item[name]; !ok && !skipped(name) {
+			removed = append(removed, name)
+		}
+	}
+	return changed, removed
+}
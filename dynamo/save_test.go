@@ -0,0 +1,101 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestSave(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	t.Run("writers changing different attributes keep each other's changes", func(t *testing.T) {
+		user := &User{Email: "save@gmail.com", Name: "Before", Age: 30}
+		require.NoError(t, user.Put(ctx, user))
+		first := CreateUser("save@gmail.com")
+		_, err := first.Get(ctx, first)
+		require.NoError(t, err)
+		second := CreateUser("save@gmail.com")
+		_, err = second.Get(ctx, second)
+		require.NoError(t, err)
+
+		first.Name = "After"
+		require.NoError(t, first.Save(ctx, first))
+		second.Age = 31
+		require.NoError(t, second.Save(ctx, second))
+
+		loaded := CreateUser("save@gmail.com")
+		_, err = loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, "After", loaded.Name)
+		assert.Equal(t, 31, loaded.Age)
+		assert.Equal(t, "After", getRowData(second)["Name"].(*awstypes.AttributeValueMemberS).Value)
+	})
+	t.Run("unchanged rows are not written", func(t *testing.T) {
+		user := CreateUser("save@gmail.com")
+		_, err := user.Get(ctx, user)
+		require.NoError(t, err)
+		require.NoError(t, user.Save(ctx, user))
+		assert.Nil(t, user.UpdateItemOutput)
+	})
+	t.Run("cleared attributes are removed", func(t *testing.T) {
+		created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		useClock(t, func() time.Time { return created })
+		note := &Note{ID: "s1", Body: "body", Tag: "draft"}
+		require.NoError(t, note.Put(ctx, note))
+		note = &Note{ID: "s1"}
+		_, err := note.Get(ctx, note)
+		require.NoError(t, err)
+
+		updated := created.Add(time.Minute)
+		useClock(t, func() time.Time { return updated })
+		note.Tag = ""
+		require.NoError(t, note.Save(ctx, note))
+		assert.NotContains(t, note.UpdateItemOutput.Attributes, "Tag")
+		assert.True(t, created.Equal(note.CreatedAt.Time))
+		assert.True(t, updated.Equal(note.UpdatedAt.Time))
+	})
+	t.Run("deleted items are not recreated", func(t *testing.T) {
+		user := CreateUser("save@gmail.com")
+		_, err := user.Get(ctx, user)
+		require.NoError(t, err)
+		deleted := CreateUser("save@gmail.com")
+		require.NoError(t, deleted.Delete(ctx, deleted))
+		user.Name = "Ghost"
+		err = user.Save(ctx, user)
+		assert.IsType(t, &ErrItemNotFound{}, err)
+	})
+	t.Run("versioned rows are checked and incremented", func(t *testing.T) {
+		account := &Account{ID: "s1"}
+		require.NoError(t, account.Put(ctx, account))
+		stale := &Account{ID: "s1"}
+		_, err := stale.Get(ctx, stale)
+		require.NoError(t, err)
+		current := &Account{ID: "s1"}
+		_, err = current.Get(ctx, current)
+		require.NoError(t, err)
+
+		current.Balance = 5
+		require.NoError(t, current.Save(ctx, current))
+		assert.Equal(t, int64(2), current.GetVersion())
+
+		stale.Balance = 7
+		err = stale.Save(ctx, stale)
+		var conflict ErrVersionConflict
+		require.True(t, errors.As(err, &conflict))
+		assert.Equal(t, int64(2), conflict.CurrentVersion())
+	})
+	t.Run("rows that were not loaded are put", func(t *testing.T) {
+		user := &User{Email: "unsaved@gmail.com", Name: "New"}
+		require.NoError(t, user.Save(ctx, user))
+		assert.NotNil(t, user.PutItemOutput)
+		assert.Nil(t, user.UpdateItemOutput)
+	})
+}
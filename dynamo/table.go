@@ -2,7 +2,6 @@ package dynamo
 
 import (
 	"context"
-	"os"
 
 	"github.com/entegral/gobox/clients"
 )
@@ -17,17 +16,18 @@ func NewTable(tablename string) Table {
 }
 
 // TableName returns the name of the DynamoDB table.
-// By default, this is the value of the TABLENAME environment variable.
-// If you need to override this, implement this method on the parent type.
+// By default, this is the table of the client the table uses, set with
+// clients.WithTable, or else the value of the TABLENAME environment
+// variable. If you need to override this, implement this method on the
+// parent type.
 func (t *Table) TableName(ctx context.Context) string {
 	if t.Tablename != "" {
 		return t.Tablename
 	}
-	tn := os.Getenv("TABLENAME")
-	if tn == "" {
-		panic("TABLENAME environment variable not set")
+	if t.Client != nil {
+		return t.Client.TableName(ctx)
 	}
-	return tn
+	return clients.TableName(ctx)
 }
 
 func (t *Table) SetTableName(tablename string) {
@@ -38,3 +38,12 @@ func (t *Table) SetTableName(tablename string) {
 func (t *Table) SetClient(client *clients.Client) {
 	t.Client = client
 }
+
+// client returns the client set on the table, falling back to the client
+// carried by ctx and then to the default client.
+func (t *Table) client(ctx context.Context) (*clients.Client, error) {
+	if t.Client != nil {
+		return t.Client, nil
+	}
+	return clients.Resolve(ctx)
+}
@@ -0,0 +1,88 @@
+package dynamo
+
+import (
+	"fmt"
+
+	"github.com/entegral/gobox/keytags"
+	"github.com/entegral/gobox/types"
+)
+
+// TaggedRow is a Row whose keys are declared with keytags struct tags
+// instead of a Keys method:
+//
+//	type Order struct {
+//		dynamo.TaggedRow
+//		Customer string `pk:"1,prepend=CUSTOMER:" pk1:"1,prepend=ORDER:"`
+//		ID       string `sk:"1,prepend=ORDER:" sk1:"1,prepend=CUSTOMER:"`
+//	}
+//
+// Every operation derives the keys from the tags before it uses them, and
+// stores the primary key in the row's keys.Key and the GSI keys in its
+// keys.GSI fields, so Put writes them. The parts of each key are joined
+// with keytags.DefaultDelimiter, unless the row implements keytags.Delimited.
+// To derive the keys outside of an operation, call keytags.Apply.
+type TaggedRow struct {
+	Row
+}
+
+// ErrKeysNotDerived is returned by the Keys method of a TaggedRow whose keys
+// were not derived from its tags yet.
+type ErrKeysNotDerived struct {
+	GSI int
+}
+
+func (e ErrKeysNotDerived) Error() string {
+	return fmt.Sprintf("keys of index %d were not derived from the row's tags, call keytags.Apply first", e.GSI)
+}
+
+// Keys returns the keys of index gsi derived from the row's tags. As with
+// Row, a primary key without a sort key gets the sort key "row".
+func (r *TaggedRow) Keys(gsi int) (string, string, error) {
+	var pk, sk *string
+	switch gsi {
+	case 0:
+		pk, sk = &r.PartitionKey, &r.SortKey
+	case 1:
+		pk, sk = r.Pk1, r.Sk1
+	case 2:
+		pk, sk = r.Pk2, r.Sk2
+	case 3:
+		pk, sk = r.Pk3, r.Sk3
+	case 4:
+		pk, sk = r.Pk4, r.Sk4
+	case 5:
+		pk, sk = r.Pk5, r.Sk5
+	case 6:
+		pk, sk = r.Pk6, r.Sk6
+	default:
+		return "", "", fmt.Errorf("invalid GSI index %d", gsi)
+	}
+	if pk == nil || *pk == "" {
+		return "", "", ErrKeysNotDerived{GSI: gsi}
+	}
+	switch {
+	case gsi == 0 && *sk == "":
+		return *pk, "row", nil
+	case sk == nil:
+		return *pk, "", nil
+	}
+	return *pk, *sk, nil
+}
+
+// keysFromTags marks TaggedRow, whose keys are derived from tags.
+func (r *TaggedRow) keysFromTags() {}
+
+type taggedKeys interface {
+	keysFromTags()
+}
+
+// rowKeys returns the keys of index gsi of the row. The keys of rows that
+// embed TaggedRow are derived from their tags first.
+func rowKeys(row types.Keyable, gsi int) (string, string, error) {
+	if _, ok := row.(taggedKeys); ok {
+		if err := keytags.Apply(row); err != nil {
+			return "", "", err
+		}
+	}
+	return row.Keys(gsi)
+}
@@ -0,0 +1,70 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/entegral/gobox/keytags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Order struct {
+	TaggedRow
+	Customer string `pk:"1,prepend=CUSTOMER:" sk1:"1,prepend=CUSTOMER:"`
+	ID       string `sk:"1,prepend=ORDER:" pk1:"1,prepend=ORDER:" sk2:"1,prepend=ORDER:"`
+	Coupon   string `pk2:"1,prepend=COUPON:"`
+	Total    int
+}
+
+func (o *Order) Type() string {
+	return "order"
+}
+
+func TestTaggedRow(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	t.Run("keys are derived from the tags on put", func(t *testing.T) {
+		order := &Order{Customer: "c1", ID: "o1", Total: 10}
+		require.NoError(t, order.Put(ctx, order))
+		assert.Equal(t, "CUSTOMER:c1", order.PartitionKey)
+		assert.Equal(t, "ORDER:o1", order.SortKey)
+		require.NotNil(t, order.Pk1)
+		assert.Equal(t, "ORDER:o1", *order.Pk1)
+		assert.Equal(t, "CUSTOMER:c1", *order.Sk1)
+		assert.Nil(t, order.Pk2, "an empty partition key part keeps the row out of the sparse index")
+
+		loaded := &Order{Customer: "c1", ID: "o1"}
+		ok, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, 10, loaded.Total)
+		assert.Contains(t, loaded.RowData, "pk1")
+		assert.NotContains(t, loaded.RowData, "pk2")
+	})
+	t.Run("keys follow the fields", func(t *testing.T) {
+		order := &Order{Customer: "c1", ID: "o2", Coupon: "SPRING"}
+		require.NoError(t, order.Put(ctx, order))
+		pk, sk, err := order.Keys(2)
+		require.NoError(t, err)
+		assert.Equal(t, "COUPON:SPRING", pk)
+		assert.Equal(t, "ORDER:o2", sk)
+	})
+	t.Run("Keys needs the keys to be derived", func(t *testing.T) {
+		order := &Order{Customer: "c2", ID: "o3"}
+		_, _, err := order.Keys(0)
+		assert.True(t, errors.As(err, new(ErrKeysNotDerived)))
+		require.NoError(t, keytags.Apply(order))
+		pk, sk, err := order.Keys(0)
+		require.NoError(t, err)
+		assert.Equal(t, "CUSTOMER:c2", pk)
+		assert.Equal(t, "ORDER:o3", sk)
+	})
+	t.Run("missing partition key fields fail the operation", func(t *testing.T) {
+		order := &Order{ID: "o4"}
+		err := order.Put(ctx, order)
+		assert.True(t, errors.As(err, new(keytags.ErrZeroKeyField)))
+	})
+}
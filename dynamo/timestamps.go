@@ -0,0 +1,269 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The attributes holding the audit timestamps of Timestampable rows.
+const (
+	createdAtAttribute = "createdAt"
+	updatedAtAttribute = "updatedAt"
+)
+
+// timeNow returns the time recorded by timestamped puts.
+var timeNow = time.Now
+
+// Timestamps adds audit timestamps to a row. Embed it next to Row to make the
+// row implement types.Timestampable:
+//
+//	type User struct {
+//		dynamo.Row
+//		dynamo.Timestamps
+//		Email string
+//	}
+//
+// Put then sets UpdatedAt on every write, and CreatedAt only when the item is
+// first written. The timestamps are formatted with the types.DateTime format.
+type Timestamps struct {
+	CreatedAt *types.DateTime `dynamodbav:"createdAt,omitempty" json:"createdAt,omitempty"`
+	UpdatedAt *types.DateTime `dynamodbav:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+// SetTimestamps sets the created and updated times of the row.
+func (t *Timestamps) SetTimestamps(createdAt, updatedAt types.DateTime) {
+	t.CreatedAt = &createdAt
+	t.UpdatedAt = &updatedAt
+}
+
+// timestampedPut is a put of a Timestampable row. It is written with an
+// UpdateItem rather than a PutItem, so the stored createdAt is kept with
+// if_not_exists instead of being overwritten by the row's own, in a single
+// write whether or not the row was loaded.
+type timestampedPut struct {
+	row  types.Linkable
+	now  types.DateTime
+	item map[string]awstypes.AttributeValue
+	// stored is the item the put replaces, as far as it is known: the item
+	// the row was loaded from, or nil. Its attributes that the item does not
+	// have are removed by the update.
+	stored map[string]awstypes.AttributeValue
+}
+
+// newTimestampedPut returns the put of item, the marshalled row. It returns
+// false if the row is not Timestampable.
+func newTimestampedPut(row types.Linkable, item map[string]awstypes.AttributeValue) (*timestampedPut, bool) {
+	if _, ok := row.(types.Timestampable); !ok {
+		return nil, false
+	}
+	return &timestampedPut{row: row, now: types.DateTime{Time: timeNow()}, item: item, stored: getRowData(row)}, true
+}
+
+// setStored records the item the put replaces, normalising a missing item to
+// nil.
+func (p *timestampedPut) setStored(item map[string]awstypes.AttributeValue) {
+	if len(item) == 0 {
+		item = nil
+	}
+	p.stored = item
+}
+
+// input returns the update that writes the item if the condition holds.
+// Attributes of the stored item that the item does not have are removed.
+func (p *timestampedPut) input(tablename string, cond Condition) (*dynamodb.UpdateItemInput, error) {
+	e := newExpression("U")
+	now := e.value(p.now)
+	var set []string
+	for _, name := range sortedAttributes(p.item) {
+		switch name {
+		case "pk", "sk", createdAtAttribute, updatedAtAttribute:
+		default:
+			set = append(set, e.name(name)+" = "+e.value(p.item[name]))
+		}
+	}
+	created := e.name(createdAtAttribute)
+	set = append(set,
+		created+" = if_not_exists("+created+", "+now+")",
+		e.name(updatedAtAttribute)+" = "+now,
+	)
+	update := "SET " + strings.Join(set, ", ")
+	if stale := p.stale(p.stored); len(stale) > 0 {
+		remove := make([]string, len(stale))
+		for i, name := range stale {
+			remove[i] = e.name(name)
+		}
+		update += " REMOVE " + strings.Join(remove, ", ")
+	}
+	in := &dynamodb.UpdateItemInput{
+		TableName:        &tablename,
+		Key:              map[string]awstypes.AttributeValue{"pk": p.item["pk"], "sk": p.item["sk"]},
+		UpdateExpression: &update,
+	}
+	if !cond.IsZero() {
+		expr := cond.build(e)
+		in.ConditionExpression = &expr
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	in.ExpressionAttributeNames = e.names
+	in.ExpressionAttributeValues = e.values
+	return in, nil
+}
+
+// stale returns the sorted names of the attributes of old that the item
+// does not have, leaving out the timestamps.
+func (p *timestampedPut) stale(old map[string]awstypes.AttributeValue) []string {
+	var names []string
+	for _, name := range sortedAttributes(old) {
+		if _, ok := p.item[name]; !ok && name != createdAtAttribute && name != updatedAtAttribute {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// putWithClient writes the item if the condition holds. The output of the
+// update is returned as a PutItemOutput holding the replaced item.
+//
+// A row that was not loaded cannot name the attributes it replaces, so the
+// update leaves them in place. When the replaced item had any, they are
+// removed with a second update, sent only in that case and only while the
+// item is still the one written here, so the item ends up as a PutItem would
+// have left it without the common case paying for a second write.
+func (p *timestampedPut) putWithClient(ctx context.Context, client *clients.Client, tablename string, cond Condition) (*dynamodb.PutItemOutput, error) {
+	in, err := p.input(tablename, cond)
+	if err != nil {
+		return nil, err
+	}
+	in.ReturnValues = awstypes.ReturnValueAllOld
+	in.ReturnValuesOnConditionCheckFailure = awstypes.ReturnValuesOnConditionCheckFailureAllOld
+	in.ReturnConsumedCapacity = consumedCapacity()
+	out, err := client.Dynamo().UpdateItem(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	var removed map[string]bool
+	if p.stored != nil {
+		removed = map[string]bool{}
+		for _, name := range p.stale(p.stored) {
+			removed[name] = true
+		}
+	}
+	var left []string
+	for _, name := range p.stale(out.Attributes) {
+		if !removed[name] {
+			left = append(left, name)
+		}
+	}
+	if len(left) > 0 {
+		if err := p.removeWithClient(ctx, client, tablename, left); err != nil {
+			return nil, err
+		}
+	}
+	p.commit(out.Attributes)
+	return &dynamodb.PutItemOutput{Attributes: out.Attributes, ConsumedCapacity: out.ConsumedCapacity}, nil
+}
+
+// removeWithClient removes the attributes from the item written by the put,
+// unless another write replaced it since.
+func (p *timestampedPut) removeWithClient(ctx context.Context, client *clients.Client, tablename string, names []string) error {
+	e := newExpression("R")
+	remove := make([]string, len(names))
+	for i, name := range names {
+		remove[i] = e.name(name)
+	}
+	update := "REMOVE " + strings.Join(remove, ", ")
+	expr := Equal(updatedAtAttribute, p.now).build(e)
+	if e.err != nil {
+		return e.err
+	}
+	_, err := client.Dynamo().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &tablename,
+		Key:                       map[string]awstypes.AttributeValue{"pk": p.item["pk"], "sk": p.item["sk"]},
+		UpdateExpression:          &update,
+		ConditionExpression:       &expr,
+		ExpressionAttributeNames:  e.names,
+		ExpressionAttributeValues: e.values,
+		ReturnConsumedCapacity:    consumedCapacity(),
+	})
+	var ccf *awstypes.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		// A later write replaced the item, and with it the attributes.
+		return nil
+	}
+	return err
+}
+
+// consistentGet reads the item with the key with a consistent read. It
+// returns nil if there is none.
+func consistentGet(ctx context.Context, client *clients.Client, tablename string, key map[string]awstypes.AttributeValue) (map[string]awstypes.AttributeValue, error) {
+	out, err := client.Dynamo().GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &tablename,
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Item, nil
+}
+
+// commit sets the timestamps on the row once the put succeeded, and records
+// the written item as the row's RowData.
+func (p *timestampedPut) commit(old map[string]awstypes.AttributeValue) {
+	p.stamp(old)
+	setRowData(p.row, p.item)
+}
+
+// stamp sets the timestamps on the row and in the item. old is the item that
+// was replaced, if known: its createdAt is the one that was kept.
+func (p *timestampedPut) stamp(old map[string]awstypes.AttributeValue) {
+	created := p.stampItem(old)
+	p.row.(types.Timestampable).SetTimestamps(created, p.now)
+}
+
+// stampItem sets the timestamps in the item, taking createdAt from old if it
+// has one, and returns the createdAt it set.
+func (p *timestampedPut) stampItem(old map[string]awstypes.AttributeValue) types.DateTime {
+	created := p.now
+	p.item[createdAtAttribute], _ = created.MarshalDynamoDBAttributeValue()
+	if av, ok := old[createdAtAttribute]; ok {
+		var stored types.DateTime
+		if err := stored.UnmarshalDynamoDBAttributeValue(av); err == nil {
+			created = stored
+			p.item[createdAtAttribute] = av
+		}
+	}
+	p.item[updatedAtAttribute], _ = p.now.MarshalDynamoDBAttributeValue()
+	return created
+}
+
+// stampItem sets the timestamps of a Timestampable row in item for batch
+// puts, which are written with PutItem requests and so cannot keep the
+// stored createdAt with if_not_exists as Put does. The createdAt of the row
+// is kept if it has one.
+func stampItem(row types.Linkable, item map[string]awstypes.AttributeValue) {
+	if p, ok := newTimestampedPut(row, item); ok {
+		p.stamp(item)
+	}
+}
+
+func sortedAttributes(item map[string]awstypes.AttributeValue) []string {
+	names := make([]string, 0, len(item))
+	for name := range item {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
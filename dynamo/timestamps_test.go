@@ -0,0 +1,205 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type Note struct {
+	Row
+	Timestamps
+	ID   string
+	Body string
+	Tag  string `dynamodbav:",omitempty"`
+}
+
+func (n *Note) Type() string {
+	return "note"
+}
+
+func (n *Note) Keys(gsi int) (string, string, error) {
+	n.PartitionKey = n.ID
+	n.SortKey = "note"
+	return n.PartitionKey, n.SortKey, nil
+}
+
+// countWrites counts the PutItem and UpdateItem requests it serves.
+type countWrites struct {
+	clients.DynamoMethods
+	writes int
+}
+
+func (c *countWrites) PutItem(ctx context.Context, in *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.writes++
+	return c.DynamoMethods.PutItem(ctx, in, optFns...)
+}
+
+func (c *countWrites) UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	c.writes++
+	return c.DynamoMethods.UpdateItem(ctx, in, optFns...)
+}
+
+// useClock makes timestamped puts record the times returned by the clock.
+func useClock(t *testing.T, clock func() time.Time) {
+	previous := timeNow
+	timeNow = clock
+	t.Cleanup(func() { timeNow = previous })
+}
+
+func TestTimestamps(t *testing.T) {
+	db := useMemDB(t)
+	ctx := context.Background()
+	counted := func() (context.Context, *countWrites) {
+		counter := &countWrites{DynamoMethods: db}
+		client := db.Client().WithDynamo(counter)
+		return clients.WithClient(ctx, &client), counter
+	}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := created.Add(time.Hour)
+
+	t.Run("the first put sets both timestamps", func(t *testing.T) {
+		useClock(t, func() time.Time { return created })
+		note := &Note{ID: "n1", Body: "first", Tag: "draft"}
+		require.NoError(t, note.Put(ctx, note))
+		require.NotNil(t, note.CreatedAt)
+		assert.True(t, created.Equal(note.CreatedAt.Time))
+		assert.True(t, created.Equal(note.UpdatedAt.Time))
+	})
+	t.Run("later puts keep the stored createdAt", func(t *testing.T) {
+		useClock(t, func() time.Time { return updated })
+		note := &Note{ID: "n1", Body: "second", Tag: "draft"}
+		note.SetTimestamps(types.DateTime{Time: updated}, types.DateTime{})
+		require.NoError(t, note.Put(ctx, note))
+		assert.True(t, created.Equal(note.CreatedAt.Time))
+		assert.True(t, updated.Equal(note.UpdatedAt.Time))
+		assert.NotEmpty(t, note.OldPutValues())
+
+		loaded := &Note{ID: "n1"}
+		_, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, "second", loaded.Body)
+		assert.True(t, created.Equal(loaded.CreatedAt.Time))
+		assert.True(t, updated.Equal(loaded.UpdatedAt.Time))
+	})
+	t.Run("attributes cleared on a loaded row are removed", func(t *testing.T) {
+		note := &Note{ID: "n1"}
+		_, err := note.Get(ctx, note)
+		require.NoError(t, err)
+		note.Tag = ""
+		require.NoError(t, note.Put(ctx, note))
+
+		loaded := &Note{ID: "n1"}
+		_, err = loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Empty(t, loaded.Tag)
+		assert.NotContains(t, getRowData(loaded), "Tag")
+	})
+	t.Run("a row that was not loaded replaces the stored item", func(t *testing.T) {
+		useClock(t, func() time.Time { return updated })
+		note := &Note{ID: "n1", Body: "replaced"}
+		require.NoError(t, note.Put(ctx, note))
+		assert.True(t, created.Equal(note.CreatedAt.Time))
+
+		require.NoError(t, note.Put(ctx, &Note{ID: "n1", Body: "tagged", Tag: "stale"}))
+		unloaded := &Note{ID: "n1", Body: "untagged"}
+		countedCtx, counter := counted()
+		require.NoError(t, unloaded.Put(countedCtx, unloaded))
+		assert.True(t, created.Equal(unloaded.CreatedAt.Time))
+		assert.Equal(t, 2, counter.writes, "the stale attribute is removed with a second write")
+
+		loaded := &Note{ID: "n1"}
+		_, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, "untagged", loaded.Body)
+		assert.Empty(t, loaded.Tag)
+		assert.True(t, created.Equal(loaded.CreatedAt.Time))
+	})
+	t.Run("an existing row that was not loaded is written once", func(t *testing.T) {
+		useClock(t, func() time.Time { return updated })
+		unloaded := &Note{ID: "n1", Body: "once"}
+		countedCtx, counter := counted()
+		require.NoError(t, unloaded.Put(countedCtx, unloaded))
+		assert.Equal(t, 1, counter.writes)
+		assert.True(t, created.Equal(unloaded.CreatedAt.Time))
+	})
+	t.Run("a stale row keeps the createdAt stored by another writer", func(t *testing.T) {
+		stale := &Note{ID: "n4"}
+		useClock(t, func() time.Time { return created })
+		require.NoError(t, stale.Put(ctx, stale))
+
+		// The item is recreated after stale was written, with a later
+		// createdAt.
+		recreated := created.Add(time.Minute)
+		useClock(t, func() time.Time { return recreated })
+		require.NoError(t, stale.Delete(ctx, &Note{ID: "n4"}))
+		require.NoError(t, stale.Put(ctx, &Note{ID: "n4"}))
+
+		useClock(t, func() time.Time { return updated })
+		stale.Body = "stale"
+		countedCtx, counter := counted()
+		require.NoError(t, stale.Put(countedCtx, stale))
+		assert.Equal(t, 1, counter.writes)
+		assert.True(t, recreated.Equal(stale.CreatedAt.Time))
+
+		loaded := &Note{ID: "n4"}
+		_, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, "stale", loaded.Body)
+		assert.True(t, recreated.Equal(loaded.CreatedAt.Time))
+	})
+	t.Run("timestamps use the DateTime format", func(t *testing.T) {
+		types.SetDefaultDateTimeFormat(time.DateOnly)
+		t.Cleanup(types.ResetDefaultDateTimeFormat)
+		useClock(t, func() time.Time { return created })
+		note := &Note{ID: "n2"}
+		require.NoError(t, note.Put(ctx, note))
+
+		loaded := &Note{ID: "n2"}
+		_, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, &awstypes.AttributeValueMemberS{Value: "2024-01-02"}, getRowData(loaded)["createdAt"])
+	})
+	t.Run("conditions apply to timestamped puts", func(t *testing.T) {
+		note := &Note{ID: "n1", Body: "again"}
+		err := note.Create(ctx, note)
+		var exists ErrAlreadyExists
+		require.True(t, errors.As(err, &exists))
+		assert.NotNil(t, exists.Current)
+		assert.Nil(t, note.CreatedAt)
+	})
+	t.Run("transactions keep the stored createdAt", func(t *testing.T) {
+		useClock(t, func() time.Time { return updated })
+		note := &Note{ID: "n1"}
+		_, err := note.Get(ctx, note)
+		require.NoError(t, err)
+		note.Body = "transacted"
+		_, err = NewTransaction().Put(note).Exec(ctx)
+		require.NoError(t, err)
+		assert.True(t, created.Equal(note.CreatedAt.Time))
+
+		unloaded := &Note{ID: "n1", Body: "unloaded"}
+		_, err = NewTransaction().Put(unloaded).Exec(ctx)
+		require.NoError(t, err)
+		assert.True(t, created.Equal(unloaded.CreatedAt.Time))
+		loaded := &Note{ID: "n1"}
+		_, err = loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, "unloaded", loaded.Body)
+		assert.True(t, created.Equal(loaded.CreatedAt.Time))
+
+		fresh := &Note{ID: "n3"}
+		_, err = NewTransaction().Put(fresh).Exec(ctx)
+		require.NoError(t, err)
+		assert.True(t, updated.Equal(fresh.CreatedAt.Time))
+	})
+}
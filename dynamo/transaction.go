@@ -0,0 +1,491 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// conditionalCheckFailed is the cancellation reason code DynamoDB reports
+// for an operation whose condition was not met.
+const conditionalCheckFailed = "ConditionalCheckFailed"
+
+// ErrTransactionItem describes an operation that caused a transaction to be
+// canceled. Index is the position of the operation in the transaction and
+// Code the cancellation reason reported by DynamoDB, such as
+// ConditionalCheckFailed or TransactionConflict. When the condition of an
+// operation failed, Item holds the stored item, if there was one.
+type ErrTransactionItem struct {
+	Index   int
+	Row     types.Keyable
+	Code    string
+	Message string
+	Item    map[string]awstypes.AttributeValue
+	// Err is the error the operation reports in place of the cancellation
+	// reason, if any.
+	Err error
+}
+
+func (e ErrTransactionItem) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("transaction item %d: %v", e.Index, e.Err)
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("transaction item %d: %s: %s", e.Index, e.Code, e.Message)
+	}
+	return fmt.Sprintf("transaction item %d: %s", e.Index, e.Code)
+}
+
+func (e ErrTransactionItem) Unwrap() error {
+	return e.Err
+}
+
+// ConditionFailed reports whether the operation's condition was not met.
+func (e ErrTransactionItem) ConditionFailed() bool {
+	return e.Code == conditionalCheckFailed
+}
+
+// ErrTransactionCanceled is returned when DynamoDB cancels a transaction.
+// Items holds an ErrTransactionItem for every operation that caused the
+// cancellation; errors.As can recover them individually.
+type ErrTransactionCanceled struct {
+	Items []ErrTransactionItem
+	Err   *awstypes.TransactionCanceledException
+}
+
+func (e ErrTransactionCanceled) Error() string {
+	if len(e.Items) == 0 {
+		return fmt.Sprintf("transaction canceled: %v", e.Err)
+	}
+	msgs := make([]string, len(e.Items))
+	for i, item := range e.Items {
+		msgs[i] = item.Error()
+	}
+	return "transaction canceled: " + strings.Join(msgs, "; ")
+}
+
+func (e ErrTransactionCanceled) Unwrap() []error {
+	errs := make([]error, 0, len(e.Items)+1)
+	for _, item := range e.Items {
+		errs = append(errs, item)
+	}
+	return append(errs, e.Err)
+}
+
+// transactOp is an operation of a Transaction. build is called with the
+// table name override of the transaction, which may be empty.
+type transactOp struct {
+	row   types.Keyable
+	build func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error)
+	// failed returns the error reported for the operation when its
+	// condition fails, given the stored item. It may be nil.
+	failed func(current map[string]awstypes.AttributeValue) error
+	// prepare is called by Exec after the before hooks, with the table
+	// name override of the transaction, to read what build needs. It may be
+	// nil.
+	prepare func(ctx context.Context, client *clients.Client, tablename string) error
+	// commit is called once the transaction succeeded. It may be nil.
+	commit func()
+	// before and after are the lifecycle hooks of the operation. They may
+	// be nil.
+	before, after func(ctx context.Context, client *clients.Client, row any) error
+}
+
+// Transaction collects Put, Delete, Update and ConditionCheck operations on
+// rows and executes them atomically with TransactWriteItems:
+//
+//	_, err := dynamo.NewTransaction().
+//		Put(order).
+//		Delete(cart).
+//		Update(inventory).
+//		Exec(ctx)
+//
+// Rows are written with the same keys, type, shard and ttl as Put. If
+// DynamoDB cancels the transaction, nothing is written and an
+// ErrTransactionCanceled describing each failed operation is returned.
+type Transaction struct {
+	ops       []transactOp
+	client    *clients.Client
+	tablename string
+}
+
+// NewTransaction returns an empty transaction.
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// WithClient sets the client used to execute the transaction. By default the
+// client carried by ctx, or the default client, is used.
+func (t *Transaction) WithClient(client *clients.Client) *Transaction {
+	t.client = client
+	return t
+}
+
+// WithTableName overrides the table name returned by each row's TableName
+// method.
+func (t *Transaction) WithTableName(tablename string) *Transaction {
+	t.tablename = tablename
+	return t
+}
+
+// Put writes the row, replacing any existing item with the same key. A
+// types.Versionable row is only written if its stored version is the one
+// that was loaded; otherwise the operation fails with ErrVersionConflict.
+// A types.Timestampable row is written with an update that keeps the stored
+// createdAt. The update removes the attributes of the stored item the row
+// does not have, which Exec reads before the transaction is sent; attributes
+// that another write adds in between are left in place.
+func (t *Transaction) Put(row types.Linkable) *Transaction {
+	var vp *versionedPut
+	var tp *timestampedPut
+	var stored map[string]awstypes.AttributeValue
+	var read bool
+	return t.add(transactOp{
+		row:    row,
+		before: beforePut,
+		after:  afterPut,
+		prepare: func(ctx context.Context, client *clients.Client, tablename string) error {
+			if _, ok := row.(types.Timestampable); !ok {
+				return nil
+			}
+			key, err := rowKey(row)
+			if err != nil {
+				return err
+			}
+			stored, err = consistentGet(ctx, client, rowTableName(ctx, row, tablename), key)
+			read = err == nil
+			return err
+		},
+		build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+			av, err := putItemAttributes(row, rowTTL(row))
+			if err != nil {
+				return awstypes.TransactWriteItem{}, err
+			}
+			tn := rowTableName(ctx, row, tablename)
+			var cond Condition
+			var ok bool
+			if vp, ok = newVersionedPut(row, av); ok {
+				cond = vp.condition()
+			}
+			if tp, ok = newTimestampedPut(row, av); ok {
+				// Without Exec, the attributes removed are those of the item
+				// the row was loaded from.
+				if read {
+					tp.setStored(stored)
+				}
+				in, err := tp.input(tn, cond)
+				if err != nil {
+					return awstypes.TransactWriteItem{}, err
+				}
+				return awstypes.TransactWriteItem{Update: &awstypes.Update{
+					TableName:                           in.TableName,
+					Key:                                 in.Key,
+					UpdateExpression:                    in.UpdateExpression,
+					ConditionExpression:                 in.ConditionExpression,
+					ExpressionAttributeNames:            in.ExpressionAttributeNames,
+					ExpressionAttributeValues:           in.ExpressionAttributeValues,
+					ReturnValuesOnConditionCheckFailure: awstypes.ReturnValuesOnConditionCheckFailureAllOld,
+				}}, nil
+			}
+			put := &awstypes.Put{
+				TableName:                           aws.String(tn),
+				Item:                                av,
+				ReturnValuesOnConditionCheckFailure: awstypes.ReturnValuesOnConditionCheckFailureAllOld,
+			}
+			if !cond.IsZero() {
+				expr, names, values, err := cond.Render()
+				if err != nil {
+					return awstypes.TransactWriteItem{}, err
+				}
+				put.ConditionExpression = aws.String(expr)
+				put.ExpressionAttributeNames = names
+				put.ExpressionAttributeValues = values
+			}
+			return awstypes.TransactWriteItem{Put: put}, nil
+		},
+		failed: func(current map[string]awstypes.AttributeValue) error {
+			if vp == nil {
+				return nil
+			}
+			return vp.conflict(current)
+		},
+		commit: func() {
+			// Transactions do not return the replaced item, so the createdAt
+			// kept is taken to be the one of the stored item that was read,
+			// or of the item the row was loaded from.
+			if tp != nil {
+				tp.commit(tp.stored)
+			}
+			if vp != nil {
+				vp.commit()
+			}
+		},
+	})
+}
+
+// Delete deletes the row.
+func (t *Transaction) Delete(row types.Linkable) *Transaction {
+	return t.add(transactOp{row: row, before: beforeDelete, after: afterDelete, build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+		key, err := rowKey(row)
+		if err != nil {
+			return awstypes.TransactWriteItem{}, err
+		}
+		return awstypes.TransactWriteItem{Delete: &awstypes.Delete{
+			TableName:                           aws.String(rowTableName(ctx, row, tablename)),
+			Key:                                 key,
+			ReturnValuesOnConditionCheckFailure: awstypes.ReturnValuesOnConditionCheckFailureAllOld,
+		}}, nil
+	}})
+}
+
+// ConditionCheck cancels the transaction unless the condition expression
+// holds for the row's stored item. The names and values maps hold the
+// expression attribute names and values and may be nil.
+func (t *Transaction) ConditionCheck(row types.Linkable, expression string, names map[string]string, values map[string]awstypes.AttributeValue) *Transaction {
+	return t.add(transactOp{row: row, build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+		key, err := rowKey(row)
+		if err != nil {
+			return awstypes.TransactWriteItem{}, err
+		}
+		return awstypes.TransactWriteItem{ConditionCheck: &awstypes.ConditionCheck{
+			TableName:                           aws.String(rowTableName(ctx, row, tablename)),
+			Key:                                 key,
+			ConditionExpression:                 aws.String(expression),
+			ExpressionAttributeNames:            names,
+			ExpressionAttributeValues:           values,
+			ReturnValuesOnConditionCheckFailure: awstypes.ReturnValuesOnConditionCheckFailureAllOld,
+		}}, nil
+	}})
+}
+
+// Update applies the UpdateItemInput returned by the row's DynamoUpdateInput
+// method. If the row is Linkable, the item is addressed by the row's
+// type-prefixed key, like every other operation of the transaction; an input
+// that carries a different key, such as the row's key without the type
+// prefix, fails the transaction before it is sent. Otherwise the input's key
+// is used as the stored key of the item. If the input has no table name, the
+// transaction's table is used.
+func (t *Transaction) Update(row types.DynamoUpdater) *Transaction {
+	return t.add(transactOp{row: row, before: beforeUpdate, after: afterUpdate, build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+		input, err := row.DynamoUpdateInput(ctx)
+		if err != nil {
+			return awstypes.TransactWriteItem{}, err
+		}
+		key := input.Key
+		if linkable, ok := row.(types.Linkable); ok {
+			stored, err := rowKey(linkable)
+			if err != nil {
+				return awstypes.TransactWriteItem{}, err
+			}
+			if key != nil && (len(key) != len(stored) || keyID(key) != keyID(stored)) {
+				given, _ := itemKey(key)
+				want, _ := itemKey(stored)
+				return awstypes.TransactWriteItem{}, fmt.Errorf("update input key %+v is not the stored key %+v of the row", given, want)
+			}
+			key = stored
+		} else if key == nil {
+			return awstypes.TransactWriteItem{}, errors.New("update input has no key and the row is not Linkable")
+		}
+		if tablename == "" {
+			tablename = aws.ToString(input.TableName)
+		}
+		if tablename == "" {
+			tablename = rowTableName(ctx, row, "")
+		}
+		return awstypes.TransactWriteItem{Update: &awstypes.Update{
+			TableName:                           aws.String(tablename),
+			Key:                                 key,
+			UpdateExpression:                    input.UpdateExpression,
+			ConditionExpression:                 input.ConditionExpression,
+			ExpressionAttributeNames:            input.ExpressionAttributeNames,
+			ExpressionAttributeValues:           input.ExpressionAttributeValues,
+			ReturnValuesOnConditionCheckFailure: awstypes.ReturnValuesOnConditionCheckFailureAllOld,
+		}}, nil
+	}})
+}
+
+// checkExists cancels the transaction unless an item with the key exists.
+// If it does not, err is reported for the operation.
+func (t *Transaction) checkExists(key map[string]awstypes.AttributeValue, err error) *Transaction {
+	failed := func(map[string]awstypes.AttributeValue) error { return err }
+	return t.add(transactOp{failed: failed, build: func(ctx context.Context, tablename string) (awstypes.TransactWriteItem, error) {
+		if tablename == "" {
+			tablename = clients.TableName(ctx)
+		}
+		return awstypes.TransactWriteItem{ConditionCheck: &awstypes.ConditionCheck{
+			TableName:           aws.String(tablename),
+			Key:                 key,
+			ConditionExpression: aws.String("attribute_exists(pk)"),
+		}}, nil
+	}})
+}
+
+func (t *Transaction) add(op transactOp) *Transaction {
+	t.ops = append(t.ops, op)
+	return t
+}
+
+// Input builds the dynamodb.TransactWriteItemsInput executed by Exec.
+func (t *Transaction) Input(ctx context.Context) (*dynamodb.TransactWriteItemsInput, error) {
+	if len(t.ops) == 0 {
+		return nil, errors.New("transaction has no operations")
+	}
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems:          make([]awstypes.TransactWriteItem, len(t.ops)),
+		ReturnConsumedCapacity: consumedCapacity(),
+	}
+	for i, op := range t.ops {
+		item, err := op.build(ctx, t.tablename)
+		if err != nil {
+			return nil, fmt.Errorf("transaction item %d: %w", i, err)
+		}
+		input.TransactItems[i] = item
+	}
+	return input, nil
+}
+
+// Exec executes the transaction. The Before hooks of every row run first,
+// and a failing hook aborts the transaction. The stored items of
+// types.Timestampable rows that are put are then read, to remove the
+// attributes the rows do not have. If DynamoDB cancels it, the returned
+// error is an ErrTransactionCanceled. Once it succeeded, the After hooks run
+// and their errors are joined.
+func (t *Transaction) Exec(ctx context.Context) (*dynamodb.TransactWriteItemsOutput, error) {
+	client := t.client
+	if client == nil {
+		var err error
+		if client, err = clients.Resolve(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for i, op := range t.ops {
+		if op.before == nil {
+			continue
+		}
+		if err := op.before(ctx, client, op.row); err != nil {
+			return nil, fmt.Errorf("transaction item %d: %w", i, err)
+		}
+	}
+	for i, op := range t.ops {
+		if op.prepare == nil {
+			continue
+		}
+		if err := op.prepare(ctx, client, t.tablename); err != nil {
+			return nil, fmt.Errorf("transaction item %d: %w", i, err)
+		}
+	}
+	input, err := t.Input(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.Dynamo().TransactWriteItems(ctx, input)
+	if err != nil {
+		return nil, t.canceled(err)
+	}
+	var errs []error
+	for i, op := range t.ops {
+		if op.commit != nil {
+			op.commit()
+		}
+		if op.after == nil {
+			continue
+		}
+		if err := op.after(ctx, client, op.row); err != nil {
+			errs = append(errs, fmt.Errorf("transaction item %d: %w", i, err))
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// canceled converts a TransactionCanceledException into an
+// ErrTransactionCanceled. Other errors are returned unchanged.
+func (t *Transaction) canceled(err error) error {
+	var tce *awstypes.TransactionCanceledException
+	if !errors.As(err, &tce) {
+		return err
+	}
+	canceled := ErrTransactionCanceled{Err: tce}
+	for i, reason := range tce.CancellationReasons {
+		code := aws.ToString(reason.Code)
+		if code == "" || code == "None" {
+			continue
+		}
+		item := ErrTransactionItem{
+			Index:   i,
+			Code:    code,
+			Message: aws.ToString(reason.Message),
+			Item:    reason.Item,
+		}
+		if i < len(t.ops) {
+			item.Row = t.ops[i].row
+			if code == conditionalCheckFailed && t.ops[i].failed != nil {
+				item.Err = t.ops[i].failed(reason.Item)
+			}
+		}
+		canceled.Items = append(canceled.Items, item)
+	}
+	return canceled
+}
+
+// TransactGet loads the rows with a single TransactGetItems request, so they
+// are read as one consistent snapshot. The returned results are in the same
+// order as rows. A row that does not exist gets an ErrItemNotFound, like
+// Get. The error is set when the request itself fails.
+func TransactGet(ctx context.Context, rows ...types.Linkable) ([]Result, error) {
+	client, err := clients.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	input := &dynamodb.TransactGetItemsInput{
+		TransactItems:          make([]awstypes.TransactGetItem, len(rows)),
+		ReturnConsumedCapacity: consumedCapacity(),
+	}
+	for i, row := range rows {
+		if err := beforeGet(ctx, client, row); err != nil {
+			return nil, fmt.Errorf("transaction item %d: %w", i, err)
+		}
+		key, err := rowKey(row)
+		if err != nil {
+			return nil, fmt.Errorf("transaction item %d: %w", i, err)
+		}
+		input.TransactItems[i] = awstypes.TransactGetItem{Get: &awstypes.Get{
+			TableName: aws.String(row.TableName(ctx)),
+			Key:       key,
+		}}
+	}
+	out, err := client.Dynamo().TransactGetItems(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(rows))
+	for i, row := range rows {
+		results[i].Index = i
+		if i >= len(out.Responses) || out.Responses[i].Item == nil {
+			results[i].Error = &ErrItemNotFound{Row: row}
+			continue
+		}
+		if err := unmarshalItemInto(row, out.Responses[i].Item); err != nil {
+			results[i].Error = err
+			continue
+		}
+		results[i].Loaded = true
+		results[i].Error = afterGet(ctx, client, row)
+	}
+	return results, nil
+}
+
+// rowTableName returns the override, or else the row's table name.
+func rowTableName(ctx context.Context, row types.Keyable, override string) string {
+	if override != "" {
+		return override
+	}
+	return types.CheckTableable(ctx, row)
+}
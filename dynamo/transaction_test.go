@@ -0,0 +1,132 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rawKeyBirthday increments a user's age, addressing the user by its key
+// without the type prefix.
+type rawKeyBirthday struct {
+	*User
+}
+
+func (b rawKeyBirthday) DynamoUpdateInput(ctx context.Context) (*dynamodb.UpdateItemInput, error) {
+	pk, sk, err := b.Keys(0)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.UpdateItemInput{
+		Key: map[string]awstypes.AttributeValue{
+			"pk": &awstypes.AttributeValueMemberS{Value: pk},
+			"sk": &awstypes.AttributeValueMemberS{Value: sk},
+		},
+		UpdateExpression:          aws.String("ADD Age :one"),
+		ExpressionAttributeValues: map[string]awstypes.AttributeValue{":one": &awstypes.AttributeValueMemberN{Value: "1"}},
+	}, nil
+}
+
+// birthday increments a user's age. Its update input has no key, so the
+// transaction derives it from the user.
+type birthday struct {
+	*User
+}
+
+func (b birthday) DynamoUpdateInput(ctx context.Context) (*dynamodb.UpdateItemInput, error) {
+	return &dynamodb.UpdateItemInput{
+		UpdateExpression:          aws.String("ADD Age :one"),
+		ExpressionAttributeValues: map[string]awstypes.AttributeValue{":one": &awstypes.AttributeValueMemberN{Value: "1"}},
+	}, nil
+}
+
+func TestTransaction(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	keep := &User{Email: "keep@gmail.com", Name: "Keep", Age: 20}
+	doomed := &User{Email: "doomed@gmail.com", Name: "Doomed"}
+	gate := &User{Email: "gate@gmail.com", Name: "Gate", Age: 20}
+	car := &Car{Make: "TxMake", Model: "TxModel", Year: 2020}
+	require.NoError(t, keep.Put(ctx, keep))
+	require.NoError(t, doomed.Put(ctx, doomed))
+	require.NoError(t, gate.Put(ctx, gate))
+
+	t.Run("a failed condition cancels every operation", func(t *testing.T) {
+		_, err := NewTransaction().
+			Put(car).
+			Delete(doomed).
+			Update(birthday{keep}).
+			ConditionCheck(gate, "Age > :age", nil, map[string]awstypes.AttributeValue{
+				":age": &awstypes.AttributeValueMemberN{Value: "30"},
+			}).
+			Exec(ctx)
+		var canceled ErrTransactionCanceled
+		require.True(t, errors.As(err, &canceled))
+		require.Len(t, canceled.Items, 1)
+		item := canceled.Items[0]
+		assert.Equal(t, 3, item.Index)
+		assert.True(t, item.ConditionFailed())
+		assert.Equal(t, gate, item.Row)
+		assert.Equal(t, &awstypes.AttributeValueMemberN{Value: "20"}, item.Item["Age"])
+		assert.True(t, errors.As(err, new(ErrTransactionItem)))
+
+		ok, err := CreateUser(doomed.Email).Get(ctx, CreateUser(doomed.Email))
+		assert.True(t, ok)
+		assert.NoError(t, err)
+	})
+	t.Run("every operation is applied when all conditions hold", func(t *testing.T) {
+		_, err := NewTransaction().
+			Put(car).
+			Delete(doomed).
+			Update(birthday{keep}).
+			ConditionCheck(gate, "attribute_exists(pk)", nil, nil).
+			Exec(ctx)
+		require.NoError(t, err)
+
+		results, err := TransactGet(ctx, CreateUser(keep.Email), CreateUser(doomed.Email), &Car{Make: car.Make, Model: car.Model, Year: car.Year})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.True(t, results[0].Loaded)
+		assert.IsType(t, &ErrItemNotFound{}, results[1].Error)
+		assert.True(t, results[2].Loaded)
+	})
+	t.Run("TransactGet unmarshals into the rows", func(t *testing.T) {
+		loaded := CreateUser(keep.Email)
+		_, err := TransactGet(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, 21, loaded.Age)
+		assert.NotNil(t, loaded.RowData)
+	})
+	t.Run("puts are decorated like Put", func(t *testing.T) {
+		input, err := NewTransaction().Put(car).WithTableName("other").Input(ctx)
+		require.NoError(t, err)
+		put := input.TransactItems[0].Put
+		assert.Equal(t, "other", aws.ToString(put.TableName))
+		assert.Equal(t, &awstypes.AttributeValueMemberS{Value: car.Type()}, put.Item["type"])
+		assert.NotNil(t, put.Item["pkshard"])
+		key, err := rowKey(car)
+		require.NoError(t, err)
+		assert.Equal(t, key["pk"], put.Item["pk"])
+	})
+	t.Run("updates address the row by its stored key", func(t *testing.T) {
+		input, err := NewTransaction().Update(rename{Profile: &Profile{ID: "p0"}, to: "jj"}).Input(ctx)
+		require.NoError(t, err)
+		key, err := rowKey(&Profile{ID: "p0"})
+		require.NoError(t, err)
+		assert.Equal(t, key, input.TransactItems[0].Update.Key)
+
+		_, err = NewTransaction().Update(rawKeyBirthday{keep}).Input(ctx)
+		assert.ErrorContains(t, err, "is not the stored key")
+	})
+	t.Run("an empty transaction is an error", func(t *testing.T) {
+		_, err := NewTransaction().Exec(ctx)
+		assert.Error(t, err)
+	})
+}
@@ -6,10 +6,10 @@ import (
 	"github.com/entegral/gobox/types"
 )
 
-// TriLink is a generic type that can link two entities together in dynamo.
-// By Default the TriLink will establish a one-to-one relationship between the two
-// entities using the primary keys. If you need to save or modify fields in the
-// linked record, you will need to override this method.
+// TriLink is a generic type that can link three entities together in dynamo.
+// Like a DiLink, its link type may implement CardinalityDeclarer to limit
+// the links its Entity0 or Entity1 take part in. If you need to save or
+// modify fields in the linked record, you will need to override this method.
 type TriLink[T0, T1, T2 types.Linkable] struct {
 	DiLink[T0, T1] // Embedding the DiLink type for DynamoDB requirements
 
@@ -42,16 +42,3 @@ func (r *TriLink[T0, T1, T2]) Type() string {
 	}
 	return r.UnmarshalledType
 }
-
-// Link is a generic method to establish a connection between the two entities. By default
-// it will establish a one-to-one relationship between the two entities using the primary keys.
-// If the relation is set to OneToMany, then it will establish a one-to-many relationship
-// between the two entities where Entity0 is the "one" and Entity1 is the "many".
-func (m *TriLink[T0, T1, T2]) Link(ctx context.Context, row types.Linkable) error {
-	return m.Put(ctx, row)
-}
-
-// Unlink method to remove the connection between the two entities.
-func (m *TriLink[T0, T1, T2]) Unlink(ctx context.Context, row types.Linkable) error {
-	return m.Delete(ctx, row)
-}
@@ -2,63 +2,37 @@ package dynamo
 
 import (
 	"context"
-	"reflect"
 
-	"github.com/entegral/gobox/clients"
-
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/entegral/gobox/types"
 )
 
 func (m *TriLink[T0, T1, T2]) LoadEntity2(ctx context.Context) (bool, error) {
-	var pk, sk, e2pk, e2sk string
-	var err error
-
-	if !reflect.ValueOf(m.Entity2).IsNil() {
-		pk, sk, err = m.Entity2.Keys(0)
-		if err != nil {
-			return false, err
-		}
-		seg, err := addKeySegment(rowType, m.Entity2.Type())
-		if err != nil {
-			return false, err
-		}
-		e2pk = seg
-		seg, err = addKeySegment(rowPk, pk)
-		if err != nil {
-			return false, err
-		}
-		e2pk += seg
-		e2sk = sk
-	} else {
-		e2pk, e2sk, err = m.ExtractE2Keys()
-		if err != nil {
-			return false, err
-		}
-	}
-
-	tn := m.TableName(ctx)
-	clients := clients.GetDefaultClient(ctx)
-	out, err := clients.Dynamo().GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: &tn,
-		Key: map[string]awstypes.AttributeValue{
-			"pk": &awstypes.AttributeValueMemberS{Value: e2pk},
-			"sk": &awstypes.AttributeValueMemberS{Value: e2sk},
-		},
-	})
-	if err != nil {
-		return false, err
-	}
-	if out.Item == nil {
+	loaded, err := loadLinkedEntity(ctx, m.slots(), 2, &m.Entity2)
+	if err == nil && !loaded {
 		return false, &ErrEntityNotFound[T2]{Entity: m.Entity2}
 	}
-	if err := validateDynamoRowType[T2](out.Item, m.Entity2); err != nil {
-		return false, err
-	}
-	err = attributevalue.UnmarshalMap(out.Item, &m.Entity2)
-	if err != nil {
-		return false, err
-	}
-	return true, nil
+	return loaded, err
+}
+
+// LoadEntity0s returns the Entity0 of every link of the type whose Entity1
+// and Entity2 are those of m. Either of them may be left unset to match any
+// entity; at least one must be set. The entities are loaded with batched
+// reads, each one once.
+func (m *TriLink[T0, T1, T2]) LoadEntity0s(ctx context.Context, linkWrapper types.Typeable) ([]T0, error) {
+	fixed := fixedEntities(nil, m.Entity1, m.Entity2)
+	return loadSlotEntities[T0](ctx, &m.DBManager, linkWrapper.Type(), 0, fixed)
+}
+
+// LoadEntity1s returns the Entity1 of every link of the type whose Entity0
+// and Entity2 are those of m, either of which may be left unset.
+func (m *TriLink[T0, T1, T2]) LoadEntity1s(ctx context.Context, linkWrapper types.Typeable) ([]T1, error) {
+	fixed := fixedEntities(m.Entity0, nil, m.Entity2)
+	return loadSlotEntities[T1](ctx, &m.DBManager, linkWrapper.Type(), 1, fixed)
+}
+
+// LoadEntity2s returns the Entity2 of every link of the type whose Entity0
+// and Entity1 are those of m, either of which may be left unset.
+func (m *TriLink[T0, T1, T2]) LoadEntity2s(ctx context.Context, linkWrapper types.Typeable) ([]T2, error) {
+	fixed := fixedEntities(m.Entity0, m.Entity1, nil)
+	return loadSlotEntities[T2](ctx, &m.DBManager, linkWrapper.Type(), 2, fixed)
 }
@@ -1,81 +1,36 @@
 package dynamo
 
-func (m *TriLink[T0, T1, T2]) GenerateTriLinkCompositeKey() (string, string, error) {
-	// generate keys for the 0th entity
-	_, _, err := m.GenerateDiLinkKeys()
-	if err != nil {
-		return "", "", err
-	}
-
-	// Generate third part of the key using the entity2 type, pk, and sk
-	// to ensure uniqueness of the key
-	e2pk, e2sk, err := m.Entity2.Keys(0)
-	if err != nil {
-		return "", "", err
-	}
+// slots returns the entity slots of the trilink.
+func (m *TriLink[T0, T1, T2]) slots() linkSlots {
+	l := m.DiLink.slots()
+	l.slots = append(l.slots, linkSlot{entity: m.Entity2, pk: &m.E2pk, sk: &m.E2sk, missing: ErrEntityNotFound[T2]{Entity: m.Entity2}})
+	return l
+}
 
-	linkedE2PartitionKey, err := addKeySegment(rowType, m.Entity2.Type())
-	if err != nil {
-		return "", "", err
-	}
-	seg, err := addKeySegment(rowPk, e2pk)
-	if err != nil {
-		return "", "", err
-	}
-	linkedE2PartitionKey += seg
+// GenerateLinkKeys generates the composite key for the trilink. Keys too
+// long to be stored are hashed.
+func (m *TriLink[T0, T1, T2]) GenerateLinkKeys() (string, string, error) {
+	return m.slots().generate()
+}
 
-	m.E2pk = linkedE2PartitionKey
-	m.E2sk = e2sk
+// GenerateTriLinkCompositeKey generates the composite key for the trilink,
+// like GenerateLinkKeys.
+func (m *TriLink[T0, T1, T2]) GenerateTriLinkCompositeKey() (string, string, error) {
+	return m.GenerateLinkKeys()
+}
 
-	seg, err = addKeySegment(entity2Type, m.Entity2.Type())
-	if err != nil {
-		return "", "", err
-	}
-	m.PartitionKey += seg
-	seg, err = addKeySegment(entity2pk, e2pk)
-	if err != nil {
-		return "", "", err
-	}
-	m.PartitionKey += seg
-	seg, err = addKeySegment(entity2sk, e2sk)
-	if err != nil {
-		return "", "", err
-	}
-	m.SortKey += seg
-	return m.PartitionKey, m.SortKey, nil
+// ExtractEntityKeys extracts the pk and sk values for the entity in the slot
+// from the primary composite key.
+func (m *TriLink[T0, T1, T2]) ExtractEntityKeys(slot int) (string, string, error) {
+	return m.slots().extractSlot(slot)
 }
 
+// ExtractE2Keys extracts the pk and sk values for the 2nd entity from the
+// primary composite key.
 func (m *TriLink[T0, T1, T2]) ExtractE2Keys() (string, string, error) {
-	if m.PartitionKey == "" || m.SortKey == "" {
-		_, _, err := m.GenerateTriLinkCompositeKey()
-		if err != nil {
-			return "", "", err
-		}
-	}
-	if m.E2pk != "" && m.E2sk != "" {
-		return m.E2pk, m.E2sk, nil
-	}
-	pk2 := extractKeys(entity2pk, m.PartitionKey)
-	sk2 := extractKeys(entity2sk, m.SortKey)
-
-	return pk2, sk2, nil
+	return m.slots().extract(2)
 }
 
 func (m *TriLink[T0, T1, T2]) Keys(gsi int) (string, string, error) {
-	// by default, we will only prefix the primary keys of both entities with "link-".
-	// this will create a 1-1 relationship between the two entities.
-	_, _, err := m.GenerateTriLinkCompositeKey()
-	if err != nil {
-		return "", "", err
-	}
-
-	switch gsi {
-	case 0: // Primary keys
-		return m.PartitionKey, m.SortKey, nil
-	case 1: // GSI 1
-		return *m.Pk1, *m.Sk1, nil
-	default:
-		// Handle other GSIs or return an error
-		return "", "", ErrInvalidGSI{GSI: gsi}
-	}
+	return m.slots().keys(gsi)
 }
@@ -0,0 +1,283 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrEmptyUpdate is returned when an Update without actions is applied.
+type ErrEmptyUpdate struct{}
+
+func (e ErrEmptyUpdate) Error() string {
+	return "update has no actions"
+}
+
+// Update is an update expression for UpdateItem. Build one by chaining its
+// methods rather than writing the expression by hand: attribute names and
+// values are always passed as expression attribute placeholders.
+//
+//	update := dynamo.NewUpdate().
+//		Set("Name", "Jane").
+//		Increment("Logins", 1).
+//		Append("History", event).
+//		AddToSet("Tags", dynamo.StringSet("admin")).
+//		Remove("Token")
+//
+// As in Condition, attribute names may be document paths.
+type Update struct {
+	set, remove, add, del []func(e *expression) string
+	cond                  Condition
+}
+
+// NewUpdate returns an empty update.
+func NewUpdate() *Update {
+	return &Update{}
+}
+
+// Set sets the attribute to the value.
+func (u *Update) Set(name string, value any) *Update {
+	u.set = append(u.set, func(e *expression) string {
+		return e.name(name) + " = " + e.value(value)
+	})
+	return u
+}
+
+// SetIfNotExists sets the attribute to the value only if the item does not
+// have the attribute yet.
+func (u *Update) SetIfNotExists(name string, value any) *Update {
+	u.set = append(u.set, func(e *expression) string {
+		path := e.name(name)
+		return fmt.Sprintf("%s = if_not_exists(%s, %s)", path, path, e.value(value))
+	})
+	return u
+}
+
+// Increment atomically adds by to the number attribute, starting from zero
+// if the item does not have it.
+func (u *Update) Increment(name string, by int64) *Update {
+	return u.counter(name, "+", by)
+}
+
+// Decrement atomically subtracts by from the number attribute, starting
+// from zero if the item does not have it.
+func (u *Update) Decrement(name string, by int64) *Update {
+	return u.counter(name, "-", by)
+}
+
+func (u *Update) counter(name, op string, by int64) *Update {
+	u.set = append(u.set, func(e *expression) string {
+		path := e.name(name)
+		zero := e.value(&awstypes.AttributeValueMemberN{Value: "0"})
+		delta := e.value(&awstypes.AttributeValueMemberN{Value: strconv.FormatInt(by, 10)})
+		return fmt.Sprintf("%s = if_not_exists(%s, %s) %s %s", path, path, zero, op, delta)
+	})
+	return u
+}
+
+// Append appends the values to the list attribute, creating it if the item
+// does not have it.
+func (u *Update) Append(name string, values ...any) *Update {
+	return u.listAppend(name, values, false)
+}
+
+// Prepend prepends the values to the list attribute, creating it if the
+// item does not have it.
+func (u *Update) Prepend(name string, values ...any) *Update {
+	return u.listAppend(name, values, true)
+}
+
+func (u *Update) listAppend(name string, values []any, prepend bool) *Update {
+	u.set = append(u.set, func(e *expression) string {
+		path := e.name(name)
+		list := &awstypes.AttributeValueMemberL{Value: make([]awstypes.AttributeValue, len(values))}
+		for i, v := range values {
+			av, err := attributevalue.Marshal(v)
+			if err != nil && e.err == nil {
+				e.err = err
+			}
+			list.Value[i] = av
+		}
+		existing := fmt.Sprintf("if_not_exists(%s, %s)", path, e.value(&awstypes.AttributeValueMemberL{}))
+		added := e.value(list)
+		if prepend {
+			return fmt.Sprintf("%s = list_append(%s, %s)", path, added, existing)
+		}
+		return fmt.Sprintf("%s = list_append(%s, %s)", path, existing, added)
+	})
+	return u
+}
+
+// Remove removes the attributes from the item.
+func (u *Update) Remove(names ...string) *Update {
+	for _, name := range names {
+		name := name
+		u.remove = append(u.remove, func(e *expression) string {
+			return e.name(name)
+		})
+	}
+	return u
+}
+
+// Add atomically adds the number to the number attribute, which is treated
+// as zero if the item does not have it.
+func (u *Update) Add(name string, number int64) *Update {
+	return u.addAction(name, &awstypes.AttributeValueMemberN{Value: strconv.FormatInt(number, 10)})
+}
+
+// AddToSet adds the elements of the set to the set attribute, creating it
+// if the item does not have it.
+func (u *Update) AddToSet(name string, set ValueSet) *Update {
+	return u.addAction(name, set.av)
+}
+
+// DeleteFromSet removes the elements of the set from the set attribute.
+func (u *Update) DeleteFromSet(name string, set ValueSet) *Update {
+	u.del = append(u.del, func(e *expression) string {
+		return e.name(name) + " " + e.value(set.av)
+	})
+	return u
+}
+
+func (u *Update) addAction(name string, value awstypes.AttributeValue) *Update {
+	u.add = append(u.add, func(e *expression) string {
+		return e.name(name) + " " + e.value(value)
+	})
+	return u
+}
+
+// If makes the update conditional: it is only applied if the condition
+// holds for the stored item.
+func (u *Update) If(cond Condition) *Update {
+	u.cond = And(u.cond, cond)
+	return u
+}
+
+// IsZero reports whether the update has no actions.
+func (u *Update) IsZero() bool {
+	return len(u.set)+len(u.remove)+len(u.add)+len(u.del) == 0
+}
+
+// Input returns the UpdateItemInput that applies the update to the item of
+// the row, addressed by its type-prefixed key in the row's table. It can be
+// returned from the DynamoUpdateInput method of a types.DynamoUpdater.
+func (u *Update) Input(ctx context.Context, row types.Linkable) (*dynamodb.UpdateItemInput, error) {
+	if u.IsZero() {
+		return nil, ErrEmptyUpdate{}
+	}
+	key, err := rowKey(row)
+	if err != nil {
+		return nil, err
+	}
+	e := newExpression("U")
+	var clauses []string
+	for _, clause := range []struct {
+		keyword string
+		actions []func(e *expression) string
+	}{{"SET", u.set}, {"REMOVE", u.remove}, {"ADD", u.add}, {"DELETE", u.del}} {
+		if len(clause.actions) == 0 {
+			continue
+		}
+		parts := make([]string, len(clause.actions))
+		for i, action := range clause.actions {
+			parts[i] = action(e)
+		}
+		clauses = append(clauses, clause.keyword+" "+strings.Join(parts, ", "))
+	}
+	in := &dynamodb.UpdateItemInput{
+		TableName:        aws.String(rowTableName(ctx, row, "")),
+		Key:              key,
+		UpdateExpression: aws.String(strings.Join(clauses, " ")),
+	}
+	if !u.cond.IsZero() {
+		in.ConditionExpression = aws.String(u.cond.build(e))
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	in.ExpressionAttributeNames = e.names
+	if len(e.values) > 0 {
+		in.ExpressionAttributeValues = e.values
+	}
+	return in, nil
+}
+
+// Update applies the update to the item of the row and unmarshals the
+// updated item into the row, as Get does: fields of attributes the update
+// removed keep their value, but RowData holds the item as it is now stored.
+// A conditional update whose condition does not hold returns
+// ErrConditionFailed. The UpdateItemOutput response is stored in
+// d.UpdateItemOutput.
+//
+//	err := user.Update(ctx, user, dynamo.NewUpdate().Increment("Logins", 1))
+func (d *DBManager) Update(ctx context.Context, row types.Linkable, update *Update) (err error) {
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+	d.UpdateItemOutput, err = d.updateWithClient(ctx, client, row, update)
+	return err
+}
+
+func (d *DBManager) updateWithClient(ctx context.Context, client *clients.Client, row types.Linkable, update *Update) (*dynamodb.UpdateItemOutput, error) {
+	if err := beforeUpdate(ctx, client, row); err != nil {
+		return nil, err
+	}
+	in, err := update.Input(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+	tn := d.TableName(ctx)
+	in.TableName = &tn
+	in.ReturnValues = awstypes.ReturnValueAllNew
+	in.ReturnValuesOnConditionCheckFailure = awstypes.ReturnValuesOnConditionCheckFailureAllOld
+	in.ReturnConsumedCapacity = consumedCapacity()
+	out, err := client.Dynamo().UpdateItem(ctx, in)
+	var ccf *awstypes.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return nil, ErrConditionFailed{Row: row, Current: ccf.Item}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := unmarshalItemInto(row, out.Attributes); err != nil {
+		return out, err
+	}
+	return out, afterUpdate(ctx, client, row)
+}
+
+// ValueSet is a string or number set for AddToSet and DeleteFromSet.
+type ValueSet struct {
+	av awstypes.AttributeValue
+}
+
+// StringSet returns a set of strings.
+func StringSet(values ...string) ValueSet {
+	return ValueSet{av: &awstypes.AttributeValueMemberSS{Value: values}}
+}
+
+// Number is the constraint of the element type of NumberSet.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// NumberSet returns a set of numbers.
+func NumberSet[T Number](values ...T) ValueSet {
+	numbers := make([]string, len(values))
+	for i, v := range values {
+		numbers[i] = fmt.Sprint(v)
+	}
+	return ValueSet{av: &awstypes.AttributeValueMemberNS{Value: numbers}}
+}
@@ -0,0 +1,114 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type Profile struct {
+	Row
+	ID       string
+	Nickname string `dynamodbav:",omitempty"`
+	Token    string `dynamodbav:",omitempty"`
+	Logins   int
+	History  []string `dynamodbav:",omitempty"`
+	Tags     []string `dynamodbav:",stringset,omitempty"`
+	Scores   []int    `dynamodbav:",numberset,omitempty"`
+}
+
+func (p *Profile) Type() string {
+	return "profile"
+}
+
+func (p *Profile) Keys(gsi int) (string, string, error) {
+	p.PartitionKey = p.ID
+	p.SortKey = "profile"
+	return p.PartitionKey, p.SortKey, nil
+}
+
+// rename updates the nickname of a profile through UpdateItem.
+type rename struct {
+	*Profile
+	to string
+}
+
+func (r rename) DynamoUpdateInput(ctx context.Context) (*dynamodb.UpdateItemInput, error) {
+	return NewUpdate().Set("Nickname", r.to).Input(ctx, r.Profile)
+}
+
+func TestUpdate(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	t.Run("renders placeholders and the type-prefixed key", func(t *testing.T) {
+		in, err := NewUpdate().
+			Set("Nickname", "jj").
+			Increment("Logins", 2).
+			Remove("Token").
+			AddToSet("Tags", StringSet("a")).
+			DeleteFromSet("Scores", NumberSet(1)).
+			If(AttributeExists("pk")).
+			Input(ctx, &Profile{ID: "p0"})
+		require.NoError(t, err)
+		assert.Equal(t, "SET #gbU0 = :gbU0, #gbU1 = if_not_exists(#gbU1, :gbU1) + :gbU2 REMOVE #gbU2 ADD #gbU3 :gbU3 DELETE #gbU4 :gbU4", *in.UpdateExpression)
+		assert.Equal(t, "attribute_exists(#gbU5)", *in.ConditionExpression)
+		assert.Equal(t, "Nickname", in.ExpressionAttributeNames["#gbU0"])
+		assert.Equal(t, &awstypes.AttributeValueMemberS{Value: "/rowType(profile)/rowPk(p0)"}, in.Key["pk"])
+	})
+	t.Run("applies every kind of action", func(t *testing.T) {
+		profile := &Profile{ID: "p1", Token: "secret", Tags: []string{"old"}, Scores: []int{1, 2}}
+		require.NoError(t, profile.Put(ctx, profile))
+
+		update := NewUpdate().
+			SetIfNotExists("Nickname", "first").
+			Increment("Logins", 3).
+			Append("History", "login").
+			Remove("Token").
+			AddToSet("Tags", StringSet("new")).
+			DeleteFromSet("Scores", NumberSet(1))
+		require.NoError(t, profile.Update(ctx, profile, update))
+		assert.Equal(t, "first", profile.Nickname)
+		assert.Equal(t, 3, profile.Logins)
+		assert.Equal(t, []string{"login"}, profile.History)
+		assert.NotContains(t, profile.RowData, "Token")
+		assert.ElementsMatch(t, []string{"old", "new"}, profile.Tags)
+		assert.Equal(t, []int{2}, profile.Scores)
+
+		update = NewUpdate().
+			SetIfNotExists("Nickname", "second").
+			Decrement("Logins", 1).
+			Prepend("History", "signup")
+		require.NoError(t, profile.Update(ctx, profile, update))
+		assert.Equal(t, "first", profile.Nickname)
+		assert.Equal(t, 2, profile.Logins)
+		assert.Equal(t, []string{"signup", "login"}, profile.History)
+		assert.NotNil(t, profile.UpdateItemOutput)
+		assert.NotNil(t, profile.RowData)
+	})
+	t.Run("conditions guard the update", func(t *testing.T) {
+		profile := &Profile{ID: "p1"}
+		err := profile.Update(ctx, profile, NewUpdate().Add("Logins", 1).If(Equal("Nickname", "other")))
+		var failed ErrConditionFailed
+		require.True(t, errors.As(err, &failed))
+		assert.NotNil(t, failed.Current)
+	})
+	t.Run("an empty update is rejected", func(t *testing.T) {
+		profile := &Profile{ID: "p1"}
+		assert.ErrorIs(t, profile.Update(ctx, profile, NewUpdate()), ErrEmptyUpdate{})
+	})
+	t.Run("builds the input of a DynamoUpdater", func(t *testing.T) {
+		_, err := UpdateItem(ctx, rename{Profile: &Profile{ID: "p1"}, to: "renamed"})
+		require.NoError(t, err)
+		loaded := &Profile{ID: "p1"}
+		_, err = loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, "renamed", loaded.Nickname)
+	})
+}
@@ -0,0 +1,123 @@
+package dynamo
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/entegral/gobox/types"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// versionAttribute is the attribute holding the version of Versionable rows.
+const versionAttribute = "version"
+
+// Version adds optimistic locking to a row. Embed it next to Row to make the
+// row implement types.Versionable:
+//
+//	type User struct {
+//		dynamo.Row
+//		dynamo.Version
+//		Email string
+//	}
+//
+// Put then fails with ErrVersionConflict if the row was changed since it was
+// loaded, instead of silently overwriting the other write.
+type Version struct {
+	RowVersion int64 `dynamodbav:"version" json:"version,omitempty"`
+}
+
+// GetVersion returns the version of the row.
+func (v *Version) GetVersion() int64 {
+	return v.RowVersion
+}
+
+// SetVersion sets the version of the row.
+func (v *Version) SetVersion(version int64) {
+	v.RowVersion = version
+}
+
+// ErrVersionConflict is returned when a Versionable row is put but the
+// version stored in DynamoDB is not the version that was loaded. Current is
+// the stored item, or nil if the item no longer exists.
+type ErrVersionConflict struct {
+	Row      types.Linkable
+	Expected int64
+	Current  map[string]awstypes.AttributeValue
+}
+
+// CurrentVersion returns the version of the stored item, or 0 if it does not
+// exist or has no version.
+func (e ErrVersionConflict) CurrentVersion() int64 {
+	return itemVersion(e.Current)
+}
+
+func (e ErrVersionConflict) Error() string {
+	if e.Current == nil {
+		return fmt.Sprintf("version conflict on %s: expected version %d, but the item does not exist", e.Row.Type(), e.Expected)
+	}
+	return fmt.Sprintf("version conflict on %s: expected version %d, found %d", e.Row.Type(), e.Expected, e.CurrentVersion())
+}
+
+// versionedPut is a put of a Versionable row. The row's version is only
+// incremented once the put succeeds.
+type versionedPut struct {
+	row      types.Linkable
+	expected int64
+	item     map[string]awstypes.AttributeValue
+}
+
+// newVersionedPut sets the incremented version in item, the marshalled row.
+// It returns false if the row is not Versionable.
+//
+// The expected version is the one in the row's RowData, which holds the item
+// as it was loaded. Rows without RowData fall back to their own version.
+// Version 0 means the item is new or was written before it was versioned.
+func newVersionedPut(row types.Linkable, item map[string]awstypes.AttributeValue) (*versionedPut, bool) {
+	v, ok := row.(types.Versionable)
+	if !ok {
+		return nil, false
+	}
+	p := &versionedPut{row: row, expected: v.GetVersion(), item: item}
+	if loaded := getRowData(row); loaded != nil {
+		p.expected = itemVersion(loaded)
+	}
+	item[versionAttribute] = &awstypes.AttributeValueMemberN{Value: strconv.FormatInt(p.expected+1, 10)}
+	return p, true
+}
+
+// condition returns the condition that enforces the expected version.
+func (p *versionedPut) condition() Condition {
+	if p.expected == 0 {
+		return AttributeNotExists(versionAttribute)
+	}
+	return Equal(versionAttribute, p.expected)
+}
+
+// mismatch reports whether the stored item has a version other than the
+// expected one.
+func (p *versionedPut) mismatch(current map[string]awstypes.AttributeValue) bool {
+	return itemVersion(current) != p.expected
+}
+
+// conflict returns the error for a failed version condition.
+func (p *versionedPut) conflict(current map[string]awstypes.AttributeValue) error {
+	return ErrVersionConflict{Row: p.row, Expected: p.expected, Current: current}
+}
+
+// commit records the written item on the row once the put succeeded, so the
+// next put expects the new version.
+func (p *versionedPut) commit() {
+	p.row.(types.Versionable).SetVersion(p.expected + 1)
+	setRowData(p.row, p.item)
+}
+
+// itemVersion returns the version attribute of an item, or 0 if it has none.
+func itemVersion(item map[string]awstypes.AttributeValue) int64 {
+	n, ok := item[versionAttribute].(*awstypes.AttributeValueMemberN)
+	if !ok {
+		return 0
+	}
+	version, _ := strconv.ParseInt(n.Value, 10, 64)
+	return version
+}
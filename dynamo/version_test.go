@@ -0,0 +1,103 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Account struct {
+	Row
+	Version
+	ID      string
+	Balance int
+}
+
+func (a *Account) Type() string {
+	return "account"
+}
+
+func (a *Account) Keys(gsi int) (string, string, error) {
+	a.PartitionKey = a.ID
+	a.SortKey = "account"
+	return a.PartitionKey, a.SortKey, nil
+}
+
+func TestVersion(t *testing.T) {
+	useMemDB(t)
+	ctx := context.Background()
+
+	t.Run("put increments the version", func(t *testing.T) {
+		account := &Account{ID: "a1", Balance: 10}
+		require.NoError(t, account.Put(ctx, account))
+		assert.Equal(t, int64(1), account.GetVersion())
+		account.Balance = 20
+		require.NoError(t, account.Put(ctx, account))
+		assert.Equal(t, int64(2), account.GetVersion())
+
+		loaded := &Account{ID: "a1"}
+		_, err := loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), loaded.GetVersion())
+		assert.Equal(t, 20, loaded.Balance)
+	})
+	t.Run("the last writer gets a conflict", func(t *testing.T) {
+		first := &Account{ID: "a1"}
+		_, err := first.Get(ctx, first)
+		require.NoError(t, err)
+		second := &Account{ID: "a1"}
+		_, err = second.Get(ctx, second)
+		require.NoError(t, err)
+
+		first.Balance = 30
+		require.NoError(t, first.Put(ctx, first))
+
+		second.Balance = 40
+		err = second.Put(ctx, second)
+		var conflict ErrVersionConflict
+		require.True(t, errors.As(err, &conflict))
+		assert.Equal(t, int64(2), conflict.Expected)
+		assert.Equal(t, int64(3), conflict.CurrentVersion())
+		assert.NotNil(t, conflict.Current)
+		assert.Equal(t, int64(2), second.GetVersion())
+
+		loaded := &Account{ID: "a1"}
+		_, err = loaded.Get(ctx, loaded)
+		require.NoError(t, err)
+		assert.Equal(t, 30, loaded.Balance)
+	})
+	t.Run("a new row does not overwrite a versioned item", func(t *testing.T) {
+		fresh := &Account{ID: "a1", Balance: 50}
+		err := fresh.Put(ctx, fresh)
+		assert.True(t, errors.As(err, new(ErrVersionConflict)))
+	})
+	t.Run("the RowData version is expected over the row's own", func(t *testing.T) {
+		account := &Account{ID: "a1"}
+		_, err := account.Get(ctx, account)
+		require.NoError(t, err)
+		account.SetVersion(99)
+		require.NoError(t, account.Put(ctx, account))
+		assert.Equal(t, int64(4), account.GetVersion())
+	})
+	t.Run("transactions enforce the version", func(t *testing.T) {
+		stale := &Account{ID: "a1"}
+		_, err := stale.Get(ctx, stale)
+		require.NoError(t, err)
+		current := &Account{ID: "a1"}
+		_, err = current.Get(ctx, current)
+		require.NoError(t, err)
+
+		_, err = NewTransaction().Put(current).Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), current.GetVersion())
+
+		_, err = NewTransaction().Put(stale).Exec(ctx)
+		var conflict ErrVersionConflict
+		require.True(t, errors.As(err, &conflict))
+		assert.Equal(t, int64(5), conflict.CurrentVersion())
+		assert.Equal(t, int64(4), stale.GetVersion())
+	})
+}
@@ -0,0 +1,131 @@
+package keytags
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrKeyMismatch is returned when a key does not have the format the tags
+// of a struct declare.
+type ErrKeyMismatch struct {
+	Key    string
+	Reason string
+}
+
+func (e ErrKeyMismatch) Error() string {
+	return fmt.Sprintf("key %q does not match its tags: %s", e.Key, e.Reason)
+}
+
+// Decode parses key, the key named keyName (such as "pk" or "sk1") as
+// derived from the tags of the struct v points to, and sets the fields that
+// make it up. Field values are converted from their key form: integers,
+// floats and bools are parsed, and times are parsed with the layout of
+// their tag. Case folded values are set as they appear in the key, and
+// hashed values cannot be recovered, so their fields are left unchanged.
+//
+// The key is split with the delimiter of v, so it must not occur in any of
+// the values.
+func Decode(keyName, key string, v any) error {
+	val, spec, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields := spec.keys[keyName]
+	if len(fields) == 0 {
+		return ErrNoKeyTags{Type: spec.name, Key: keyName}
+	}
+	parts := strings.Split(key, delimiterOf(v))
+	if len(parts) != len(fields) {
+		return ErrKeyMismatch{Key: key, Reason: fmt.Sprintf("%d parts, %s declares %d", len(parts), keyName, len(fields))}
+	}
+	for i, f := range fields {
+		part := parts[i]
+		if !strings.HasPrefix(part, f.format.prefix) || !strings.HasSuffix(part[len(f.format.prefix):], f.format.suffix) {
+			return ErrKeyMismatch{Key: key, Reason: fmt.Sprintf("part %q of %s is not %q...%q", part, f.name, f.format.prefix, f.format.suffix)}
+		}
+		if f.format.hash > 0 {
+			continue
+		}
+		raw := part[len(f.format.prefix) : len(part)-len(f.format.suffix)]
+		if err := f.format.parse(raw, val.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("%s of %s.%s: %w", keyName, spec.name, f.name, err)
+		}
+	}
+	return nil
+}
+
+// DecodeAs returns a T, a struct type with key tags, with the fields that
+// make up key set as by Decode.
+func DecodeAs[T any](keyName, key string) (T, error) {
+	var v T
+	err := Decode(keyName, key, &v)
+	return v, err
+}
+
+// parse sets the field to the value of s, a part formatted by format.
+func (f fieldFormat) parse(s string, field reflect.Value) error {
+	if isTime(field.Type()) {
+		t, err := f.parseTime(s)
+		if err != nil {
+			return err
+		}
+		if field.Type() != timeType {
+			field = field.FieldByName("Time")
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch k := field.Kind(); {
+	case k == reflect.String:
+		field.SetString(s)
+	case isSigned(k):
+		n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case isInteger(k):
+		n, err := strconv.ParseUint(s, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case k == reflect.Float32 || k == reflect.Float64:
+		n, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case k == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("cannot decode into a %s", field.Type())
+	}
+	return nil
+}
+
+func (f fieldFormat) parseTime(s string) (time.Time, error) {
+	switch f.unit {
+	case "unix", "unixmilli":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if f.unit == "unix" {
+			return time.Unix(n, 0).UTC(), nil
+		}
+		return time.UnixMilli(n).UTC(), nil
+	}
+	layout := f.layout
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return time.Parse(layout, s)
+}
@@ -0,0 +1,178 @@
+package keytags
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the named layouts of the time operation. Any other value
+// is used as a time.Format layout. Keys sort correctly only with layouts of
+// a fixed width, which is why the nanosecond layout keeps trailing zeros.
+var timeLayouts = map[string]string{
+	"rfc3339":      time.RFC3339,
+	"rfc3339milli": "2006-01-02T15:04:05.000Z07:00",
+	"rfc3339nano":  "2006-01-02T15:04:05.000000000Z07:00",
+	"date":         time.DateOnly,
+	"datetime":     time.DateTime,
+}
+
+// The widths unix timestamps are padded to, enough for dates up to 2286.
+const (
+	unixWidth      = 10
+	unixMilliWidth = 13
+)
+
+// defaultHashLength is the number of hex digits kept by a hash operation
+// without a length.
+const defaultHashLength = 8
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fieldFormat holds the operations of a key tag, applied in this order:
+// the value is formatted (with time or pad), case folded (with lower or
+// upper), hashed (with hash), and prefixed and suffixed (with prepend and
+// append).
+type fieldFormat struct {
+	prefix, suffix string
+	pad            int
+	layout         string
+	unit           string
+	fold           func(string) string
+	hash           int
+}
+
+// parseFormat parses the operations of a key tag for a field of type t.
+func parseFormat(t reflect.Type, operations []string) (fieldFormat, error) {
+	var f fieldFormat
+	for _, op := range operations {
+		name, value, hasValue := strings.Cut(op, "=")
+		switch name {
+		case "prepend", "append":
+			if !hasValue {
+				return f, fmt.Errorf("missing operation or value")
+			}
+			if name == "prepend" {
+				f.prefix = value
+			} else {
+				f.suffix = value
+			}
+		case "pad":
+			width, err := strconv.Atoi(value)
+			if err != nil || width <= 0 {
+				return f, fmt.Errorf("invalid pad width %q", value)
+			}
+			if !isInteger(t.Kind()) {
+				return f, fmt.Errorf("pad needs an integer field, not %s", t)
+			}
+			f.pad = width
+		case "time":
+			if !isTime(t) {
+				return f, fmt.Errorf("time needs a time.Time or types.DateTime field, not %s", t)
+			}
+			switch value {
+			case "unix", "unixmilli":
+				f.unit = value
+			case "":
+				return f, fmt.Errorf("missing time layout")
+			default:
+				f.layout = value
+				if layout, ok := timeLayouts[value]; ok {
+					f.layout = layout
+				}
+			}
+		case "lower", "upper":
+			if hasValue {
+				return f, fmt.Errorf("%s takes no value", name)
+			}
+			f.fold = strings.ToLower
+			if name == "upper" {
+				f.fold = strings.ToUpper
+			}
+		case "hash":
+			f.hash = defaultHashLength
+			if hasValue {
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 || n > 2*sha256.Size {
+					return f, fmt.Errorf("invalid hash length %q", value)
+				}
+				f.hash = n
+			}
+		default:
+			return f, fmt.Errorf("unknown operation %q", name)
+		}
+	}
+	return f, nil
+}
+
+// format returns the key part for the field value v.
+func (f fieldFormat) format(v reflect.Value) (string, error) {
+	var s string
+	switch {
+	case isTime(v.Type()):
+		t := timeOf(v).UTC()
+		switch f.unit {
+		case "unix":
+			s = fmt.Sprintf("%0*d", unixWidth, t.Unix())
+		case "unixmilli":
+			s = fmt.Sprintf("%0*d", unixMilliWidth, t.UnixMilli())
+		default:
+			layout := f.layout
+			if layout == "" {
+				layout = time.RFC3339
+			}
+			s = t.Format(layout)
+		}
+	case f.pad > 0:
+		if isSigned(v.Kind()) && v.Int() < 0 {
+			return "", fmt.Errorf("cannot pad negative value %d", v.Int())
+		}
+		s = fmt.Sprintf("%0*d", f.pad, v.Interface())
+		if len(s) > f.pad {
+			return "", fmt.Errorf("value %s is wider than %d digits", s, f.pad)
+		}
+	default:
+		s = fmt.Sprint(v)
+	}
+	if f.fold != nil {
+		s = f.fold(s)
+	}
+	if f.hash > 0 {
+		sum := sha256.Sum256([]byte(s))
+		s = hex.EncodeToString(sum[:])[:f.hash]
+	}
+	return f.prefix + s + f.suffix, nil
+}
+
+// isTime reports whether t is time.Time or a struct embedding it, like
+// types.DateTime.
+func isTime(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	f, ok := t.FieldByName("Time")
+	return ok && f.Anonymous && f.Type == timeType
+}
+
+// timeOf returns the time held by v, a value of a type isTime accepts.
+func timeOf(v reflect.Value) time.Time {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time)
+	}
+	return v.FieldByName("Time").Interface().(time.Time)
+}
+
+func isInteger(k reflect.Kind) bool {
+	return isSigned(k) || k >= reflect.Uint && k <= reflect.Uint64
+}
+
+func isSigned(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
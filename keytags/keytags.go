@@ -1,98 +1,329 @@
+// Package keytags derives the primary and GSI keys of a struct from struct
+// tags. Each field taking part in a key is tagged with the key's name, the
+// position of the field in the key and an operation:
+//
+//	type User struct {
+//		ID    string `pk:"1,prepend=USER:" pk1:"1,prepend=USER:"`
+//		Email string `pk:"2,prepend=EMAIL:"`
+//		Kind  string `sk:"1,prepend=TYPE:"`
+//	}
+//
+// The keys are pk and sk for the primary key and pk1/sk1 through pk6/sk6
+// for the GSIs. The parts of a key are joined with a delimiter in order.
+// Tags are parsed once per type.
+//
+// After the order, a tag lists any of these operations, separated by commas:
+//
+//	prepend=X    prefix the part with X
+//	append=X     suffix the part with X
+//	pad=N        zero-pad an integer to N digits, so numbers sort as strings
+//	time=LAYOUT  format a time.Time or types.DateTime in UTC with a layout:
+//	             rfc3339 (the default), rfc3339milli, rfc3339nano, date,
+//	             datetime, unix, unixmilli or a time.Format layout
+//	lower        lowercase the part
+//	upper        uppercase the part
+//	hash[=N]     replace the part with the first N (default 8) hex digits of
+//	             its SHA-256, prefix and suffix excluded
+//
+// For example, `sk:"2,prepend=AT:,time=rfc3339milli"` or
+// `sk1:"1,pad=10"`. Without operations, the part is the field formatted
+// with fmt.Sprint. Decode reverses the derivation, setting the fields that
+// make up a key.
 package keytags
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/entegral/gobox/keys"
 )
 
+// DefaultDelimiter joins the parts of keys derived by Keys and Apply, unless
+// the struct implements Delimited.
+const DefaultDelimiter = "#"
+
+// Delimited is implemented by structs that join their key parts with a
+// delimiter other than DefaultDelimiter.
+type Delimited interface {
+	KeyDelimiter() string
+}
+
+// keyNames are the tag names of the keys, in index order: pk and sk are
+// index 0, pk1 and sk1 index 1, and so on.
+var keyNames = []string{
+	keys.PkKey, keys.SkKey,
+	keys.Pk1Key, keys.Sk1Key,
+	keys.Pk2Key, keys.Sk2Key,
+	keys.Pk3Key, keys.Sk3Key,
+	keys.Pk4Key, keys.Sk4Key,
+	keys.Pk5Key, keys.Sk5Key,
+	keys.Pk6Key, keys.Sk6Key,
+}
+
+// ErrZeroKeyField is returned when a field that is part of a partition key
+// has its zero value.
+type ErrZeroKeyField struct {
+	Type  string
+	Field string
+	Key   string
+}
+
+func (e ErrZeroKeyField) Error() string {
+	return fmt.Sprintf("zero or empty value for field %s.%s used in %s", e.Type, e.Field, e.Key)
+}
+
+// ErrNoKeyTags is returned when a struct does not declare the requested key.
+type ErrNoKeyTags struct {
+	Type string
+	Key  string
+}
+
+func (e ErrNoKeyTags) Error() string {
+	return fmt.Sprintf("%s declares no %s tags", e.Type, e.Key)
+}
+
 // Define a struct to hold parts of the key along with their order.
 type keyPart struct {
 	Value string
 	Order int
 }
 
-func generateCompositeKeys(v interface{}, delimiter string) (map[string]string, error) {
-	val := reflect.ValueOf(v).Elem()
-	typeName := val.Type().Name() // Get the type name of the struct.
+// fieldSpec is a field taking part in a key, as declared by its tag.
+type fieldSpec struct {
+	index  []int
+	name   string
+	order  int
+	format fieldFormat
+}
 
-	keys := make(map[string][]keyPart)
-	orderUsed := make(map[string]map[int]bool) // New: Track orders used for each key type.
+// typeSpec holds the parsed key tags of a struct type.
+type typeSpec struct {
+	name string
+	keys map[string][]fieldSpec
+	err  error
+}
 
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Type().Field(i)
-		fieldValue := val.Field(i) // Ensure you're working with the field's value.
+// specs caches the typeSpec of each struct type.
+var specs sync.Map
 
-		if fieldValue.Kind() == reflect.Slice || fieldValue.Kind() == reflect.Array {
-			return nil, fmt.Errorf("slice or array field %s.%s cannot be used for key generation", typeName, field.Name)
+// specFor returns the parsed key tags of the struct type t.
+func specFor(t reflect.Type) *typeSpec {
+	if spec, ok := specs.Load(t); ok {
+		return spec.(*typeSpec)
+	}
+	spec := &typeSpec{name: t.Name(), keys: map[string][]fieldSpec{}}
+	spec.err = spec.parse(t, nil, map[string]map[int]bool{})
+	for _, fields := range spec.keys {
+		sort.Slice(fields, func(i, j int) bool { return fields[i].order < fields[j].order })
+	}
+	actual, _ := specs.LoadOrStore(t, spec)
+	return actual.(*typeSpec)
+}
+
+// parse adds the tagged fields of t to the spec. Untagged embedded structs
+// are searched for tagged fields too. orderUsed tracks the orders used for
+// each key.
+func (s *typeSpec) parse(t reflect.Type, index []int, orderUsed map[string]map[int]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		tagged := false
+		for _, keyName := range keyNames {
+			if _, ok := field.Tag.Lookup(keyName); ok {
+				tagged = true
+			}
+		}
+		if !tagged {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if err := s.parse(field.Type, fieldIndex, orderUsed); err != nil {
+					return err
+				}
+			}
+			continue
 		}
 
-		if !fieldValue.IsValid() || fieldValue.Kind() == reflect.Struct || fieldValue.Kind() == reflect.Map || fieldValue.Kind() == reflect.Func || fieldValue.Kind() == reflect.Chan {
-			return nil, fmt.Errorf("non-primitive field %s.%s cannot be used for key generation", typeName, field.Name)
+		switch field.Type.Kind() {
+		case reflect.Slice, reflect.Array:
+			return fmt.Errorf("slice or array field %s.%s cannot be used for key generation", s.name, field.Name)
+		case reflect.Struct, reflect.Map, reflect.Func, reflect.Chan, reflect.Pointer:
+			if !isTime(field.Type) {
+				return fmt.Errorf("non-primitive field %s.%s cannot be used for key generation", s.name, field.Name)
+			}
 		}
 
-		for _, keyName := range []string{"pk", "sk", "pk1", "sk1", "pk2", "sk2", "pk3", "sk3", "pk4", "sk4", "pk5", "sk5", "pk6", "sk6"} {
+		for _, keyName := range keyNames {
 			tag, ok := field.Tag.Lookup(keyName)
 			if !ok {
 				continue // Skip if the tag is not present for the keyName.
 			}
 
 			parts := strings.Split(tag, ",")
-			if len(parts) < 2 {
-				continue // Ensure at least the order and one operation are specified.
-			}
-
 			order, err := strconv.Atoi(parts[0])
 			if err != nil {
-				return nil, fmt.Errorf("invalid order in %s tag for %s.%s: %v", keyName, typeName, field.Name, err)
+				return fmt.Errorf("invalid order in %s tag for %s.%s: %v", keyName, s.name, field.Name, err)
 			}
 
-			// New: Check for duplicate orders within the same key type.
+			// Check for duplicate orders within the same key type.
 			if _, exists := orderUsed[keyName]; !exists {
 				orderUsed[keyName] = make(map[int]bool)
 			}
 			if orderUsed[keyName][order] {
-				return nil, fmt.Errorf("duplicate key ordering specified for %s in %s, both specify %d term", keyName, typeName, order)
+				return fmt.Errorf("duplicate key ordering specified for %s in %s, both specify %d term", keyName, s.name, order)
 			}
 			orderUsed[keyName][order] = true
 
-			operationAndValue := strings.SplitN(parts[1], "=", 2) // Split operation from value.
-			if len(operationAndValue) != 2 {
-				return nil, fmt.Errorf("malformed tag for %s.%s: missing operation or value", typeName, field.Name)
+			format, err := parseFormat(field.Type, parts[1:])
+			if err != nil {
+				return fmt.Errorf("malformed %s tag for %s.%s: %v", keyName, s.name, field.Name, err)
 			}
 
-			operation := operationAndValue[0]
-			value := operationAndValue[1]
+			s.keys[keyName] = append(s.keys[keyName], fieldSpec{
+				index:  fieldIndex,
+				name:   field.Name,
+				order:  order,
+				format: format,
+			})
+		}
+	}
+	return nil
+}
 
-			// Corrected zero or empty value check
-			if (keyName == "pk" || strings.HasPrefix(keyName, "pk")) && (fieldValue.Kind() == reflect.String && strings.TrimSpace(fieldValue.String()) == "" || !fieldValue.IsValid() || reflect.DeepEqual(fieldValue.Interface(), reflect.Zero(fieldValue.Type()).Interface())) {
-				return nil, fmt.Errorf("zero or empty value for field %s.%s used in %s", typeName, field.Name, keyName)
-			}
+// compose builds the key from the fields of val, the struct value.
+func (s *typeSpec) compose(val reflect.Value, keyName, delimiter string) (string, error) {
+	var parts []keyPart
+	for _, f := range s.keys[keyName] {
+		fieldValue := val.FieldByIndex(f.index)
 
-			var keyValue string
-			if operation == "prepend" {
-				keyValue = value + fmt.Sprint(fieldValue)
-			} else if operation == "append" {
-				keyValue = fmt.Sprint(fieldValue) + value
-			} else {
-				keyValue = fmt.Sprint(fieldValue) // Just use the field value if no valid operation is specified.
-			}
+		// Partition keys cannot have empty parts.
+		if strings.HasPrefix(keyName, "pk") && (fieldValue.Kind() == reflect.String && strings.TrimSpace(fieldValue.String()) == "" || fieldValue.IsZero()) {
+			return "", ErrZeroKeyField{Type: s.name, Field: f.name, Key: keyName}
+		}
 
-			keys[keyName] = append(keys[keyName], keyPart{Value: keyValue, Order: order})
+		keyValue, err := f.format.format(fieldValue)
+		if err != nil {
+			return "", fmt.Errorf("%s of %s.%s: %w", keyName, s.name, f.name, err)
 		}
+		parts = append(parts, keyPart{Value: keyValue, Order: f.order})
 	}
+	return concatenateKeyParts(parts, delimiter), nil
+}
 
-	finalKeys := make(map[string]string)
-	for keyType, parts := range keys {
-		sort.Slice(parts, func(i, j int) bool { return parts[i].Order < parts[j].Order })
-		finalKeys[keyType] = concatenateKeyParts(parts, delimiter)
+// structValue returns the struct v points to, along with its spec.
+func structValue(v any) (reflect.Value, *typeSpec, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Pointer || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("keytags: %T is not a pointer to a struct", v)
 	}
+	val = val.Elem()
+	spec := specFor(val.Type())
+	return val, spec, spec.err
+}
 
+// GenerateCompositeKeys returns every key declared by the tags of the struct
+// v points to, joined with the delimiter, by key name.
+func GenerateCompositeKeys(v any, delimiter string) (map[string]string, error) {
+	val, spec, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	finalKeys := make(map[string]string)
+	for keyName := range spec.keys {
+		if finalKeys[keyName], err = spec.compose(val, keyName, delimiter); err != nil {
+			return nil, err
+		}
+	}
 	return finalKeys, nil
 }
 
+// Declares reports whether the struct v points to declares a key with tags.
+func Declares(v any) bool {
+	_, spec, err := structValue(v)
+	return err == nil && len(spec.keys) > 0
+}
+
+// Keys returns the partition and sort key of index gsi, 0 being the primary
+// key, declared by the tags of the struct v points to. A key without tags
+// is empty, but the partition key of the primary key must be declared.
+func Keys(v any, gsi int) (pk, sk string, err error) {
+	if gsi < 0 || 2*gsi+1 >= len(keyNames) {
+		return "", "", fmt.Errorf("keytags: invalid index %d", gsi)
+	}
+	val, spec, err := structValue(v)
+	if err != nil {
+		return "", "", err
+	}
+	pkName, skName := keyNames[2*gsi], keyNames[2*gsi+1]
+	if len(spec.keys[pkName]) == 0 && gsi == 0 {
+		return "", "", ErrNoKeyTags{Type: spec.name, Key: pkName}
+	}
+	delimiter := delimiterOf(v)
+	if pk, err = spec.compose(val, pkName, delimiter); err != nil {
+		return "", "", err
+	}
+	sk, err = spec.compose(val, skName, delimiter)
+	return pk, sk, err
+}
+
+// Apply derives the keys declared by the tags of the struct v points to and
+// stores them in its keys.Key and keys.GSI fields, usually embedded through
+// a row type. GSIs whose partition key has an empty part, or that declare
+// no partition key, are left unset, so the item stays out of sparse indexes.
+func Apply(v any) error {
+	val, spec, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	pk, sk, err := Keys(v, 0)
+	if err != nil {
+		return err
+	}
+	setString(val, "PartitionKey", pk)
+	setString(val, "SortKey", sk)
+	for gsi := 1; 2*gsi < len(keyNames); gsi++ {
+		pkName, skName := keyNames[2*gsi], keyNames[2*gsi+1]
+		if len(spec.keys[pkName]) == 0 && len(spec.keys[skName]) == 0 {
+			continue
+		}
+		pk, sk, err := Keys(v, gsi)
+		if errors.As(err, new(ErrZeroKeyField)) || err == nil && pk == "" {
+			setStringPointer(val, strings.ToUpper(pkName[:1])+pkName[1:], nil)
+			setStringPointer(val, strings.ToUpper(skName[:1])+skName[1:], nil)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		setStringPointer(val, strings.ToUpper(pkName[:1])+pkName[1:], &pk)
+		setStringPointer(val, strings.ToUpper(skName[:1])+skName[1:], &sk)
+	}
+	return nil
+}
+
+func delimiterOf(v any) string {
+	if d, ok := v.(Delimited); ok {
+		return d.KeyDelimiter()
+	}
+	return DefaultDelimiter
+}
+
+func setString(val reflect.Value, name, s string) {
+	if f := val.FieldByName(name); f.IsValid() && f.CanSet() && f.Kind() == reflect.String {
+		f.SetString(s)
+	}
+}
+
+func setStringPointer(val reflect.Value, name string, s *string) {
+	if f := val.FieldByName(name); f.IsValid() && f.CanSet() && f.Type() == reflect.TypeOf(s) {
+		f.Set(reflect.ValueOf(s))
+	}
+}
+
 func concatenateKeyParts(parts []keyPart, delimiter string) string {
 	var result []string
 	for _, part := range parts {
@@ -2,10 +2,12 @@ package keytags
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/entegral/gobox/keys"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,7 +32,7 @@ func TestGenerateCompositeKeys(t *testing.T) {
 		}
 
 		// Generate the composite keys
-		keyMap, err := generateCompositeKeys(&u, "/")
+		keyMap, err := GenerateCompositeKeys(&u, "/")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -62,7 +64,7 @@ func TestGenerateCompositeKeys(t *testing.T) {
 		}
 
 		// Generate the composite keys
-		keyMap, err := generateCompositeKeys(&u, "/")
+		keyMap, err := GenerateCompositeKeys(&u, "/")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -86,7 +88,7 @@ func TestGenerateCompositeKeysBasic(t *testing.T) {
 	user := User{ID: "123", Email: "test@example.com"}
 	expected := map[string]string{"pk": "USER#123/test@example.com#EMAIL"}
 
-	keys, err := generateCompositeKeys(&user, "/")
+	keys, err := GenerateCompositeKeys(&user, "/")
 	if err != nil {
 		t.Fatalf("Failed to generate keys: %v", err)
 	}
@@ -104,7 +106,7 @@ func TestGenerateCompositeKeysEmptyField(t *testing.T) {
 
 	user := User{ID: "", Email: "test@example.com"} // ID is empty
 
-	_, err := generateCompositeKeys(&user, "#")
+	_, err := GenerateCompositeKeys(&user, "#")
 	if err == nil {
 		t.Error("Expected an error for empty ID field, but got nil")
 	}
@@ -120,7 +122,7 @@ func TestGenerateCompositeKeysMixedTypes(t *testing.T) {
 
 	expected := map[string]string{"pk": "ORDER#456#JohnDoe#CUSTOMER"}
 
-	keys, err := generateCompositeKeys(&order, "#")
+	keys, err := GenerateCompositeKeys(&order, "#")
 	if err != nil {
 		t.Fatalf("Failed to generate keys: %v", err)
 	}
@@ -142,7 +144,7 @@ func TestGenerateCompositeKeysMultipleKeyTypes(t *testing.T) {
 		"sk": "SKU:789-XYZ123:STOCK",
 	}
 
-	keys, err := generateCompositeKeys(&product, "-")
+	keys, err := GenerateCompositeKeys(&product, "-")
 	if err != nil {
 		t.Fatalf("Failed to generate keys: %v", err)
 	}
@@ -161,7 +163,7 @@ func TestGenerateCompositeKeysZeroValuePrimaryKey(t *testing.T) {
 	// ID has a zero value.
 	product := Product{ID: 0, Name: "TestProduct"}
 
-	_, err := generateCompositeKeys(&product, ":")
+	_, err := GenerateCompositeKeys(&product, ":")
 	if err == nil {
 		t.Error("Expected an error for zero value in primary key field, but got nil")
 	} else {
@@ -180,7 +182,7 @@ func TestGenerateCompositeKeysNonPrimitiveField(t *testing.T) {
 
 	user := User{Name: "JohnDoe", Address: Address{City: "Springfield"}}
 
-	_, err := generateCompositeKeys(&user, ":")
+	_, err := GenerateCompositeKeys(&user, ":")
 	if err == nil {
 		t.Error("Expected an error for non-primitive type field used for key generation, but got nil")
 	} else {
@@ -196,7 +198,7 @@ func TestGenerateCompositeKeysSliceField(t *testing.T) {
 
 	inventory := Inventory{ProductID: "12345", Items: []string{"Item1", "Item2"}}
 
-	_, err := generateCompositeKeys(&inventory, ":")
+	_, err := GenerateCompositeKeys(&inventory, ":")
 	if err == nil {
 		t.Error("Expected an error for slice field used for key generation, but got nil")
 	} else {
@@ -212,7 +214,7 @@ func TestGenerateCompositeKeysDuplicateOrder(t *testing.T) {
 
 	item := InventoryItem{ID: "123", Code: "XYZ"}
 
-	_, err := generateCompositeKeys(&item, ":")
+	_, err := GenerateCompositeKeys(&item, ":")
 	if err == nil {
 		t.Fatal("Expected an error due to duplicate order in pk tags, but got nil")
 	}
@@ -222,3 +224,192 @@ func TestGenerateCompositeKeysDuplicateOrder(t *testing.T) {
 		t.Errorf("Expected error message to contain '%s', got: %v", expectedErrorMsg, err)
 	}
 }
+
+type keyed struct {
+	keys.Key
+	keys.GSI
+	Tenant string `pk:"1,prepend=TENANT:" pk1:"1,prepend=TENANT:"`
+	ID     string `sk:"1,prepend=" sk1:"2,prepend=ID:"`
+	Region string `pk1:"2,prepend=REGION:"`
+}
+
+type slashed struct {
+	keyed
+}
+
+func (s *slashed) KeyDelimiter() string {
+	return "/"
+}
+
+func TestKeys(t *testing.T) {
+	k := &keyed{Tenant: "acme", ID: "1", Region: "eu"}
+	pk, sk, err := Keys(k, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "TENANT:acme", pk)
+	assert.Equal(t, "1", sk)
+
+	pk, sk, err = Keys(k, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "TENANT:acme#REGION:eu", pk)
+	assert.Equal(t, "ID:1", sk)
+
+	pk, sk, err = Keys(&slashed{keyed: *k}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "TENANT:acme/REGION:eu", pk, "tags of embedded structs are used, joined with the row's delimiter")
+	assert.Equal(t, "ID:1", sk)
+
+	_, _, err = Keys(k, 7)
+	assert.Error(t, err)
+	_, _, err = Keys(&struct{ Name string }{"x"}, 0)
+	assert.ErrorAs(t, err, new(ErrNoKeyTags))
+	assert.False(t, Declares(&struct{ Name string }{"x"}))
+	assert.True(t, Declares(k))
+}
+
+func TestApply(t *testing.T) {
+	k := &keyed{Tenant: "acme", ID: "1", Region: "eu"}
+	assert.NoError(t, Apply(k))
+	assert.Equal(t, "TENANT:acme", k.PartitionKey)
+	assert.Equal(t, "1", k.SortKey)
+	if assert.NotNil(t, k.Pk1) {
+		assert.Equal(t, "TENANT:acme#REGION:eu", *k.Pk1)
+		assert.Equal(t, "ID:1", *k.Sk1)
+	}
+
+	k.Region = ""
+	assert.NoError(t, Apply(k))
+	assert.Nil(t, k.Pk1, "a GSI with an empty partition key part is left unset")
+	assert.Nil(t, k.Sk1)
+
+	k.Tenant = ""
+	assert.ErrorAs(t, Apply(k), new(ErrZeroKeyField))
+}
+
+func TestSpecsAreCached(t *testing.T) {
+	typ := reflect.TypeOf(keyed{})
+	assert.Same(t, specFor(typ), specFor(typ))
+}
+
+func TestFormatOperations(t *testing.T) {
+	type DateTime struct {
+		time.Time
+	}
+	type Event struct {
+		Name    string    `pk:"1,prepend=EVENT:,lower"`
+		Seq     int       `sk:"1,prepend=SEQ:,pad=6"`
+		At      time.Time `sk1:"1,time=rfc3339milli"`
+		Day     DateTime  `pk1:"1,time=date"`
+		Stamp   time.Time `sk2:"1,time=unix"`
+		Email   string    `pk2:"1,prepend=E:,lower,hash=12"`
+		Country string    `sk3:"1,upper"`
+	}
+	at := time.Date(2024, 3, 9, 8, 7, 6, 5e6, time.FixedZone("CET", 3600))
+	e := &Event{Name: "Launch", Seq: 42, At: at, Day: DateTime{at}, Stamp: at, Email: "Jane@Example.com", Country: "nl"}
+
+	keyMap, err := GenerateCompositeKeys(e, "#")
+	assert.NoError(t, err)
+	assert.Equal(t, "EVENT:launch", keyMap["pk"])
+	assert.Equal(t, "SEQ:000042", keyMap["sk"])
+	assert.Equal(t, "2024-03-09T07:07:06.005Z", keyMap["sk1"], "times are formatted in UTC")
+	assert.Equal(t, "2024-03-09", keyMap["pk1"])
+	assert.Equal(t, fmt.Sprintf("%010d", at.Unix()), keyMap["sk2"])
+	assert.Equal(t, "NL", keyMap["sk3"])
+	assert.Len(t, keyMap["pk2"], len("E:")+12)
+	lower := *e
+	lower.Email = "jane@example.com"
+	lowerKeys, err := GenerateCompositeKeys(&lower, "#")
+	assert.NoError(t, err)
+	assert.Equal(t, keyMap["pk2"], lowerKeys["pk2"], "case is folded before hashing")
+
+	t.Run("padded numbers sort as strings", func(t *testing.T) {
+		_, small, err := Keys(&Event{Name: "x", Seq: 9}, 0)
+		assert.NoError(t, err)
+		_, big, err := Keys(&Event{Name: "x", Seq: 10}, 0)
+		assert.NoError(t, err)
+		assert.Less(t, small, big)
+	})
+	t.Run("values wider than the pad or negative are rejected", func(t *testing.T) {
+		_, _, err := Keys(&Event{Name: "x", Seq: 1234567}, 0)
+		assert.Error(t, err)
+		_, _, err = Keys(&Event{Name: "x", Seq: -1}, 0)
+		assert.Error(t, err)
+	})
+	t.Run("operations are checked against the field type", func(t *testing.T) {
+		_, err := GenerateCompositeKeys(&struct {
+			Name string `pk:"1,pad=4"`
+		}{"x"}, "#")
+		assert.ErrorContains(t, err, "pad needs an integer field")
+		_, err = GenerateCompositeKeys(&struct {
+			Count int `pk:"1,time=date"`
+		}{1}, "#")
+		assert.ErrorContains(t, err, "time needs a time.Time")
+		_, err = GenerateCompositeKeys(&struct {
+			Name string `pk:"1,shout"`
+		}{"x"}, "#")
+		assert.ErrorContains(t, err, "unknown operation")
+	})
+}
+
+func TestApplyWithoutGSIPartitionKey(t *testing.T) {
+	type sortOnly struct {
+		keys.Key
+		keys.GSI
+		ID string `pk:"1,prepend=ID:" sk1:"1,prepend=ID:"`
+	}
+	s := &sortOnly{ID: "1"}
+	assert.NoError(t, Apply(s))
+	assert.Nil(t, s.Pk1)
+	assert.Nil(t, s.Sk1)
+}
+
+func TestDecode(t *testing.T) {
+	type DateTime struct {
+		time.Time
+	}
+	type Shipment struct {
+		Tenant  string    `pk:"1,prepend=TENANT:,lower"`
+		ID      uint      `pk:"2,prepend=ID:,pad=8"`
+		At      time.Time `sk:"1,time=rfc3339milli"`
+		Day     DateTime  `sk:"2,time=date"`
+		Seq     int       `sk:"3,pad=4"`
+		Express bool      `sk:"4,append=:express"`
+		Weight  float64   `sk:"5"`
+		Owner   string    `sk:"6,hash"`
+	}
+	at := time.Date(2024, 3, 9, 8, 7, 6, 5e6, time.UTC)
+	original := &Shipment{Tenant: "acme", ID: 42, At: at, Day: DateTime{at.Truncate(24 * time.Hour)}, Seq: 7, Express: true, Weight: 1.5, Owner: "jane"}
+	keyMap, err := GenerateCompositeKeys(original, DefaultDelimiter)
+	assert.NoError(t, err)
+
+	decoded, err := DecodeAs[Shipment]("pk", keyMap["pk"])
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", decoded.Tenant)
+	assert.Equal(t, uint(42), decoded.ID)
+
+	assert.NoError(t, Decode("sk", keyMap["sk"], &decoded))
+	assert.True(t, at.Equal(decoded.At))
+	assert.True(t, original.Day.Equal(decoded.Day.Time))
+	assert.Equal(t, 7, decoded.Seq)
+	assert.True(t, decoded.Express)
+	assert.Equal(t, 1.5, decoded.Weight)
+	assert.Empty(t, decoded.Owner, "hashed values cannot be recovered")
+
+	t.Run("unix times", func(t *testing.T) {
+		type Tick struct {
+			At time.Time `pk:"1,time=unix"`
+		}
+		tick, err := DecodeAs[Tick]("pk", "0001700000")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Unix(1700000, 0).UTC(), tick.At)
+	})
+	t.Run("keys of another shape are rejected", func(t *testing.T) {
+		_, err := DecodeAs[Shipment]("pk", "TENANT:acme")
+		assert.ErrorAs(t, err, new(ErrKeyMismatch))
+		_, err = DecodeAs[Shipment]("pk", "ORG:acme#ID:00000042")
+		assert.ErrorAs(t, err, new(ErrKeyMismatch))
+		_, err = DecodeAs[Shipment]("pk", "TENANT:acme#ID:forty-two")
+		assert.Error(t, err)
+		_, err = DecodeAs[Shipment]("pk9", "x")
+		assert.ErrorAs(t, err, new(ErrNoKeyTags))
+	})
+}
@@ -0,0 +1,408 @@
+package memdb
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// item is a single stored row.
+type item = map[string]types.AttributeValue
+
+// pathElem is one step of a document path, either a map key or a list index.
+type pathElem struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+type docPath []pathElem
+
+func (p docPath) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		switch {
+		case e.isIndex:
+			fmt.Fprintf(&sb, "[%d]", e.index)
+		case i > 0:
+			sb.WriteString("." + e.name)
+		default:
+			sb.WriteString(e.name)
+		}
+	}
+	return sb.String()
+}
+
+// getPath returns the value stored at the path, if any.
+func getPath(it item, p docPath) (types.AttributeValue, bool) {
+	if len(p) == 0 || p[0].isIndex {
+		return nil, false
+	}
+	cur, ok := it[p[0].name]
+	if !ok {
+		return nil, false
+	}
+	for _, e := range p[1:] {
+		switch v := cur.(type) {
+		case *types.AttributeValueMemberM:
+			if e.isIndex {
+				return nil, false
+			}
+			if cur, ok = v.Value[e.name]; !ok {
+				return nil, false
+			}
+		case *types.AttributeValueMemberL:
+			if !e.isIndex || e.index < 0 || e.index >= len(v.Value) {
+				return nil, false
+			}
+			cur = v.Value[e.index]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// setPath stores the value at the path. Intermediate maps and lists must
+// already exist, matching DynamoDB's behaviour for nested SET actions.
+func setPath(it item, p docPath, av types.AttributeValue) error {
+	if len(p) == 0 || p[0].isIndex {
+		return fmt.Errorf("invalid document path %s", p)
+	}
+	if len(p) == 1 {
+		it[p[0].name] = av
+		return nil
+	}
+	parent, ok := getPath(it, p[:len(p)-1])
+	if !ok {
+		return fmt.Errorf("the document path provided in the update expression is invalid for update: %s", p)
+	}
+	last := p[len(p)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if last.isIndex {
+			return fmt.Errorf("the document path provided in the update expression is invalid for update: %s", p)
+		}
+		v.Value[last.name] = av
+	case *types.AttributeValueMemberL:
+		if !last.isIndex {
+			return fmt.Errorf("the document path provided in the update expression is invalid for update: %s", p)
+		}
+		if last.index >= len(v.Value) {
+			v.Value = append(v.Value, av)
+		} else {
+			v.Value[last.index] = av
+		}
+	default:
+		return fmt.Errorf("the document path provided in the update expression is invalid for update: %s", p)
+	}
+	return nil
+}
+
+// removePath deletes the value at the path. Missing paths are ignored.
+func removePath(it item, p docPath) {
+	if len(p) == 0 || p[0].isIndex {
+		return
+	}
+	if len(p) == 1 {
+		delete(it, p[0].name)
+		return
+	}
+	parent, ok := getPath(it, p[:len(p)-1])
+	if !ok {
+		return
+	}
+	last := p[len(p)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		delete(v.Value, last.name)
+	case *types.AttributeValueMemberL:
+		if last.isIndex && last.index >= 0 && last.index < len(v.Value) {
+			v.Value = append(v.Value[:last.index], v.Value[last.index+1:]...)
+		}
+	}
+}
+
+// copyItem deep copies an item so callers can't mutate stored data.
+func copyItem(it item) item {
+	if it == nil {
+		return nil
+	}
+	out := make(item, len(it))
+	for k, v := range it {
+		out[k] = copyValue(v)
+	}
+	return out
+}
+
+func copyValue(av types.AttributeValue) types.AttributeValue {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte(nil), v.Value...)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), v.Value...)}
+	case *types.AttributeValueMemberBS:
+		bs := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			bs[i] = append([]byte(nil), b...)
+		}
+		return &types.AttributeValueMemberBS{Value: bs}
+	case *types.AttributeValueMemberL:
+		l := make([]types.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			l[i] = copyValue(e)
+		}
+		return &types.AttributeValueMemberL{Value: l}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(v.Value)}
+	}
+	return av
+}
+
+// typeName returns the DynamoDB type descriptor of the value.
+func typeName(av types.AttributeValue) string {
+	switch av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	}
+	return ""
+}
+
+func parseNumber(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid number: %q", s)
+	}
+	return r, nil
+}
+
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := r.FloatString(38)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// compareValues orders two scalar values of the same type. ok is false when
+// the values are not comparable with <, <=, > or >=.
+func compareValues(a, b types.AttributeValue) (cmp int, ok bool) {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS:
+		bv, isS := b.(*types.AttributeValueMemberS)
+		if !isS {
+			return 0, false
+		}
+		return strings.Compare(av.Value, bv.Value), true
+	case *types.AttributeValueMemberN:
+		bv, isN := b.(*types.AttributeValueMemberN)
+		if !isN {
+			return 0, false
+		}
+		ar, err := parseNumber(av.Value)
+		if err != nil {
+			return 0, false
+		}
+		br, err := parseNumber(bv.Value)
+		if err != nil {
+			return 0, false
+		}
+		return ar.Cmp(br), true
+	case *types.AttributeValueMemberB:
+		bv, isB := b.(*types.AttributeValueMemberB)
+		if !isB {
+			return 0, false
+		}
+		return bytes.Compare(av.Value, bv.Value), true
+	}
+	return 0, false
+}
+
+// equalValues reports whether two attribute values are equal. Sets are
+// compared without regard to order.
+func equalValues(a, b types.AttributeValue) bool {
+	if typeName(a) != typeName(b) {
+		return false
+	}
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS, *types.AttributeValueMemberN, *types.AttributeValueMemberB:
+		cmp, ok := compareValues(a, b)
+		return ok && cmp == 0
+	case *types.AttributeValueMemberBOOL:
+		return av.Value == b.(*types.AttributeValueMemberBOOL).Value
+	case *types.AttributeValueMemberNULL:
+		return true
+	case *types.AttributeValueMemberSS:
+		return equalStringSets(av.Value, b.(*types.AttributeValueMemberSS).Value)
+	case *types.AttributeValueMemberNS:
+		return equalStringSets(normalizeNumbers(av.Value), normalizeNumbers(b.(*types.AttributeValueMemberNS).Value))
+	case *types.AttributeValueMemberBS:
+		as := make([]string, len(av.Value))
+		for i, v := range av.Value {
+			as[i] = string(v)
+		}
+		bvs := b.(*types.AttributeValueMemberBS).Value
+		bs := make([]string, len(bvs))
+		for i, v := range bvs {
+			bs[i] = string(v)
+		}
+		return equalStringSets(as, bs)
+	case *types.AttributeValueMemberL:
+		bl := b.(*types.AttributeValueMemberL).Value
+		if len(av.Value) != len(bl) {
+			return false
+		}
+		for i := range av.Value {
+			if !equalValues(av.Value[i], bl[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberM:
+		bm := b.(*types.AttributeValueMemberM).Value
+		if len(av.Value) != len(bm) {
+			return false
+		}
+		for k, v := range av.Value {
+			other, ok := bm[k]
+			if !ok || !equalValues(v, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func normalizeNumbers(ns []string) []string {
+	out := make([]string, len(ns))
+	for i, n := range ns {
+		r, err := parseNumber(n)
+		if err != nil {
+			out[i] = n
+			continue
+		}
+		out[i] = formatNumber(r)
+	}
+	return out
+}
+
+func equalStringSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	as := append([]string(nil), a...)
+	bs := append([]string(nil), b...)
+	sort.Strings(as)
+	sort.Strings(bs)
+	for i := range as {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// valueSize approximates the DynamoDB size of a value in bytes.
+func valueSize(av types.AttributeValue) int {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return (len(v.Value)+1)/2 + 1
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberBOOL, *types.AttributeValueMemberNULL:
+		return 1
+	case *types.AttributeValueMemberSS:
+		n := 0
+		for _, s := range v.Value {
+			n += len(s)
+		}
+		return n
+	case *types.AttributeValueMemberNS:
+		n := 0
+		for _, s := range v.Value {
+			n += (len(s)+1)/2 + 1
+		}
+		return n
+	case *types.AttributeValueMemberBS:
+		n := 0
+		for _, b := range v.Value {
+			n += len(b)
+		}
+		return n
+	case *types.AttributeValueMemberL:
+		n := 3
+		for _, e := range v.Value {
+			n += valueSize(e) + 1
+		}
+		return n
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(v.Value)
+	}
+	return 0
+}
+
+// itemSize approximates the DynamoDB size of an item in bytes.
+func itemSize(it item) int {
+	n := 0
+	for k, v := range it {
+		n += len(k) + valueSize(v)
+	}
+	return n
+}
+
+// sizeOf implements the size() function of DynamoDB expressions.
+func sizeOf(av types.AttributeValue) (int, bool) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberB:
+		return len(v.Value), true
+	case *types.AttributeValueMemberSS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberNS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberBS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberL:
+		return len(v.Value), true
+	case *types.AttributeValueMemberM:
+		return len(v.Value), true
+	}
+	return 0, false
+}
@@ -0,0 +1,185 @@
+package memdb
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	maxBatchGetKeys   = 100
+	maxBatchWriteReqs = 25
+)
+
+// WithBatchCapacity caps the number of keys or write requests a single
+// BatchGetItem or BatchWriteItem call processes. The rest are returned as
+// UnprocessedKeys or UnprocessedItems, as DynamoDB does when a request is
+// throttled, so retry logic can be exercised.
+func WithBatchCapacity(n int) Option {
+	return func(db *DB) {
+		db.batchCapacity = n
+	}
+}
+
+// BatchGetItem implements clients.DynamoMethods.
+func (db *DB) BatchGetItem(ctx context.Context, in *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(in.RequestItems) == 0 {
+		return nil, validationError("1 validation error detected: Value at 'requestItems' failed to satisfy constraint: Member must have length greater than or equal to 1")
+	}
+	total := 0
+	for _, name := range sortedTableNames(in.RequestItems) {
+		ka := in.RequestItems[name]
+		if _, err := db.table(&name); err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, key := range ka.Keys {
+			k, err := validateKey(key)
+			if err != nil {
+				return nil, err
+			}
+			if seen[k] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[k] = true
+		}
+		total += len(ka.Keys)
+	}
+	if total > maxBatchGetKeys {
+		return nil, validationError("Too many items requested for the BatchGetItem call")
+	}
+
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]types.AttributeValue{},
+		UnprocessedKeys: map[string]types.KeysAndAttributes{},
+	}
+	processed := 0
+	for _, name := range sortedTableNames(in.RequestItems) {
+		ka := in.RequestItems[name]
+		t := db.tables[name]
+		var paths []docPath
+		if ka.ProjectionExpression != nil && *ka.ProjectionExpression != "" {
+			var err error
+			if paths, err = parseProjection(*ka.ProjectionExpression, ka.ExpressionAttributeNames); err != nil {
+				return nil, validationError("%v", err)
+			}
+		}
+		var units float64
+		for i, key := range ka.Keys {
+			if db.batchCapacity > 0 && processed >= db.batchCapacity {
+				rest := ka
+				rest.Keys = make([]map[string]types.AttributeValue, 0, len(ka.Keys)-i)
+				for _, key := range ka.Keys[i:] {
+					rest.Keys = append(rest.Keys, copyItem(key))
+				}
+				out.UnprocessedKeys[name] = rest
+				break
+			}
+			processed++
+			k, _ := keyString(key)
+			stored, ok := t.items[k]
+			units += readUnits(stored, ka.ConsistentRead)
+			if !ok {
+				continue
+			}
+			if paths != nil {
+				out.Responses[name] = append(out.Responses[name], project(stored, paths))
+			} else {
+				out.Responses[name] = append(out.Responses[name], copyItem(stored))
+			}
+		}
+		if cc := consumedCapacity(in.ReturnConsumedCapacity, name, units); cc != nil {
+			out.ConsumedCapacity = append(out.ConsumedCapacity, *cc)
+		}
+	}
+	return out, nil
+}
+
+// BatchWriteItem implements clients.DynamoMethods.
+func (db *DB) BatchWriteItem(ctx context.Context, in *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(in.RequestItems) == 0 {
+		return nil, validationError("1 validation error detected: Value at 'requestItems' failed to satisfy constraint: Member must have length greater than or equal to 1")
+	}
+	total := 0
+	for _, name := range sortedTableNames(in.RequestItems) {
+		if _, err := db.table(&name); err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, req := range in.RequestItems[name] {
+			var k string
+			var err error
+			switch {
+			case req.PutRequest != nil && req.DeleteRequest == nil:
+				k, err = keyString(req.PutRequest.Item)
+			case req.DeleteRequest != nil && req.PutRequest == nil:
+				k, err = validateKey(req.DeleteRequest.Key)
+			default:
+				err = validationError("Supplied AttributeValue has more than one datatypes set, must contain exactly one of the supported datatypes")
+			}
+			if err != nil {
+				return nil, err
+			}
+			if seen[k] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[k] = true
+		}
+		total += len(in.RequestItems[name])
+	}
+	if total > maxBatchWriteReqs {
+		return nil, validationError("Too many items requested for the BatchWriteItem call")
+	}
+
+	out := &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]types.WriteRequest{},
+	}
+	processed := 0
+	for _, name := range sortedTableNames(in.RequestItems) {
+		reqs := in.RequestItems[name]
+		t := db.tables[name]
+		var units float64
+		for i, req := range reqs {
+			if db.batchCapacity > 0 && processed >= db.batchCapacity {
+				out.UnprocessedItems[name] = append([]types.WriteRequest(nil), reqs[i:]...)
+				break
+			}
+			processed++
+			if req.PutRequest != nil {
+				k, _ := keyString(req.PutRequest.Item)
+				t.items[k] = copyItem(req.PutRequest.Item)
+				units += db.writeUnits(req.PutRequest.Item)
+				continue
+			}
+			k, _ := keyString(req.DeleteRequest.Key)
+			units += db.writeUnits(t.items[k])
+			delete(t.items, k)
+		}
+		if cc := consumedCapacity(in.ReturnConsumedCapacity, name, units); cc != nil {
+			out.ConsumedCapacity = append(out.ConsumedCapacity, *cc)
+		}
+	}
+	return out, nil
+}
+
+// sortedTableNames returns the keys of a RequestItems map in a stable order.
+func sortedTableNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
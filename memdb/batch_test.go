@@ -0,0 +1,81 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	db := New(WithBatchCapacity(3))
+
+	key := func(i int) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{"pk": s(fmt.Sprint(i)), "sk": s("row")}
+	}
+	var writes []types.WriteRequest
+	for i := 0; i < 5; i++ {
+		it := key(i)
+		it["n"] = n(fmt.Sprint(i))
+		writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: it}})
+	}
+
+	t.Run("writes beyond the capacity are unprocessed", func(t *testing.T) {
+		out, err := db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{testTable: writes},
+		})
+		require.NoError(t, err)
+		require.Len(t, out.UnprocessedItems[testTable], 2)
+		assert.Len(t, db.Items(testTable), 3)
+
+		out, err = db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: out.UnprocessedItems})
+		require.NoError(t, err)
+		assert.Empty(t, out.UnprocessedItems)
+		assert.Len(t, db.Items(testTable), 5)
+	})
+
+	t.Run("gets return found items and unprocessed keys", func(t *testing.T) {
+		keys := []map[string]types.AttributeValue{key(0), key(1), key(9), key(3)}
+		out, err := db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{testTable: {Keys: keys, ProjectionExpression: aws.String("pk")}},
+		})
+		require.NoError(t, err)
+		assert.Len(t, out.Responses[testTable], 2)
+		assert.NotContains(t, out.Responses[testTable][0], "n")
+		require.Len(t, out.UnprocessedKeys[testTable].Keys, 1)
+		assert.Equal(t, key(3), out.UnprocessedKeys[testTable].Keys[0])
+	})
+
+	t.Run("deletes", func(t *testing.T) {
+		_, err := db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{testTable: {
+				{DeleteRequest: &types.DeleteRequest{Key: key(0)}},
+				{DeleteRequest: &types.DeleteRequest{Key: key(1)}},
+			}},
+		})
+		require.NoError(t, err)
+		assert.Len(t, db.Items(testTable), 3)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := db.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{testTable: {Keys: []map[string]types.AttributeValue{key(1), key(1)}}},
+		})
+		assert.Error(t, err)
+
+		var tooMany []types.WriteRequest
+		for i := 0; i < 26; i++ {
+			tooMany = append(tooMany, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key(i)}})
+		}
+		_, err = db.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{testTable: tooMany},
+		})
+		assert.Error(t, err)
+	})
+}
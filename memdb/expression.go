@@ -0,0 +1,638 @@
+package memdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNamePlaceholder
+	tokValuePlaceholder
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a DynamoDB expression into tokens.
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':':
+			j := i + 1
+			for j < len(s) && isIdentRune(rune(s[j])) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid placeholder at position %d in expression %q", i, s)
+			}
+			kind := tokNamePlaceholder
+			if c == ':' {
+				kind = tokValuePlaceholder
+			}
+			toks = append(toks, token{kind, s[i:j]})
+			i = j
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(s) && unicode.IsDigit(rune(s[j])) {
+				j++
+			}
+			toks = append(toks, token{tokNumber, s[i:j]})
+			i = j
+		case isIdentRune(c):
+			j := i
+			for j < len(s) && isIdentRune(rune(s[j])) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j]})
+			i = j
+		case strings.HasPrefix(s[i:], "<>"), strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			toks = append(toks, token{tokPunct, s[i : i+2]})
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", c):
+			toks = append(toks, token{tokPunct, string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d in expression %q", c, i, s)
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+func isIdentRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// parser holds the state shared by condition, update and projection parsing.
+type parser struct {
+	src    string
+	toks   []token
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newParser(expr string, names map[string]string, values map[string]types.AttributeValue) (*parser, error) {
+	toks, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{src: expr, toks: toks, names: names, values: values}, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.errorf("expected %q", s)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	t := p.peek()
+	near := t.text
+	if t.kind == tokEOF {
+		near = "end of expression"
+	}
+	return fmt.Errorf("invalid expression %q near %s: %s", p.src, near, fmt.Sprintf(format, args...))
+}
+
+// parsePath parses an attribute path such as #a.b[2].c.
+func (p *parser) parsePath() (docPath, error) {
+	var path docPath
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	path = append(path, pathElem{name: name})
+	for {
+		switch {
+		case p.isPunct("."):
+			p.next()
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, pathElem{name: name})
+		case p.isPunct("["):
+			p.next()
+			t := p.next()
+			if t.kind != tokNumber {
+				return nil, p.errorf("expected list index")
+			}
+			idx, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, p.errorf("invalid list index %s", t.text)
+			}
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, pathElem{index: idx, isIndex: true})
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (p *parser) parseName() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		return t.text, nil
+	case tokNamePlaceholder:
+		name, ok := p.names[t.text]
+		if !ok {
+			return "", fmt.Errorf("invalid expression %q: an expression attribute name used in the document path is not defined; attribute name: %s", p.src, t.text)
+		}
+		return name, nil
+	}
+	p.pos--
+	return "", p.errorf("expected attribute name")
+}
+
+func (p *parser) parseValuePlaceholder() (types.AttributeValue, error) {
+	t := p.next()
+	if t.kind != tokValuePlaceholder {
+		p.pos--
+		return nil, p.errorf("expected expression attribute value")
+	}
+	v, ok := p.values[t.text]
+	if !ok {
+		return nil, fmt.Errorf("invalid expression %q: an expression attribute value used in expression is not defined; attribute value: %s", p.src, t.text)
+	}
+	return v, nil
+}
+
+// operand is anything that resolves to a value while evaluating a condition.
+type operand interface {
+	resolve(it item) (types.AttributeValue, bool)
+}
+
+type pathOperand struct{ path docPath }
+
+func (o pathOperand) resolve(it item) (types.AttributeValue, bool) {
+	return getPath(it, o.path)
+}
+
+type valueOperand struct{ value types.AttributeValue }
+
+func (o valueOperand) resolve(item) (types.AttributeValue, bool) {
+	return o.value, true
+}
+
+type sizeOperand struct{ path docPath }
+
+func (o sizeOperand) resolve(it item) (types.AttributeValue, bool) {
+	av, ok := getPath(it, o.path)
+	if !ok {
+		return nil, false
+	}
+	n, ok := sizeOf(av)
+	if !ok {
+		return nil, false
+	}
+	return &types.AttributeValueMemberN{Value: strconv.Itoa(n)}, true
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokValuePlaceholder:
+		v, err := p.parseValuePlaceholder()
+		if err != nil {
+			return nil, err
+		}
+		return valueOperand{v}, nil
+	case t.kind == tokIdent && strings.EqualFold(t.text, "size") && p.toks[p.pos+1].text == "(":
+		p.next()
+		p.next()
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return sizeOperand{path}, nil
+	default:
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{path}, nil
+	}
+}
+
+// condition is a parsed condition, filter or key condition expression.
+type condition interface {
+	eval(it item) bool
+}
+
+type andCond struct{ left, right condition }
+
+func (c andCond) eval(it item) bool { return c.left.eval(it) && c.right.eval(it) }
+
+type orCond struct{ left, right condition }
+
+func (c orCond) eval(it item) bool { return c.left.eval(it) || c.right.eval(it) }
+
+type notCond struct{ inner condition }
+
+func (c notCond) eval(it item) bool { return !c.inner.eval(it) }
+
+type compareCond struct {
+	op          string
+	left, right operand
+}
+
+func (c compareCond) eval(it item) bool {
+	l, lok := c.left.resolve(it)
+	r, rok := c.right.resolve(it)
+	if c.op == "<>" {
+		return !lok || !rok || !equalValues(l, r)
+	}
+	if !lok || !rok {
+		return false
+	}
+	if c.op == "=" {
+		return equalValues(l, r)
+	}
+	cmp, ok := compareValues(l, r)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+type betweenCond struct{ value, low, high operand }
+
+func (c betweenCond) eval(it item) bool {
+	v, ok := c.value.resolve(it)
+	if !ok {
+		return false
+	}
+	lo, ok := c.low.resolve(it)
+	if !ok {
+		return false
+	}
+	hi, ok := c.high.resolve(it)
+	if !ok {
+		return false
+	}
+	lcmp, lok := compareValues(v, lo)
+	hcmp, hok := compareValues(v, hi)
+	return lok && hok && lcmp >= 0 && hcmp <= 0
+}
+
+type inCond struct {
+	value   operand
+	options []operand
+}
+
+func (c inCond) eval(it item) bool {
+	v, ok := c.value.resolve(it)
+	if !ok {
+		return false
+	}
+	for _, o := range c.options {
+		if ov, ok := o.resolve(it); ok && equalValues(v, ov) {
+			return true
+		}
+	}
+	return false
+}
+
+type funcCond struct {
+	name string
+	path docPath
+	arg  operand
+}
+
+func (c funcCond) eval(it item) bool {
+	av, exists := getPath(it, c.path)
+	switch c.name {
+	case "attribute_exists":
+		return exists
+	case "attribute_not_exists":
+		return !exists
+	}
+	if !exists {
+		return false
+	}
+	arg, ok := c.arg.resolve(it)
+	if !ok {
+		return false
+	}
+	switch c.name {
+	case "attribute_type":
+		s, ok := arg.(*types.AttributeValueMemberS)
+		return ok && typeName(av) == s.Value
+	case "begins_with":
+		switch v := av.(type) {
+		case *types.AttributeValueMemberS:
+			prefix, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.HasPrefix(v.Value, prefix.Value)
+		case *types.AttributeValueMemberB:
+			prefix, ok := arg.(*types.AttributeValueMemberB)
+			return ok && strings.HasPrefix(string(v.Value), string(prefix.Value))
+		}
+		return false
+	case "contains":
+		switch v := av.(type) {
+		case *types.AttributeValueMemberS:
+			sub, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.Contains(v.Value, sub.Value)
+		case *types.AttributeValueMemberB:
+			sub, ok := arg.(*types.AttributeValueMemberB)
+			return ok && strings.Contains(string(v.Value), string(sub.Value))
+		case *types.AttributeValueMemberSS:
+			return containsValue(v.Value, arg, func(s string) types.AttributeValue { return &types.AttributeValueMemberS{Value: s} })
+		case *types.AttributeValueMemberNS:
+			return containsValue(v.Value, arg, func(s string) types.AttributeValue { return &types.AttributeValueMemberN{Value: s} })
+		case *types.AttributeValueMemberBS:
+			for _, b := range v.Value {
+				if equalValues(&types.AttributeValueMemberB{Value: b}, arg) {
+					return true
+				}
+			}
+		case *types.AttributeValueMemberL:
+			for _, e := range v.Value {
+				if equalValues(e, arg) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func containsValue(set []string, arg types.AttributeValue, wrap func(string) types.AttributeValue) bool {
+	for _, s := range set {
+		if equalValues(wrap(s), arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCondition parses a complete condition expression.
+func parseCondition(expr string, names map[string]string, values map[string]types.AttributeValue) (condition, error) {
+	p, err := newParser(expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return c, nil
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCond{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCond{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCond{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+var conditionFunctions = map[string]bool{
+	"attribute_exists":     true,
+	"attribute_not_exists": true,
+	"attribute_type":       true,
+	"begins_with":          true,
+	"contains":             true,
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.isPunct("(") {
+		p.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expectPunct(")")
+	}
+	t := p.peek()
+	if t.kind == tokIdent && conditionFunctions[strings.ToLower(t.text)] && p.toks[p.pos+1].text == "(" {
+		return p.parseFunction()
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.errorf("expected AND in BETWEEN")
+		}
+		p.next()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return betweenCond{left, low, high}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		var options []operand
+		for {
+			o, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, o)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		return inCond{left, options}, p.expectPunct(")")
+	}
+	op := p.next()
+	switch op.text {
+	case "=", "<>", "<", "<=", ">", ">=":
+	default:
+		p.pos--
+		return nil, p.errorf("expected comparator")
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareCond{op.text, left, right}, nil
+}
+
+func (p *parser) parseFunction() (condition, error) {
+	name := strings.ToLower(p.next().text)
+	p.next() // (
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	c := funcCond{name: name, path: path}
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if err := p.expectPunct(","); err != nil {
+			return nil, err
+		}
+		if c.arg, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+	}
+	return c, p.expectPunct(")")
+}
+
+// parseProjection parses a comma separated list of document paths.
+func parseProjection(expr string, names map[string]string) ([]docPath, error) {
+	p, err := newParser(expr, names, nil)
+	if err != nil {
+		return nil, err
+	}
+	var paths []docPath
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return paths, nil
+}
+
+// project returns a copy of the item containing only the requested paths.
+// Nested map paths are preserved; paths that index into a list project the
+// whole top level attribute.
+func project(it item, paths []docPath) item {
+	out := item{}
+	for _, path := range paths {
+		av, ok := getPath(it, path)
+		if !ok {
+			continue
+		}
+		if len(path) == 1 {
+			out[path[0].name] = copyValue(av)
+			continue
+		}
+		nested := true
+		for _, e := range path {
+			if e.isIndex {
+				nested = false
+			}
+		}
+		if !nested {
+			out[path[0].name] = copyValue(it[path[0].name])
+			continue
+		}
+		cur := out
+		for _, e := range path[:len(path)-1] {
+			m, ok := cur[e.name].(*types.AttributeValueMemberM)
+			if !ok {
+				m = &types.AttributeValueMemberM{Value: item{}}
+				cur[e.name] = m
+			}
+			cur = m.Value
+		}
+		cur[path[len(path)-1].name] = copyValue(av)
+	}
+	return out
+}
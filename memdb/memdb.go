@@ -0,0 +1,618 @@
+// Package memdb provides an in-memory stand-in for DynamoDB. The DB type
+// satisfies clients.DynamoMethods, so it can be plugged into a
+// clients.Client and used by the dynamo package without a network or AWS
+// credentials. It is intended for tests and local development.
+//
+// Tables are created on first use with the pk/sk primary key used throughout
+// gobox, unless WithTable is used to declare the only tables that exist.
+// Global secondary indexes are inferred from their names, which follow the
+// "<hashKey>-<rangeKey>-index" convention (pk1-sk1-index, e0pk-e0sk-index,
+// ...), so every GSI the dynamo package queries works out of the box.
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/entegral/gobox/clients"
+	"github.com/entegral/gobox/keys"
+)
+
+// DefaultIndexes are the GSIs a gobox table is expected to have. They are
+// used to charge write capacity for every index an item projects into.
+var DefaultIndexes = []string{
+	"pk1-sk1-index",
+	"pk2-sk2-index",
+	"pk3-sk3-index",
+	"pk4-sk4-index",
+	"pk5-sk5-index",
+	"pk6-sk6-index",
+	"e0pk-e0sk-index",
+	"e1pk-e1sk-index",
+	"e2pk-e2sk-index",
+	"e0pk-linksort-index",
+	"e1pk-linksort-index",
+	"pkshard-index",
+}
+
+// DB is an in-memory DynamoDB. The zero value is not usable, use New.
+type DB struct {
+	mu       sync.Mutex
+	tables   map[string]*table
+	declared map[string]bool
+	indexes  []index
+	pageSize int
+
+	batchCapacity int
+}
+
+// maxPageBytes is the amount of data a single Query reads before it returns
+// a page with a LastEvaluatedKey.
+const maxPageBytes = 1 << 20
+
+var _ clients.DynamoMethods = (*DB)(nil)
+
+// Option configures a DB.
+type Option func(*DB)
+
+// WithTable declares a table. Once any table is declared, requests against
+// undeclared tables fail with a ResourceNotFoundException instead of
+// creating the table on first use.
+func WithTable(name string) Option {
+	return func(db *DB) {
+		db.declared[name] = true
+	}
+}
+
+// WithIndexes replaces DefaultIndexes as the set of GSIs used to compute
+// consumed write capacity.
+func WithIndexes(names ...string) Option {
+	return func(db *DB) {
+		db.indexes = nil
+		for _, name := range names {
+			if idx, err := resolveIndex(&name); err == nil {
+				db.indexes = append(db.indexes, idx)
+			}
+		}
+	}
+}
+
+// WithPageSize caps the number of items evaluated by a single Query, so
+// pagination can be exercised without writing a megabyte of data. Requests
+// with a smaller Limit are unaffected.
+func WithPageSize(n int) Option {
+	return func(db *DB) {
+		db.pageSize = n
+	}
+}
+
+// New returns an empty in-memory DynamoDB.
+func New(opts ...Option) *DB {
+	db := &DB{tables: map[string]*table{}, declared: map[string]bool{}}
+	WithIndexes(DefaultIndexes...)(db)
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db
+}
+
+// Client returns a clients.Client whose Dynamo() calls are served by the DB.
+func (db *DB) Client() clients.Client {
+	return clients.Client{}.WithDynamo(db)
+}
+
+// Reset removes every table and item from the DB.
+func (db *DB) Reset() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.tables = map[string]*table{}
+}
+
+// Items returns a copy of every item stored in the table, ordered by
+// primary key.
+func (db *DB) Items(tableName string) []map[string]types.AttributeValue {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, ok := db.tables[tableName]
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(t.items))
+	for k := range t.items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]map[string]types.AttributeValue, len(keys))
+	for i, k := range keys {
+		out[i] = copyItem(t.items[k])
+	}
+	return out
+}
+
+type table struct {
+	name  string
+	items map[string]item
+}
+
+// index describes the key schema of the table or one of its GSIs.
+type index struct {
+	name     string
+	hashKey  string
+	rangeKey string
+}
+
+var primaryIndex = index{hashKey: keys.PkKey, rangeKey: keys.SkKey}
+
+// table returns the named table, creating it if it doesn't exist yet.
+// The caller must hold db.mu.
+func (db *DB) table(name *string) (*table, error) {
+	if name == nil || *name == "" {
+		return nil, validationError("1 validation error detected: Value null at 'tableName' failed to satisfy constraint: Member must not be null")
+	}
+	t, ok := db.tables[*name]
+	if !ok {
+		if len(db.declared) > 0 && !db.declared[*name] {
+			return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found: Table: " + *name + " not found")}
+		}
+		t = &table{name: *name, items: map[string]item{}}
+		db.tables[*name] = t
+	}
+	return t, nil
+}
+
+// resolveIndex returns the key schema for the index name, inferring GSI
+// key attributes from the "<hashKey>-<rangeKey>-index" naming convention.
+func resolveIndex(name *string) (index, error) {
+	if name == nil || *name == "" {
+		return primaryIndex, nil
+	}
+	trimmed := strings.TrimSuffix(*name, "-index")
+	if trimmed == *name || trimmed == "" {
+		return index{}, validationError("The table does not have the specified index: %s", *name)
+	}
+	parts := strings.Split(trimmed, "-")
+	switch len(parts) {
+	case 1:
+		return index{name: *name, hashKey: parts[0]}, nil
+	case 2:
+		return index{name: *name, hashKey: parts[0], rangeKey: parts[1]}, nil
+	}
+	return index{}, validationError("The table does not have the specified index: %s", *name)
+}
+
+// keyString returns a unique string for the primary key of the item.
+func keyString(it item) (string, error) {
+	pk, err := keyPart(it, keys.PkKey)
+	if err != nil {
+		return "", err
+	}
+	sk, err := keyPart(it, keys.SkKey)
+	if err != nil {
+		return "", err
+	}
+	return pk + "\x00" + sk, nil
+}
+
+func keyPart(it item, name string) (string, error) {
+	av, ok := it[name]
+	if !ok {
+		return "", validationError("One of the required keys was not given a value: %s", name)
+	}
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		if v.Value == "" {
+			return "", validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name)
+		}
+		return "S:" + v.Value, nil
+	case *types.AttributeValueMemberN:
+		return "N:" + v.Value, nil
+	case *types.AttributeValueMemberB:
+		return "B:" + string(v.Value), nil
+	}
+	return "", validationError("One or more parameter values were invalid: Type mismatch for key %s", name)
+}
+
+// validateKey ensures a Key map holds exactly the primary key attributes.
+func validateKey(key item) (string, error) {
+	if len(key) != 2 {
+		return "", validationError("The provided key element does not match the schema")
+	}
+	return keyString(key)
+}
+
+func primaryKey(it item) item {
+	return item{keys.PkKey: copyValue(it[keys.PkKey]), keys.SkKey: copyValue(it[keys.SkKey])}
+}
+
+func validationError(format string, args ...any) error {
+	return &smithy.GenericAPIError{Code: "ValidationException", Message: fmt.Sprintf(format, args...)}
+}
+
+func conditionFailed(old item, rv types.ReturnValuesOnConditionCheckFailure) error {
+	err := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	if rv == types.ReturnValuesOnConditionCheckFailureAllOld && old != nil {
+		err.Item = copyItem(old)
+	}
+	return err
+}
+
+// checkCondition evaluates an optional condition expression against the
+// current item, which is nil when the item does not exist.
+func checkCondition(expr *string, names map[string]string, values map[string]types.AttributeValue, current item) (bool, error) {
+	if expr == nil || *expr == "" {
+		return true, nil
+	}
+	c, err := parseCondition(*expr, names, values)
+	if err != nil {
+		return false, validationError("%v", err)
+	}
+	if current == nil {
+		current = item{}
+	}
+	return c.eval(current), nil
+}
+
+func consumedCapacity(rcc types.ReturnConsumedCapacity, tableName string, units float64) *types.ConsumedCapacity {
+	if rcc == "" || rcc == types.ReturnConsumedCapacityNone {
+		return nil
+	}
+	return &types.ConsumedCapacity{TableName: aws.String(tableName), CapacityUnits: aws.Float64(units)}
+}
+
+func readUnits(it item, consistent *bool) float64 {
+	units := math.Max(1, math.Ceil(float64(itemSize(it))/4096))
+	if consistent != nil && *consistent {
+		return units
+	}
+	return units / 2
+}
+
+// writeUnits charges one write per KB for the table and again for every
+// GSI the item projects into.
+func (db *DB) writeUnits(it item) float64 {
+	units := math.Max(1, math.Ceil(float64(itemSize(it))/1024))
+	total := units
+	for _, idx := range db.indexes {
+		if idx.contains(it) {
+			total += units
+		}
+	}
+	return total
+}
+
+// GetItem implements clients.DynamoMethods.
+func (db *DB) GetItem(ctx context.Context, in *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, err := db.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := validateKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	out := &dynamodb.GetItemOutput{}
+	stored, ok := t.items[k]
+	if ok {
+		out.Item = copyItem(stored)
+		if in.ProjectionExpression != nil {
+			paths, err := parseProjection(*in.ProjectionExpression, in.ExpressionAttributeNames)
+			if err != nil {
+				return nil, validationError("%v", err)
+			}
+			out.Item = project(stored, paths)
+		}
+	}
+	out.ConsumedCapacity = consumedCapacity(in.ReturnConsumedCapacity, t.name, readUnits(stored, in.ConsistentRead))
+	return out, nil
+}
+
+// PutItem implements clients.DynamoMethods.
+func (db *DB) PutItem(ctx context.Context, in *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, err := db.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := keyString(in.Item)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	ok, err := checkCondition(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, old)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, conditionFailed(old, in.ReturnValuesOnConditionCheckFailure)
+	}
+	t.items[k] = copyItem(in.Item)
+	out := &dynamodb.PutItemOutput{
+		ConsumedCapacity: consumedCapacity(in.ReturnConsumedCapacity, t.name, db.writeUnits(in.Item)),
+	}
+	if in.ReturnValues == types.ReturnValueAllOld && old != nil {
+		out.Attributes = copyItem(old)
+	}
+	return out, nil
+}
+
+// DeleteItem implements clients.DynamoMethods.
+func (db *DB) DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, err := db.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := validateKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	ok, err := checkCondition(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, old)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, conditionFailed(old, in.ReturnValuesOnConditionCheckFailure)
+	}
+	delete(t.items, k)
+	out := &dynamodb.DeleteItemOutput{
+		ConsumedCapacity: consumedCapacity(in.ReturnConsumedCapacity, t.name, db.writeUnits(old)),
+	}
+	if in.ReturnValues == types.ReturnValueAllOld && old != nil {
+		out.Attributes = copyItem(old)
+	}
+	return out, nil
+}
+
+// UpdateItem implements clients.DynamoMethods. As in DynamoDB, updating an
+// item that does not exist creates it from the key and the update actions.
+func (db *DB) UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, err := db.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := validateKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+	old := t.items[k]
+	ok, err := checkCondition(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, old)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, conditionFailed(old, in.ReturnValuesOnConditionCheckFailure)
+	}
+	updated, touched, err := applyUpdate(in.Key, old, in.UpdateExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	t.items[k] = updated
+	out := &dynamodb.UpdateItemOutput{
+		ConsumedCapacity: consumedCapacity(in.ReturnConsumedCapacity, t.name, db.writeUnits(updated)),
+	}
+	switch in.ReturnValues {
+	case types.ReturnValueAllOld:
+		out.Attributes = copyItem(old)
+	case types.ReturnValueAllNew:
+		out.Attributes = copyItem(updated)
+	case types.ReturnValueUpdatedOld:
+		out.Attributes = pick(old, touched)
+	case types.ReturnValueUpdatedNew:
+		out.Attributes = pick(updated, touched)
+	}
+	return out, nil
+}
+
+// applyUpdate returns the item that results from applying the update
+// expression to old, or to a new item holding only the key when old is nil,
+// along with the names of the attributes the expression touches.
+func applyUpdate(key, old item, expr *string, names map[string]string, values map[string]types.AttributeValue) (item, []string, error) {
+	base := old
+	if base == nil {
+		base = copyItem(key)
+	}
+	if expr == nil {
+		return copyItem(base), nil, nil
+	}
+	plan, err := parseUpdate(*expr, names, values)
+	if err != nil {
+		return nil, nil, validationError("%v", err)
+	}
+	touched := plan.touched()
+	for _, name := range touched {
+		if name == keys.PkKey || name == keys.SkKey {
+			return nil, nil, validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", name)
+		}
+	}
+	updated, err := plan.apply(base)
+	if err != nil {
+		return nil, nil, validationError("%v", err)
+	}
+	return updated, touched, nil
+}
+
+func pick(it item, names []string) item {
+	if it == nil {
+		return nil
+	}
+	out := item{}
+	for _, n := range names {
+		if v, ok := it[n]; ok {
+			out[n] = copyValue(v)
+		}
+	}
+	return out
+}
+
+// Query implements clients.DynamoMethods. Results are ordered by the sort
+// key of the queried index, Limit caps the number of items evaluated before
+// the filter expression is applied and LastEvaluatedKey is returned whenever
+// more items remain.
+func (db *DB) Query(ctx context.Context, in *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, err := db.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := resolveIndex(in.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	if in.KeyConditionExpression == nil || *in.KeyConditionExpression == "" {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+	keyCond, err := parseCondition(*in.KeyConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, validationError("%v", err)
+	}
+	var filter condition
+	if in.FilterExpression != nil && *in.FilterExpression != "" {
+		if filter, err = parseCondition(*in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues); err != nil {
+			return nil, validationError("%v", err)
+		}
+	}
+	var projection []docPath
+	if in.ProjectionExpression != nil && *in.ProjectionExpression != "" {
+		if projection, err = parseProjection(*in.ProjectionExpression, in.ExpressionAttributeNames); err != nil {
+			return nil, validationError("%v", err)
+		}
+	}
+
+	var candidates []item
+	for _, it := range t.items {
+		if !idx.contains(it) || !keyCond.eval(it) {
+			continue
+		}
+		candidates = append(candidates, it)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return idx.compare(candidates[i], candidates[j]) < 0
+	})
+	forward := in.ScanIndexForward == nil || *in.ScanIndexForward
+	if !forward {
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+	if in.ExclusiveStartKey != nil {
+		start := len(candidates)
+		for i, it := range candidates {
+			cmp := idx.compare(it, in.ExclusiveStartKey)
+			if (forward && cmp > 0) || (!forward && cmp < 0) {
+				start = i
+				break
+			}
+		}
+		candidates = candidates[start:]
+	}
+
+	out := &dynamodb.QueryOutput{}
+	limit := len(candidates)
+	if in.Limit != nil && int(*in.Limit) < limit {
+		limit = int(*in.Limit)
+	}
+	if db.pageSize > 0 && db.pageSize < limit {
+		limit = db.pageSize
+	}
+	var units float64
+	var scannedBytes int
+	for i, it := range candidates[:limit] {
+		// like DynamoDB, stop once a page has read 1 MB of data
+		if scannedBytes >= maxPageBytes {
+			limit = i
+			break
+		}
+		scannedBytes += itemSize(it)
+		out.ScannedCount++
+		units += readUnits(it, in.ConsistentRead)
+		if filter != nil && !filter.eval(it) {
+			continue
+		}
+		out.Count++
+		if in.Select == types.SelectCount {
+			continue
+		}
+		if projection != nil {
+			out.Items = append(out.Items, project(it, projection))
+		} else {
+			out.Items = append(out.Items, copyItem(it))
+		}
+	}
+	if limit < len(candidates) && limit > 0 {
+		out.LastEvaluatedKey = idx.lastEvaluatedKey(candidates[limit-1])
+	}
+	out.ConsumedCapacity = consumedCapacity(in.ReturnConsumedCapacity, t.name, units)
+	return out, nil
+}
+
+// contains reports whether the item projects into the index.
+func (idx index) contains(it item) bool {
+	if _, ok := it[idx.hashKey]; !ok {
+		return false
+	}
+	if idx.rangeKey == "" {
+		return true
+	}
+	_, ok := it[idx.rangeKey]
+	return ok
+}
+
+// compare orders items by the index sort key, falling back to the table's
+// primary key so that items sharing a GSI key have a stable order.
+func (idx index) compare(a, b item) int {
+	if idx.rangeKey != "" {
+		av, aok := a[idx.rangeKey]
+		bv, bok := b[idx.rangeKey]
+		if aok && bok {
+			if cmp, ok := compareValues(av, bv); ok && cmp != 0 {
+				return cmp
+			}
+		}
+	}
+	if idx.name == "" {
+		return 0
+	}
+	ak, _ := keyString(a)
+	bk, _ := keyString(b)
+	return strings.Compare(ak, bk)
+}
+
+func (idx index) lastEvaluatedKey(it item) item {
+	lek := primaryKey(it)
+	lek[idx.hashKey] = copyValue(it[idx.hashKey])
+	if idx.rangeKey != "" {
+		lek[idx.rangeKey] = copyValue(it[idx.rangeKey])
+	}
+	return lek
+}
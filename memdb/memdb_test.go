@@ -0,0 +1,304 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTable = "memdb-test"
+
+func s(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+func n(v string) types.AttributeValue { return &types.AttributeValueMemberN{Value: v} }
+
+func put(t *testing.T, db *DB, it map[string]types.AttributeValue) {
+	t.Helper()
+	_, err := db.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(testTable), Item: it})
+	require.NoError(t, err)
+}
+
+func get(t *testing.T, db *DB, pk, sk string) map[string]types.AttributeValue {
+	t.Helper()
+	out, err := db.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String(testTable),
+		Key:       map[string]types.AttributeValue{"pk": s(pk), "sk": s(sk)},
+	})
+	require.NoError(t, err)
+	return out.Item
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+
+	t.Run("put returns the replaced item with ALL_OLD", func(t *testing.T) {
+		put(t, db, map[string]types.AttributeValue{"pk": s("a"), "sk": s("1"), "name": s("first")})
+		out, err := db.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:    aws.String(testTable),
+			Item:         map[string]types.AttributeValue{"pk": s("a"), "sk": s("1"), "name": s("second")},
+			ReturnValues: types.ReturnValueAllOld,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, s("first"), out.Attributes["name"])
+		assert.Equal(t, s("second"), get(t, db, "a", "1")["name"])
+	})
+	t.Run("get of a missing item returns a nil item", func(t *testing.T) {
+		assert.Nil(t, get(t, db, "missing", "1"))
+	})
+	t.Run("stored items are isolated from caller mutation", func(t *testing.T) {
+		it := get(t, db, "a", "1")
+		it["name"] = s("mutated")
+		assert.Equal(t, s("second"), get(t, db, "a", "1")["name"])
+	})
+	t.Run("conditional put fails with ConditionalCheckFailedException", func(t *testing.T) {
+		_, err := db.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                           aws.String(testTable),
+			Item:                                map[string]types.AttributeValue{"pk": s("a"), "sk": s("1")},
+			ConditionExpression:                 aws.String("attribute_not_exists(pk)"),
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		})
+		var ccf *types.ConditionalCheckFailedException
+		require.True(t, errors.As(err, &ccf))
+		assert.Equal(t, s("second"), ccf.Item["name"])
+	})
+	t.Run("delete returns the deleted item with ALL_OLD", func(t *testing.T) {
+		out, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:    aws.String(testTable),
+			Key:          map[string]types.AttributeValue{"pk": s("a"), "sk": s("1")},
+			ReturnValues: types.ReturnValueAllOld,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, s("second"), out.Attributes["name"])
+		assert.Nil(t, get(t, db, "a", "1"))
+	})
+	t.Run("missing key attributes are rejected", func(t *testing.T) {
+		_, err := db.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(testTable),
+			Item:      map[string]types.AttributeValue{"pk": s("a")},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestUpdateItem(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	put(t, db, map[string]types.AttributeValue{
+		"pk":    s("u"),
+		"sk":    s("1"),
+		"count": n("1"),
+		"tags":  &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		"list":  &types.AttributeValueMemberL{Value: []types.AttributeValue{s("x")}},
+		"gone":  s("soon"),
+	})
+	out, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(testTable),
+		Key:              map[string]types.AttributeValue{"pk": s("u"), "sk": s("1")},
+		UpdateExpression: aws.String("SET #c = #c + :inc, created = if_not_exists(created, :now), #l = list_append(#l, :more) REMOVE gone ADD tags :add DELETE tags :del"),
+		ExpressionAttributeNames: map[string]string{
+			"#c": "count",
+			"#l": "list",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":inc":  n("2.5"),
+			":now":  s("today"),
+			":more": &types.AttributeValueMemberL{Value: []types.AttributeValue{s("y")}},
+			":add":  &types.AttributeValueMemberSS{Value: []string{"c"}},
+			":del":  &types.AttributeValueMemberSS{Value: []string{"a"}},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, n("3.5"), out.Attributes["count"])
+	assert.Equal(t, s("today"), out.Attributes["created"])
+	assert.Len(t, out.Attributes["list"].(*types.AttributeValueMemberL).Value, 2)
+	assert.ElementsMatch(t, []string{"b", "c"}, out.Attributes["tags"].(*types.AttributeValueMemberSS).Value)
+	assert.NotContains(t, out.Attributes, "gone")
+
+	t.Run("if_not_exists keeps an existing value", func(t *testing.T) {
+		out, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(testTable),
+			Key:                       map[string]types.AttributeValue{"pk": s("u"), "sk": s("1")},
+			UpdateExpression:          aws.String("SET created = if_not_exists(created, :now)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":now": s("tomorrow")},
+			ReturnValues:              types.ReturnValueUpdatedNew,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]types.AttributeValue{"created": s("today")}, out.Attributes)
+	})
+	t.Run("updating a missing item creates it", func(t *testing.T) {
+		_, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(testTable),
+			Key:                       map[string]types.AttributeValue{"pk": s("new"), "sk": s("1")},
+			UpdateExpression:          aws.String("ADD visits :one"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":one": n("1")},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, n("1"), get(t, db, "new", "1")["visits"])
+	})
+	t.Run("key attributes can't be updated", func(t *testing.T) {
+		_, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(testTable),
+			Key:                       map[string]types.AttributeValue{"pk": s("u"), "sk": s("1")},
+			UpdateExpression:          aws.String("SET pk = :v"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v": s("other")},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	for _, it := range []map[string]types.AttributeValue{
+		{"pk": s("p"), "sk": s("a#1"), "type": s("x"), "e0pk": s("user"), "e0sk": s("1")},
+		{"pk": s("p"), "sk": s("a#2"), "type": s("y"), "e0pk": s("user"), "e0sk": s("2")},
+		{"pk": s("p"), "sk": s("b#1"), "type": s("x"), "e0pk": s("user"), "e0sk": s("3")},
+		{"pk": s("q"), "sk": s("a#1"), "type": s("x"), "e0pk": s("other"), "e0sk": s("1")},
+		{"pk": s("r"), "sk": s("a#1"), "type": s("x")},
+	} {
+		put(t, db, it)
+	}
+
+	query := func(in *dynamodb.QueryInput) *dynamodb.QueryOutput {
+		t.Helper()
+		in.TableName = aws.String(testTable)
+		out, err := db.Query(ctx, in)
+		require.NoError(t, err)
+		return out
+	}
+	sks := func(items []map[string]types.AttributeValue, attr string) []string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it[attr].(*types.AttributeValueMemberS).Value)
+		}
+		return out
+	}
+
+	t.Run("begins_with on the primary index", func(t *testing.T) {
+		out := query(&dynamodb.QueryInput{
+			KeyConditionExpression:    aws.String("pk = :pk AND begins_with(sk, :sk)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("p"), ":sk": s("a#")},
+		})
+		assert.Equal(t, []string{"a#1", "a#2"}, sks(out.Items, "sk"))
+	})
+	t.Run("entity GSI with a filter expression, descending", func(t *testing.T) {
+		out := query(&dynamodb.QueryInput{
+			IndexName:                 aws.String("e0pk-e0sk-index"),
+			KeyConditionExpression:    aws.String("e0pk = :pk"),
+			FilterExpression:          aws.String("#type = :type"),
+			ExpressionAttributeNames:  map[string]string{"#type": "type"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("user"), ":type": s("x")},
+			ScanIndexForward:          aws.Bool(false),
+		})
+		assert.Equal(t, []string{"3", "1"}, sks(out.Items, "e0sk"))
+		assert.Equal(t, int32(3), out.ScannedCount)
+	})
+	t.Run("between on the sort key with a projection", func(t *testing.T) {
+		out := query(&dynamodb.QueryInput{
+			KeyConditionExpression:    aws.String("pk = :pk AND sk BETWEEN :lo AND :hi"),
+			ProjectionExpression:      aws.String("sk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("p"), ":lo": s("a#2"), ":hi": s("b#1")},
+		})
+		assert.Equal(t, []map[string]types.AttributeValue{{"sk": s("a#2")}, {"sk": s("b#1")}}, out.Items)
+	})
+	t.Run("limit returns a LastEvaluatedKey that resumes the query", func(t *testing.T) {
+		in := &dynamodb.QueryInput{
+			IndexName:                 aws.String("e0pk-e0sk-index"),
+			KeyConditionExpression:    aws.String("e0pk = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("user")},
+			Limit:                     aws.Int32(2),
+		}
+		first := query(in)
+		assert.Equal(t, []string{"1", "2"}, sks(first.Items, "e0sk"))
+		require.NotNil(t, first.LastEvaluatedKey)
+		assert.Contains(t, first.LastEvaluatedKey, "e0pk")
+		in.ExclusiveStartKey = first.LastEvaluatedKey
+		second := query(in)
+		assert.Equal(t, []string{"3"}, sks(second.Items, "e0sk"))
+		assert.Nil(t, second.LastEvaluatedKey)
+	})
+	t.Run("page size splits results without a Limit", func(t *testing.T) {
+		paged := New(WithPageSize(2))
+		for _, it := range db.Items(testTable) {
+			_, err := paged.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(testTable), Item: it})
+			require.NoError(t, err)
+		}
+		in := &dynamodb.QueryInput{
+			TableName:                 aws.String(testTable),
+			KeyConditionExpression:    aws.String("pk = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("p")},
+		}
+		var pages [][]string
+		for {
+			out, err := paged.Query(ctx, in)
+			require.NoError(t, err)
+			pages = append(pages, sks(out.Items, "sk"))
+			if out.LastEvaluatedKey == nil {
+				break
+			}
+			in.ExclusiveStartKey = out.LastEvaluatedKey
+		}
+		assert.Equal(t, [][]string{{"a#1", "a#2"}, {"b#1"}}, pages)
+	})
+	t.Run("unknown index names are rejected", func(t *testing.T) {
+		_, err := db.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(testTable),
+			IndexName:                 aws.String("byEmail"),
+			KeyConditionExpression:    aws.String("email = :e"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":e": s("x")},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestConditionExpressions(t *testing.T) {
+	it := map[string]types.AttributeValue{
+		"name":  s("gobox"),
+		"count": n("10"),
+		"tags":  &types.AttributeValueMemberSS{Value: []string{"go", "dynamo"}},
+		"meta":  &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"level": n("3")}},
+	}
+	values := map[string]types.AttributeValue{
+		":name": s("gobox"),
+		":five": n("5"),
+		":tag":  s("go"),
+		":pre":  s("go"),
+		":lvl":  n("3"),
+		":a":    s("a"),
+		":b":    s("b"),
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"name = :name", true},
+		{"name <> :name", false},
+		{"missing <> :name", true},
+		{"count > :five AND begins_with(name, :pre)", true},
+		{"count < :five OR contains(tags, :tag)", true},
+		{"NOT (count >= :five)", false},
+		{"attribute_exists(meta.level) AND meta.level = :lvl", true},
+		{"attribute_not_exists(missing)", true},
+		{"size(tags) = :five", false},
+		{"name IN (:a, :name, :b)", true},
+		{"attribute_type(count, :a)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := parseCondition(tt.expr, nil, values)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, c.eval(it))
+		})
+	}
+	t.Run("undefined placeholders are an error", func(t *testing.T) {
+		_, err := parseCondition("#missing = :name", nil, values)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,259 @@
+package memdb
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const maxTransactItems = 100
+
+// Cancellation reason codes reported in a TransactionCanceledException.
+const (
+	reasonNone                   = "None"
+	reasonConditionalCheckFailed = "ConditionalCheckFailed"
+)
+
+// transactWrite is a validated TransactWriteItem with the state needed to
+// check its condition and apply it.
+type transactWrite struct {
+	table *table
+	key   string
+	// cond is the condition expression and its attributes.
+	cond   *string
+	names  map[string]string
+	values map[string]types.AttributeValue
+	rv     types.ReturnValuesOnConditionCheckFailure
+	// apply returns the new item, or nil to delete it. It is nil for a
+	// ConditionCheck.
+	apply func(old item) (item, error)
+}
+
+// TransactWriteItems implements clients.DynamoMethods. Every condition is
+// evaluated before any write is applied, and if one fails nothing is
+// written and a TransactionCanceledException with a reason per item is
+// returned.
+func (db *DB) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(in.TransactItems) == 0 || len(in.TransactItems) > maxTransactItems {
+		return nil, validationError("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to %d and greater than or equal to 1", maxTransactItems)
+	}
+
+	writes := make([]transactWrite, len(in.TransactItems))
+	seen := map[string]bool{}
+	for i, ti := range in.TransactItems {
+		w, err := db.transactWrite(ti)
+		if err != nil {
+			return nil, err
+		}
+		id := w.table.name + "\x00" + w.key
+		if seen[id] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+		writes[i] = w
+	}
+
+	reasons := make([]types.CancellationReason, len(writes))
+	canceled := false
+	for i, w := range writes {
+		old := w.table.items[w.key]
+		ok, err := checkCondition(w.cond, w.names, w.values, old)
+		if err != nil {
+			return nil, err
+		}
+		reasons[i].Code = aws.String(reasonNone)
+		if !ok {
+			canceled = true
+			reasons[i].Code = aws.String(reasonConditionalCheckFailed)
+			reasons[i].Message = aws.String("The conditional request failed")
+			if w.rv == types.ReturnValuesOnConditionCheckFailureAllOld && old != nil {
+				reasons[i].Item = copyItem(old)
+			}
+		}
+	}
+	if canceled {
+		return nil, transactionCanceled(reasons)
+	}
+
+	updated := make([]item, len(writes))
+	for i, w := range writes {
+		if w.apply == nil {
+			continue
+		}
+		it, err := w.apply(w.table.items[w.key])
+		if err != nil {
+			return nil, err
+		}
+		updated[i] = it
+	}
+
+	units := map[string]float64{}
+	for i, w := range writes {
+		old := w.table.items[w.key]
+		switch {
+		case w.apply == nil:
+			units[w.table.name] += 2 * math.Max(1, math.Ceil(float64(itemSize(old))/1024))
+		case updated[i] == nil:
+			units[w.table.name] += 2 * db.writeUnits(old)
+			delete(w.table.items, w.key)
+		default:
+			units[w.table.name] += 2 * db.writeUnits(updated[i])
+			w.table.items[w.key] = updated[i]
+		}
+	}
+	out := &dynamodb.TransactWriteItemsOutput{}
+	for _, name := range sortedTableNames(units) {
+		if cc := consumedCapacity(in.ReturnConsumedCapacity, name, units[name]); cc != nil {
+			out.ConsumedCapacity = append(out.ConsumedCapacity, *cc)
+		}
+	}
+	return out, nil
+}
+
+// transactWrite validates a TransactWriteItem, which must hold exactly one
+// operation. The caller must hold db.mu.
+func (db *DB) transactWrite(ti types.TransactWriteItem) (transactWrite, error) {
+	var w transactWrite
+	var err error
+	ops := 0
+	if c := ti.ConditionCheck; c != nil {
+		ops++
+		if c.ConditionExpression == nil || *c.ConditionExpression == "" {
+			return w, validationError("1 validation error detected: Value null at 'transactItems.conditionCheck.conditionExpression' failed to satisfy constraint: Member must not be null")
+		}
+		if w.table, err = db.table(c.TableName); err != nil {
+			return w, err
+		}
+		if w.key, err = validateKey(c.Key); err != nil {
+			return w, err
+		}
+		w.cond, w.names, w.values, w.rv = c.ConditionExpression, c.ExpressionAttributeNames, c.ExpressionAttributeValues, c.ReturnValuesOnConditionCheckFailure
+	}
+	if p := ti.Put; p != nil {
+		ops++
+		if w.table, err = db.table(p.TableName); err != nil {
+			return w, err
+		}
+		if w.key, err = keyString(p.Item); err != nil {
+			return w, err
+		}
+		w.cond, w.names, w.values, w.rv = p.ConditionExpression, p.ExpressionAttributeNames, p.ExpressionAttributeValues, p.ReturnValuesOnConditionCheckFailure
+		w.apply = func(item) (item, error) { return copyItem(p.Item), nil }
+	}
+	if d := ti.Delete; d != nil {
+		ops++
+		if w.table, err = db.table(d.TableName); err != nil {
+			return w, err
+		}
+		if w.key, err = validateKey(d.Key); err != nil {
+			return w, err
+		}
+		w.cond, w.names, w.values, w.rv = d.ConditionExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues, d.ReturnValuesOnConditionCheckFailure
+		w.apply = func(item) (item, error) { return nil, nil }
+	}
+	if u := ti.Update; u != nil {
+		ops++
+		if u.UpdateExpression == nil || *u.UpdateExpression == "" {
+			return w, validationError("1 validation error detected: Value null at 'transactItems.update.updateExpression' failed to satisfy constraint: Member must not be null")
+		}
+		if w.table, err = db.table(u.TableName); err != nil {
+			return w, err
+		}
+		if w.key, err = validateKey(u.Key); err != nil {
+			return w, err
+		}
+		if _, err := parseUpdate(*u.UpdateExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues); err != nil {
+			return w, validationError("%v", err)
+		}
+		w.cond, w.names, w.values, w.rv = u.ConditionExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues, u.ReturnValuesOnConditionCheckFailure
+		w.apply = func(old item) (item, error) {
+			updated, _, err := applyUpdate(u.Key, old, u.UpdateExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues)
+			return updated, err
+		}
+	}
+	if ops != 1 {
+		return w, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+	return w, nil
+}
+
+func transactionCanceled(reasons []types.CancellationReason) error {
+	codes := make([]string, len(reasons))
+	for i, r := range reasons {
+		codes[i] = aws.ToString(r.Code)
+	}
+	return &types.TransactionCanceledException{
+		Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+		CancellationReasons: reasons,
+	}
+}
+
+// TransactGetItems implements clients.DynamoMethods. Responses are returned
+// in request order, with a nil Item for keys that do not exist.
+func (db *DB) TransactGetItems(ctx context.Context, in *dynamodb.TransactGetItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(in.TransactItems) == 0 || len(in.TransactItems) > maxTransactItems {
+		return nil, validationError("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to %d and greater than or equal to 1", maxTransactItems)
+	}
+	tables := make([]*table, len(in.TransactItems))
+	itemKeys := make([]string, len(in.TransactItems))
+	paths := make([][]docPath, len(in.TransactItems))
+	seen := map[string]bool{}
+	for i, ti := range in.TransactItems {
+		g := ti.Get
+		if g == nil {
+			return nil, validationError("1 validation error detected: Value null at 'transactItems.get' failed to satisfy constraint: Member must not be null")
+		}
+		t, err := db.table(g.TableName)
+		if err != nil {
+			return nil, err
+		}
+		k, err := validateKey(g.Key)
+		if err != nil {
+			return nil, err
+		}
+		if seen[t.name+"\x00"+k] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+		seen[t.name+"\x00"+k] = true
+		if g.ProjectionExpression != nil && *g.ProjectionExpression != "" {
+			if paths[i], err = parseProjection(*g.ProjectionExpression, g.ExpressionAttributeNames); err != nil {
+				return nil, validationError("%v", err)
+			}
+		}
+		tables[i], itemKeys[i] = t, k
+	}
+
+	out := &dynamodb.TransactGetItemsOutput{Responses: make([]types.ItemResponse, len(in.TransactItems))}
+	units := map[string]float64{}
+	for i, t := range tables {
+		stored, ok := t.items[itemKeys[i]]
+		units[t.name] += 2 * readUnits(stored, aws.Bool(true))
+		switch {
+		case !ok:
+		case paths[i] != nil:
+			out.Responses[i].Item = project(stored, paths[i])
+		default:
+			out.Responses[i].Item = copyItem(stored)
+		}
+	}
+	for _, name := range sortedTableNames(units) {
+		if cc := consumedCapacity(in.ReturnConsumedCapacity, name, units[name]); cc != nil {
+			out.ConsumedCapacity = append(out.ConsumedCapacity, *cc)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,122 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactWriteItems(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	key := func(pk string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{"pk": s(pk), "sk": s("row")}
+	}
+	put(t, db, map[string]types.AttributeValue{"pk": s("exists"), "sk": s("row"), "n": n("1")})
+	put(t, db, map[string]types.AttributeValue{"pk": s("doomed"), "sk": s("row")})
+
+	check := func(pk string) types.TransactWriteItem {
+		return types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+			TableName:                           aws.String(testTable),
+			Key:                                 key(pk),
+			ConditionExpression:                 aws.String("attribute_exists(pk)"),
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		}}
+	}
+	writes := func(checked string) []types.TransactWriteItem {
+		return []types.TransactWriteItem{
+			check(checked),
+			{Put: &types.Put{TableName: aws.String(testTable), Item: map[string]types.AttributeValue{"pk": s("new"), "sk": s("row")}}},
+			{Delete: &types.Delete{TableName: aws.String(testTable), Key: key("doomed")}},
+			{Update: &types.Update{
+				TableName:                 aws.String(testTable),
+				Key:                       key("counter"),
+				UpdateExpression:          aws.String("ADD n :one"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":one": n("1")},
+			}},
+		}
+	}
+
+	t.Run("a failed condition cancels every write", func(t *testing.T) {
+		_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: writes("missing")})
+		var canceled *types.TransactionCanceledException
+		require.True(t, errors.As(err, &canceled))
+		require.Len(t, canceled.CancellationReasons, 4)
+		assert.Equal(t, "ConditionalCheckFailed", aws.ToString(canceled.CancellationReasons[0].Code))
+		assert.Nil(t, canceled.CancellationReasons[0].Item)
+		assert.Equal(t, "None", aws.ToString(canceled.CancellationReasons[1].Code))
+		assert.Nil(t, get(t, db, "new", "row"))
+		assert.NotNil(t, get(t, db, "doomed", "row"))
+		assert.Nil(t, get(t, db, "counter", "row"))
+	})
+	t.Run("every write is applied when all conditions pass", func(t *testing.T) {
+		out, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems:          writes("exists"),
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		})
+		require.NoError(t, err)
+		require.Len(t, out.ConsumedCapacity, 1)
+		assert.Equal(t, 8.0, aws.ToFloat64(out.ConsumedCapacity[0].CapacityUnits))
+		assert.NotNil(t, get(t, db, "new", "row"))
+		assert.Nil(t, get(t, db, "doomed", "row"))
+		assert.Equal(t, n("1"), get(t, db, "counter", "row")["n"])
+	})
+	t.Run("the stored item is returned for a failed check with ALL_OLD", func(t *testing.T) {
+		failing := check("exists")
+		failing.ConditionCheck.ConditionExpression = aws.String("attribute_not_exists(pk)")
+		_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{failing}})
+		var canceled *types.TransactionCanceledException
+		require.True(t, errors.As(err, &canceled))
+		assert.Equal(t, n("1"), canceled.CancellationReasons[0].Item["n"])
+	})
+	t.Run("two operations on one item are rejected", func(t *testing.T) {
+		_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{check("exists"), {Delete: &types.Delete{TableName: aws.String(testTable), Key: key("exists")}}},
+		})
+		require.Error(t, err)
+		assert.NotNil(t, get(t, db, "exists", "row"))
+	})
+	t.Run("an item must hold exactly one operation", func(t *testing.T) {
+		_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{{}},
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestTransactGetItems(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	put(t, db, map[string]types.AttributeValue{"pk": s("a"), "sk": s("row"), "n": n("1"), "name": s("first")})
+	get := func(pk string) types.TransactGetItem {
+		return types.TransactGetItem{Get: &types.Get{
+			TableName: aws.String(testTable),
+			Key:       map[string]types.AttributeValue{"pk": s(pk), "sk": s("row")},
+		}}
+	}
+
+	t.Run("responses are in request order", func(t *testing.T) {
+		projected := get("a")
+		projected.Get.ProjectionExpression = aws.String("#n")
+		projected.Get.ExpressionAttributeNames = map[string]string{"#n": "name"}
+		out, err := db.TransactGetItems(ctx, &dynamodb.TransactGetItemsInput{
+			TransactItems: []types.TransactGetItem{get("missing"), projected},
+		})
+		require.NoError(t, err)
+		require.Len(t, out.Responses, 2)
+		assert.Nil(t, out.Responses[0].Item)
+		assert.Equal(t, map[string]types.AttributeValue{"name": s("first")}, out.Responses[1].Item)
+	})
+	t.Run("duplicate keys are rejected", func(t *testing.T) {
+		_, err := db.TransactGetItems(ctx, &dynamodb.TransactGetItemsInput{
+			TransactItems: []types.TransactGetItem{get("a"), get("a")},
+		})
+		require.Error(t, err)
+	})
+}
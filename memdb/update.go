@@ -0,0 +1,401 @@
+package memdb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// valueExpr is the right hand side of a SET action.
+type valueExpr interface {
+	evaluate(it item) (types.AttributeValue, error)
+}
+
+type operandExpr struct{ operand operand }
+
+func (e operandExpr) evaluate(it item) (types.AttributeValue, error) {
+	v, ok := e.operand.resolve(it)
+	if !ok {
+		return nil, fmt.Errorf("the provided expression refers to an attribute that does not exist in the item")
+	}
+	return v, nil
+}
+
+type arithmeticExpr struct {
+	op          string
+	left, right valueExpr
+}
+
+func (e arithmeticExpr) evaluate(it item) (types.AttributeValue, error) {
+	l, err := e.left.evaluate(it)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.right.evaluate(it)
+	if err != nil {
+		return nil, err
+	}
+	ln, lok := l.(*types.AttributeValueMemberN)
+	rn, rok := r.(*types.AttributeValueMemberN)
+	if !lok || !rok {
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type")
+	}
+	lr, err := parseNumber(ln.Value)
+	if err != nil {
+		return nil, err
+	}
+	rr, err := parseNumber(rn.Value)
+	if err != nil {
+		return nil, err
+	}
+	if e.op == "+" {
+		lr.Add(lr, rr)
+	} else {
+		lr.Sub(lr, rr)
+	}
+	return &types.AttributeValueMemberN{Value: formatNumber(lr)}, nil
+}
+
+type ifNotExistsExpr struct {
+	path     docPath
+	fallback valueExpr
+}
+
+func (e ifNotExistsExpr) evaluate(it item) (types.AttributeValue, error) {
+	if v, ok := getPath(it, e.path); ok {
+		return v, nil
+	}
+	return e.fallback.evaluate(it)
+}
+
+type listAppendExpr struct{ first, second valueExpr }
+
+func (e listAppendExpr) evaluate(it item) (types.AttributeValue, error) {
+	a, err := e.first.evaluate(it)
+	if err != nil {
+		return nil, err
+	}
+	b, err := e.second.evaluate(it)
+	if err != nil {
+		return nil, err
+	}
+	al, aok := a.(*types.AttributeValueMemberL)
+	bl, bok := b.(*types.AttributeValueMemberL)
+	if !aok || !bok {
+		return nil, fmt.Errorf("an operand in the update expression has an incorrect data type")
+	}
+	out := make([]types.AttributeValue, 0, len(al.Value)+len(bl.Value))
+	for _, v := range al.Value {
+		out = append(out, copyValue(v))
+	}
+	for _, v := range bl.Value {
+		out = append(out, copyValue(v))
+	}
+	return &types.AttributeValueMemberL{Value: out}, nil
+}
+
+type updateAction struct {
+	clause string
+	path   docPath
+	value  valueExpr
+}
+
+// updatePlan is a parsed update expression.
+type updatePlan struct {
+	actions []updateAction
+}
+
+// parseUpdate parses an update expression made of SET, REMOVE, ADD and
+// DELETE clauses.
+func parseUpdate(expr string, names map[string]string, values map[string]types.AttributeValue) (*updatePlan, error) {
+	p, err := newParser(expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	plan := &updatePlan{}
+	seen := map[string]bool{}
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokIdent || (clause != "SET" && clause != "REMOVE" && clause != "ADD" && clause != "DELETE") {
+			p.pos--
+			return nil, p.errorf("expected SET, REMOVE, ADD or DELETE")
+		}
+		if seen[clause] {
+			return nil, p.errorf("the %s section can only be used once in an update expression", clause)
+		}
+		seen[clause] = true
+		for {
+			action, err := p.parseUpdateAction(clause)
+			if err != nil {
+				return nil, err
+			}
+			plan.actions = append(plan.actions, action)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if len(plan.actions) == 0 {
+		return nil, fmt.Errorf("invalid update expression %q: the expression can not be empty", expr)
+	}
+	return plan, nil
+}
+
+func (p *parser) parseUpdateAction(clause string) (updateAction, error) {
+	path, err := p.parsePath()
+	if err != nil {
+		return updateAction{}, err
+	}
+	action := updateAction{clause: clause, path: path}
+	switch clause {
+	case "SET":
+		if err := p.expectPunct("="); err != nil {
+			return action, err
+		}
+		action.value, err = p.parseSetValue()
+	case "ADD", "DELETE":
+		var v types.AttributeValue
+		v, err = p.parseValuePlaceholder()
+		action.value = operandExpr{valueOperand{v}}
+	}
+	return action, err
+}
+
+func (p *parser) parseSetValue() (valueExpr, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct("+") || p.isPunct("-") {
+		op := p.next().text
+		right, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticExpr{op, left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseSetOperand() (valueExpr, error) {
+	t := p.peek()
+	if t.kind == tokIdent && p.toks[p.pos+1].text == "(" {
+		switch strings.ToLower(t.text) {
+		case "if_not_exists":
+			p.next()
+			p.next()
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			return ifNotExistsExpr{path, fallback}, p.expectPunct(")")
+		case "list_append":
+			p.next()
+			p.next()
+			first, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			second, err := p.parseSetValue()
+			if err != nil {
+				return nil, err
+			}
+			return listAppendExpr{first, second}, p.expectPunct(")")
+		}
+	}
+	o, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return operandExpr{o}, nil
+}
+
+// touched returns the top level attribute names modified by the plan.
+func (u *updatePlan) touched() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, a := range u.actions {
+		if !seen[a.path[0].name] {
+			seen[a.path[0].name] = true
+			names = append(names, a.path[0].name)
+		}
+	}
+	return names
+}
+
+// apply evaluates every action against the original item and writes the
+// results into a copy, mirroring DynamoDB where all operands see the item as
+// it was before the update.
+func (u *updatePlan) apply(original item) (item, error) {
+	out := copyItem(original)
+	for _, a := range u.actions {
+		switch a.clause {
+		case "SET":
+			v, err := a.value.evaluate(original)
+			if err != nil {
+				return nil, err
+			}
+			if err := setPath(out, a.path, copyValue(v)); err != nil {
+				return nil, err
+			}
+		case "REMOVE":
+			removePath(out, a.path)
+		case "ADD":
+			v, _ := a.value.evaluate(original)
+			existing, ok := getPath(out, a.path)
+			if !ok {
+				if err := setPath(out, a.path, copyValue(v)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			merged, err := addValues(existing, v)
+			if err != nil {
+				return nil, err
+			}
+			if err := setPath(out, a.path, merged); err != nil {
+				return nil, err
+			}
+		case "DELETE":
+			v, _ := a.value.evaluate(original)
+			existing, ok := getPath(out, a.path)
+			if !ok {
+				continue
+			}
+			remaining, err := deleteFromSet(existing, v)
+			if err != nil {
+				return nil, err
+			}
+			if remaining == nil {
+				removePath(out, a.path)
+				continue
+			}
+			if err := setPath(out, a.path, remaining); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+func addValues(existing, delta types.AttributeValue) (types.AttributeValue, error) {
+	switch e := existing.(type) {
+	case *types.AttributeValueMemberN:
+		return arithmeticExpr{"+", operandExpr{valueOperand{e}}, operandExpr{valueOperand{delta}}}.evaluate(nil)
+	case *types.AttributeValueMemberSS:
+		d, ok := delta.(*types.AttributeValueMemberSS)
+		if !ok {
+			break
+		}
+		return &types.AttributeValueMemberSS{Value: unionStrings(e.Value, d.Value)}, nil
+	case *types.AttributeValueMemberNS:
+		d, ok := delta.(*types.AttributeValueMemberNS)
+		if !ok {
+			break
+		}
+		return &types.AttributeValueMemberNS{Value: unionStrings(e.Value, d.Value)}, nil
+	case *types.AttributeValueMemberBS:
+		d, ok := delta.(*types.AttributeValueMemberBS)
+		if !ok {
+			break
+		}
+		out := append([][]byte(nil), e.Value...)
+		for _, b := range d.Value {
+			if !containsBytes(out, b) {
+				out = append(out, b)
+			}
+		}
+		return &types.AttributeValueMemberBS{Value: out}, nil
+	}
+	return nil, fmt.Errorf("an operand in the update expression has an incorrect data type")
+}
+
+func deleteFromSet(existing, remove types.AttributeValue) (types.AttributeValue, error) {
+	switch e := existing.(type) {
+	case *types.AttributeValueMemberSS:
+		r, ok := remove.(*types.AttributeValueMemberSS)
+		if !ok {
+			break
+		}
+		if out := subtractStrings(e.Value, r.Value); len(out) > 0 {
+			return &types.AttributeValueMemberSS{Value: out}, nil
+		}
+		return nil, nil
+	case *types.AttributeValueMemberNS:
+		r, ok := remove.(*types.AttributeValueMemberNS)
+		if !ok {
+			break
+		}
+		if out := subtractStrings(e.Value, r.Value); len(out) > 0 {
+			return &types.AttributeValueMemberNS{Value: out}, nil
+		}
+		return nil, nil
+	case *types.AttributeValueMemberBS:
+		r, ok := remove.(*types.AttributeValueMemberBS)
+		if !ok {
+			break
+		}
+		var out [][]byte
+		for _, b := range e.Value {
+			if !containsBytes(r.Value, b) {
+				out = append(out, b)
+			}
+		}
+		if len(out) > 0 {
+			return &types.AttributeValueMemberBS{Value: out}, nil
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("an operand in the update expression has an incorrect data type")
+}
+
+func unionStrings(a, b []string) []string {
+	out := append([]string(nil), a...)
+	for _, s := range b {
+		if !containsString(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func subtractStrings(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if !containsString(b, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func containsString(set []string, s string) bool {
+	for _, v := range set {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsBytes(set [][]byte, b []byte) bool {
+	for _, v := range set {
+		if string(v) == string(b) {
+			return true
+		}
+	}
+	return false
+}
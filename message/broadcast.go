@@ -28,7 +28,11 @@ func BroadcastMessage(ctx context.Context, title string, item any) (*eventbridge
 		return nil, err
 	}
 
-	out, err := clients.GetDefaultClient(ctx).EventBridge().PutEvents(ctx, &eventbridge.PutEventsInput{
+	client, err := clients.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.EventBridge().PutEvents(ctx, &eventbridge.PutEventsInput{
 		Entries: []types.PutEventsRequestEntry{
 			{
 				Detail:       aws.String(string(detailBytes)),
@@ -11,8 +11,12 @@ import (
 )
 
 // SendMessage accepts an item and sends it to the SQS queue url provided by the queueURL argument
+// using the client carried by ctx, or the default client.
 func Send(ctx context.Context, queueURL string, item any) (*sqs.SendMessageOutput, error) {
-	client := clients.GetDefaultClient(ctx)
+	client, err := clients.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return SendWithClient(ctx, client, queueURL, item)
 }
 
@@ -9,7 +9,10 @@ import (
 )
 
 func Delete(ctx context.Context, bucket string, item types.Keyable) error {
-	client := clients.GetDefaultClient(ctx)
+	client, err := clients.Resolve(ctx)
+	if err != nil {
+		return err
+	}
 	pk, sk, err := item.Keys(0)
 	if err != nil {
 		return err
@@ -12,7 +12,10 @@ import (
 )
 
 func Put(ctx context.Context, bucket string, item types.Keyable) error {
-	client := clients.GetDefaultClient(ctx)
+	client, err := clients.Resolve(ctx)
+	if err != nil {
+		return err
+	}
 	pk, sk, err := item.Keys(0)
 	if err != nil {
 		return err
@@ -7,8 +7,60 @@ import (
 )
 
 // Hookable defines an object that has before and after hooks designed for use in
-// situations where the item will be persisted
+// situations where the item will be persisted. Before runs ahead of every
+// put, update and delete of the row, before the operation specific hook, and
+// After runs once the write succeeded, after the operation specific hook.
 type Hookable interface {
 	Before(ctx context.Context, clients *clients.Client) error
 	After(ctx context.Context, clients *clients.Client) error
 }
+
+// BeforeGetHook is implemented by rows that run code before they are loaded.
+// Returning an error aborts the read.
+type BeforeGetHook interface {
+	BeforeGet(ctx context.Context, client *clients.Client) error
+}
+
+// AfterGetHook is implemented by rows that run code after they are loaded,
+// for instance to compute derived fields. It is only called for rows that
+// were found.
+type AfterGetHook interface {
+	AfterGet(ctx context.Context, client *clients.Client) error
+}
+
+// BeforePutHook is implemented by rows that run code before they are
+// written, for instance to set timestamps, validate fields or compute
+// derived keys. It runs before the row is marshalled, so changes it makes
+// are written. Returning an error aborts the write.
+type BeforePutHook interface {
+	BeforePut(ctx context.Context, client *clients.Client) error
+}
+
+// AfterPutHook is implemented by rows that run code after they are written.
+type AfterPutHook interface {
+	AfterPut(ctx context.Context, client *clients.Client) error
+}
+
+// BeforeDeleteHook is implemented by rows that run code before they are
+// deleted. Returning an error aborts the delete.
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context, client *clients.Client) error
+}
+
+// AfterDeleteHook is implemented by rows that run code after they are
+// deleted.
+type AfterDeleteHook interface {
+	AfterDelete(ctx context.Context, client *clients.Client) error
+}
+
+// BeforeUpdateHook is implemented by rows that run code before they are
+// updated. Returning an error aborts the update.
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context, client *clients.Client) error
+}
+
+// AfterUpdateHook is implemented by rows that run code after they are
+// updated.
+type AfterUpdateHook interface {
+	AfterUpdate(ctx context.Context, client *clients.Client) error
+}
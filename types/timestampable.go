@@ -0,0 +1,8 @@
+package types
+
+// Timestampable is an interface for rows with audit timestamps. Put records
+// when the row was first created and when it was last written.
+type Timestampable interface {
+	// SetTimestamps sets the created and updated times of the row.
+	SetTimestamps(createdAt, updatedAt DateTime)
+}
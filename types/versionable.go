@@ -0,0 +1,11 @@
+package types
+
+// Versionable is an interface for rows that use optimistic locking. Put
+// writes a Versionable row only if the version stored in DynamoDB matches
+// the version that was loaded, and increments it.
+type Versionable interface {
+	// GetVersion returns the version of the row.
+	GetVersion() int64
+	// SetVersion sets the version of the row.
+	SetVersion(version int64)
+}